@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/emetsger/negtracker/urlutil/strip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Runs the backup subcommand, copying the storage layer of a running server to a file or standard output, e.g.:
+//   ADMIN_TOKEN=secret negtracker backup -url http://localhost:8080 -o negtracker-backup.db
+//
+// The copy is made online, by GET /admin/backup: the server continues to serve requests, including writes, while it is
+// copied.  Only storage layers kept in a local file (DB_TYPE=bolt) may be backed up.  The copy may be restored by
+// configuring it as the DB_PATH of a stopped server.
+//
+// The administrative token is read from ADMIN_TOKEN.  When writing to a file, the file is only created once the copy is
+// complete, so a failed backup never leaves a truncated copy in its place.
+func runBackup(args []string, client *http.Client, stdout io.Writer) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	serverUrl := flags.String("url", "", "base URL of the running server, e.g. http://localhost:8080")
+	out := flags.String("o", "", "file to write the backup to; defaults to standard output")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *serverUrl == "" {
+		return errors.New("the -url of the running server is required")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strip.TrailingSlashes(*serverUrl)+"/admin/backup", nil)
+	if err != nil {
+		return fmt.Errorf("malformed url: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+getEnvOrDefault("ADMIN_TOKEN", ""))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != 200 {
+		detail, _ := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+		return fmt.Errorf("server responded %s: %s", res.Status, detail)
+	}
+
+	if *out == "" {
+		_, err = io.Copy(stdout, res.Body)
+		return err
+	}
+
+	// the copy is written beside the file, and renamed once complete
+	f, err := ioutil.TempFile(filepath.Dir(*out), filepath.Base(*out)+".*.partial")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, res.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), *out)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/emetsger/negtracker/handler/neg"
+	"github.com/emetsger/negtracker/store"
+	"io"
+	"net/url"
+	"os"
+)
+
+// Media types of the formats accepted by the -format flag of the export subcommand
+var exportFormats = map[string]string{
+	"ndjson": neg.NdjsonMediaType,
+	"csv":    neg.CsvMediaType,
+}
+
+// Runs the export subcommand, writing every Neg in the store to a file or standard output, e.g.:
+//   negtracker export -format csv -query 'film=Tri-X&ei_min=400' -o tri-x.csv
+//
+// The -query flag accepts the query parameters supported by GET /neg, URL-encoded.
+func runExport(args []string, s store.Api, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "ndjson", "format of the export: ndjson or csv")
+	query := flags.String("query", "", "URL-encoded query parameters selecting the Negs to export, as for GET /neg")
+	out := flags.String("o", "", "file to write the export to; defaults to standard output")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	mediaType, ok := exportFormats[*format]
+	if !ok {
+		return fmt.Errorf("unknown format '%s', must be ndjson or csv", *format)
+	}
+
+	params, err := url.ParseQuery(*query)
+	if err != nil {
+		return fmt.Errorf("malformed query: %w", err)
+	}
+
+	dest := stdout
+	var f *os.File
+	if *out != "" {
+		if f, err = os.Create(*out); err != nil {
+			return err
+		}
+		dest = f
+	}
+
+	buf := bufio.NewWriter(dest)
+	if err = neg.Export(buf, s, params, mediaType); err == nil {
+		err = buf.Flush()
+	}
+
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
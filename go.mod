@@ -5,5 +5,7 @@ go 1.13
 require (
 	github.com/google/uuid v1.1.2
 	github.com/stretchr/testify v1.6.1
+	go.etcd.io/bbolt v1.3.5
 	go.mongodb.org/mongo-driver v1.4.1
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
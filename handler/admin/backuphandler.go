@@ -0,0 +1,53 @@
+package admin
+
+import (
+	"fmt"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/store"
+	"log"
+	"net/http"
+	"time"
+)
+
+// The media type of a backup, which is in the format of the storage layer
+const BackupMediaType = "application/octet-stream"
+
+// Represents the configuration of the backup handler
+type BackupConfig struct {
+	// The bearer token that must be presented by administrators.  If empty, every request is forbidden.
+	Token string
+}
+
+// Returns an http.HandlerFunc which writes a consistent copy of the storage layer to the response, while the storage
+// layer remains in use.  Only GET requests bearing the administrative token are honored.
+//
+// The copy is streamed as it is written by the storage layer.  If the storage layer fails before the copy has begun, a
+// 500 is written; once the response has begun, the connection is aborted instead, so the client does not mistake a
+// truncated backup for a complete one.
+func NewBackupHandler(s store.Backupable, c *BackupConfig) http.HandlerFunc {
+	return guarded(http.MethodGet, c.Token, func(w http.ResponseWriter, r *http.Request) http.HandlerFunc {
+		return backup(s)
+	})
+}
+
+func backup(s store.Backupable) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filename := fmt.Sprintf("negtracker-%s.db", time.Now().UTC().Format("20060102T150405Z"))
+		w.Header().Set("Content-Type", BackupMediaType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+		// the status is written along with the first bytes of the copy
+		n, err := s.Backup(w)
+		if err != nil && n == 0 {
+			log.Printf("handler/admin: backup failed: %v", err)
+			w.Header().Del("Content-Disposition")
+			handler.ServerError(w, r)
+			return
+		} else if err != nil {
+			log.Printf("handler/admin: backup aborted after %d bytes: %v", n, err)
+			panic(http.ErrAbortHandler)
+		}
+
+		log.Printf("handler/admin: backed up %d bytes", n)
+	}
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// A store.Backupable writing a fixed copy, failing after the supplied number of bytes, if any
+type fixedBackup struct {
+	copy   string
+	failAt int
+}
+
+func (b fixedBackup) Backup(w io.Writer) (int64, error) {
+	if b.failAt == 0 {
+		return 0, errors.New("moo")
+	} else if b.failAt > 0 {
+		n, _ := io.WriteString(w, b.copy[:b.failAt])
+		return int64(n), errors.New("moo")
+	}
+	n, err := io.WriteString(w, b.copy)
+	return int64(n), err
+}
+
+func backupAs(b fixedBackup, method, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "/admin/backup", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	NewBackupHandler(b, &BackupConfig{Token: "secret"})(w, r)
+	return w
+}
+
+func TestBackupHandler(t *testing.T) {
+	w := backupAs(fixedBackup{copy: "backup", failAt: -1}, http.MethodGet, "secret")
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, BackupMediaType, w.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename="negtracker-\d{8}T\d{6}Z\.db"$`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "backup", w.Body.String())
+
+	assert.Equal(t, 401, backupAs(fixedBackup{failAt: -1}, http.MethodGet, "").Code)
+	assert.Equal(t, 403, backupAs(fixedBackup{failAt: -1}, http.MethodGet, "moo").Code)
+	assert.Equal(t, 405, backupAs(fixedBackup{failAt: -1}, http.MethodPost, "secret").Code)
+}
+
+func TestBackupHandler_Failed(t *testing.T) {
+	w := backupAs(fixedBackup{copy: "backup", failAt: 0}, http.MethodGet, "secret")
+	assert.Equal(t, 500, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+
+	// once the copy has begun, the response is aborted
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		backupAs(fixedBackup{copy: "backup", failAt: 3}, http.MethodGet, "secret")
+	})
+}
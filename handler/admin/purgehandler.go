@@ -0,0 +1,107 @@
+// Provides handlers for administrative operations, which are not part of the public API.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const bearer = "Bearer"
+
+// Represents the configuration of the purge handler
+type PurgeConfig struct {
+	// The bearer token that must be presented by administrators.  If empty, every request is forbidden.
+	Token string
+	// Tombstones of business objects that were deleted longer ago than Age are purged.  May be overridden per request
+	// by the `age` query parameter, e.g. `?age=72h`.
+	Age time.Duration
+}
+
+// The response to a successful purge
+type purgeResult struct {
+	// Number of tombstones that were physically removed
+	Purged int
+	// Tombstones of business objects deleted prior to this time were purged
+	DeletedBefore time.Time
+}
+
+// Returns an http.HandlerFunc which physically removes the tombstones of deleted business objects from the storage
+// layer.  Only POST requests bearing the administrative token are honored.
+func NewPurgeHandler(s store.Api, c *PurgeConfig) http.HandlerFunc {
+	return guarded(http.MethodPost, c.Token, func(w http.ResponseWriter, r *http.Request) http.HandlerFunc {
+		return purge(w, r, s, c.Age)
+	})
+}
+
+// Returns an http.HandlerFunc which honors only requests of the supplied method bearing the administrative token, by
+// serving the http.HandlerFunc returned by op.
+func guarded(method, token string, op func(w http.ResponseWriter, r *http.Request) http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var h http.HandlerFunc
+		switch {
+		case r.Method != method:
+			h = func(w http.ResponseWriter, r *http.Request) {
+				handler.MethodNotAllowed(w, r, method)
+			}
+		case !strings.HasPrefix(r.Header.Get("Authorization"), bearer+" "):
+			h = func(w http.ResponseWriter, r *http.Request) {
+				handler.Unauthorized(w, r, bearer)
+			}
+		case !authorized(r.Header.Get("Authorization")[len(bearer)+1:], token):
+			h = func(w http.ResponseWriter, r *http.Request) {
+				handler.Forbidden(w, r)
+			}
+		default:
+			h = op(w, r)
+		}
+
+		h.ServeHTTP(w, r)
+	}
+}
+
+func purge(w http.ResponseWriter, r *http.Request, s store.Api, age time.Duration) (h http.HandlerFunc) {
+	if value := r.URL.Query().Get("age"); value != "" {
+		var err error
+		if age, err = time.ParseDuration(value); err != nil || age < 0 {
+			return func(w http.ResponseWriter, r *http.Request) {
+				handler.MalformedRequest(w, r, fmt.Sprintf("Invalid age '%s'", value))
+			}
+		}
+	}
+
+	result := purgeResult{DeletedBefore: time.Now().UTC().Add(-age)}
+
+	var err error
+	if result.Purged, err = s.Purge(result.DeletedBefore); err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.ServerError(w, r)
+		}
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.ServerError(w, r)
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(200)
+		_, _ = w.Write(body)
+	}
+}
+
+// Compares the presented token with the administrative token in constant time.  An empty administrative token never
+// authorizes a request.
+func authorized(presented, token string) bool {
+	return len(token) > 0 && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
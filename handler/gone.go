@@ -0,0 +1,9 @@
+package handler
+
+import (
+	"net/http"
+)
+
+func Gone(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, NewProblem(410, "gone", "Resource has been deleted"))
+}
@@ -2,13 +2,14 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 )
 
 func MalformedRequest(w http.ResponseWriter, r *http.Request, reason string) {
-	bytes := []byte(reason)
-	w.Header().Set("Content-Type", "text/plain")
-	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
-	w.WriteHeader(400)
-	_, _ = w.Write(bytes)
+	WriteProblem(w, r, NewProblem(400, "malformed-request", reason))
+}
+
+// Responds with a 400, identifying the request parameters or fields that are invalid in the "invalid-params" extension
+// member of the problem.
+func InvalidParams(w http.ResponseWriter, r *http.Request, reason string, params []FieldError) {
+	WriteProblem(w, r, NewProblem(400, "invalid-params", reason).With("invalid-params", params))
 }
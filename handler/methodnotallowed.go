@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Responds with a 405, listing the methods supported by the target resource in the Allow header.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	WriteProblem(w, r, NewProblem(405, "method-not-allowed", fmt.Sprintf("Method %s is not allowed", r.Method)))
+}
@@ -0,0 +1,203 @@
+package neg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/id"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"io"
+	"mime"
+	"net/http"
+)
+
+const (
+	// The maximum size of the request body of a bulk import
+	maxBulkBytes = 32 << 20
+	// The maximum size of a single line of a bulk import
+	maxBulkLineBytes = 1 << 20
+	// The number of lines of a bulk import that are inserted by each call to the storage layer
+	bulkBatchSize = 500
+)
+
+// The outcome of importing a line of a bulk import, written to the response as a line of newline-delimited JSON
+type bulkResult struct {
+	// The number of the line, starting from 1
+	Line int `json:"line"`
+	// The id of the business object created from the line, or supplied by it
+	Id string `json:"id,omitempty"`
+	// The status of the line: 201 if a business object was created, otherwise the status a POST of the line would
+	// have resulted in
+	Status int `json:"status"`
+	// Describes why the line was not imported
+	Error string `json:"error,omitempty"`
+	// The reasons each offending field of the line was rejected
+	Violations map[string][]string `json:"violations,omitempty"`
+}
+
+// A line of a bulk import, and the business object unmarshaled from it
+type bulkLine struct {
+	result bulkResult
+	// the business object to store, nil if the line was rejected
+	t interface{}
+}
+
+// Returns an http.HandlerFunc capable of creating a business object from each line of a newline-delimited JSON request
+// body.  Each line is unmarshaled to a new model struct returned by `newT`, and is validated as if it had been POSTed.
+// Blank lines are ignored.
+//
+// Lines are inserted in batches, and a line that fails (e.g. because a business object with the same id already
+// exists) does not prevent the remaining lines from being imported.  The outcome of each line is streamed back as a line
+// of newline-delimited JSON, in the order of the request, as each batch completes.  A line reported with a 5xx may
+// nonetheless have been imported, if the storage layer failed part way through its batch.
+func bulk(w http.ResponseWriter, r *http.Request, s store.Api, newT func() interface{}) http.HandlerFunc {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil ||
+		mediaType != NdjsonMediaType {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.UnsupportedMediaType(w, r, "Content-Type must be "+NdjsonMediaType)
+		}
+	}
+
+	// the request body is read in its entirety before responding: a server may not permit the request body to be read
+	// once the response has begun
+	body := &io.LimitedReader{R: r.Body, N: maxBulkBytes + 1}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxBulkLineBytes)
+
+	var lines []*bulkLine
+	for n := 1; scanner.Scan(); n++ {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			lines = append(lines, parseLine(n, line, newT()))
+		}
+	}
+
+	if err := scanner.Err(); err == bufio.ErrTooLong || body.N < 1 {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.WriteProblem(w, r, handler.NewProblem(413, "request-too-large",
+				fmt.Sprintf("Request body must be at most %d bytes, with lines of at most %d bytes",
+					maxBulkBytes, maxBulkLineBytes)))
+		}
+	} else if err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.MalformedRequest(w, r, "Unable to read request body")
+		}
+	}
+
+	if len(lines) == 0 {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.MalformedRequest(w, r, "Request body contains no lines")
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", NdjsonMediaType)
+		w.WriteHeader(200)
+
+		enc := json.NewEncoder(w)
+		for start := 0; start < len(lines); start += bulkBatchSize {
+			end := start + bulkBatchSize
+			if end > len(lines) {
+				end = len(lines)
+			}
+
+			storeBatch(s, lines[start:end])
+
+			for _, line := range lines[start:end] {
+				if err := enc.Encode(line.result); err != nil {
+					// the client has gone away
+					return
+				}
+			}
+
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	}
+}
+
+// Unmarshals and validates a line of a bulk import to `t`, minting its id and setting its timestamps as a POST would.
+// The result of a rejected line carries the status and reason it was rejected.
+func parseLine(n int, line []byte, t interface{}) *bulkLine {
+	result := bulkResult{Line: n}
+
+	violations, err := decode(line, t)
+	if err != nil {
+		result.Status, result.Error = 400, "Malformed JSON"
+		return &bulkLine{result: result}
+	}
+
+	if len(violations) == 0 {
+		violations = violationsOf(t).ByField()
+	}
+
+	e, ok := t.(model.WebResource)
+	if !ok {
+		panic(fmt.Sprintf("handler/neg: unable to determine id of imported entity, unhandled type %T", t))
+	}
+	result.Id = e.GetId()
+
+	if len(violations) > 0 {
+		result.Status, result.Error, result.Violations = 422, "Request body is invalid", violations
+		return &bulkLine{result: result}
+	}
+
+	if e.GetId() == "" {
+		e.SetId(id.Mint())
+		result.Id = e.GetId()
+	}
+	created := now()
+	e.SetCreated(created)
+	e.SetUpdated(created)
+
+	return &bulkLine{result: result, t: t}
+}
+
+// Stores the business objects of the accepted lines in a single call to the storage layer, recording the outcome of
+// each in its result.
+//
+// A line that the storage layer reports an error for carries the status of that error.  If the call fails as a whole,
+// the remaining lines carry the status of the overall failure, a 5xx; as the business objects are stored independently,
+// some of them may nevertheless have been stored, so a retry of such a line may result in a 409.
+func storeBatch(s store.Api, lines []*bulkLine) {
+	var objs []interface{}
+	var accepted []*bulkLine
+	for _, line := range lines {
+		if line.t != nil {
+			objs = append(objs, line.t)
+			accepted = append(accepted, line)
+		}
+	}
+
+	if len(objs) == 0 {
+		return
+	}
+
+	errs, err := s.StoreMany(objs)
+	for i, line := range accepted {
+		switch {
+		case i < len(errs) && errs[i] != nil:
+			line.result.Status, line.result.Error = bulkStatus(errs[i])
+		case err != nil:
+			line.result.Status, line.result.Error = bulkStatus(err)
+		default:
+			line.result.Status = 201
+		}
+	}
+}
+
+// Returns the status and reason of a line that could not be stored, choosing the status by the code of the error as
+// storageFailed does.
+func bulkStatus(err error) (int, string) {
+	switch {
+	case store.CodeOf(err) == store.CodeDuplicateKey:
+		return 409, "A resource with the same id already exists"
+	case store.Temporary(err):
+		return 503, "Service unavailable"
+	default:
+		return 500, "Server error"
+	}
+}
@@ -0,0 +1,118 @@
+package neg
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A store.Api that counts the calls to StoreMany
+type manyRecorder struct {
+	*memory.MemoryStore
+	calls int
+}
+
+func (s *manyRecorder) StoreMany(objs []interface{}) ([]error, error) {
+	s.calls++
+	return s.MemoryStore.StoreMany(objs)
+}
+
+func Test_Bulk(t *testing.T) {
+	s := &manyRecorder{MemoryStore: memStore(t, &model.Neg{Id: "moo", Film: "Tri-X"})}
+	h := NewHandler(s, nil)
+
+	body := strings.Join([]string{
+		`{"Film": "Tri-X", "EI": 400}`,
+		``,
+		`{"Id": "moo", "Film": "HP5"}`,
+		`{"Film": `,
+		`{"EI": -1}`,
+		`{"Film": "FP4"}`,
+	}, "\n")
+	r := httptest.NewRequest("POST", "/neg/_bulk", bytes.NewBufferString(body))
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	h(w, r)
+
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, 1, s.calls)
+
+	var results []bulkResult
+	dec := json.NewDecoder(w.Body)
+	for dec.More() {
+		result := bulkResult{}
+		require.Nil(t, dec.Decode(&result))
+		results = append(results, result)
+	}
+
+	require.Len(t, results, 5)
+	assert.Equal(t, 1, results[0].Line)
+	assert.Equal(t, 201, results[0].Status)
+	assert.NotEmpty(t, results[0].Id)
+	assert.Equal(t, bulkResult{Line: 3, Id: "moo", Status: 409, Error: "A resource with the same id already exists"},
+		results[1])
+	assert.Equal(t, bulkResult{Line: 4, Status: 400, Error: "Malformed JSON"}, results[2])
+	assert.Equal(t, 422, results[3].Status)
+	assert.Contains(t, results[3].Violations, "Film")
+	assert.Contains(t, results[3].Violations, "EI")
+	assert.Equal(t, 201, results[4].Status)
+
+	assert.Equal(t, "Tri-X", retrieved(t, s, results[0].Id).Film)
+	assert.Equal(t, "Tri-X", retrieved(t, s, "moo").Film)
+	assert.Equal(t, "FP4", retrieved(t, s, results[4].Id).Film)
+}
+
+// A store.Api whose StoreMany stores the first of the supplied business objects and fails to marshal the second, before
+// failing as a whole, as an unordered insert interrupted by a network failure would
+type interruptedStore struct {
+	*memory.MemoryStore
+}
+
+func (s interruptedStore) StoreMany(objs []interface{}) ([]error, error) {
+	errs := make([]error, len(objs))
+	_, errs[0] = s.Store(objs[0])
+	errs[1] = store.GenericErr("attempt to marshal document failed", nil)
+	return errs, store.SentinelErr(store.UnavailableErr, "attempt to insert documents failed", nil)
+}
+
+// the errors of individual lines are reported in preference to the failure of the batch as a whole
+func Test_BulkInterrupted(t *testing.T) {
+	s := interruptedStore{memStore(t)}
+	h := NewHandler(s, nil)
+
+	body := strings.Join([]string{`{"Id": "moo", "Film": "Tri-X"}`, `{"Film": "HP5"}`, `{"Film": "FP4"}`}, "\n")
+	r := httptest.NewRequest("POST", "/neg/_bulk", bytes.NewBufferString(body))
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	h(w, r)
+	require.Equal(t, 200, w.Code)
+
+	var statuses []int
+	dec := json.NewDecoder(w.Body)
+	for dec.More() {
+		result := bulkResult{}
+		require.Nil(t, dec.Decode(&result))
+		statuses = append(statuses, result.Status)
+	}
+	assert.Equal(t, []int{503, 500, 503}, statuses)
+
+	// the first line was stored, despite being reported as unavailable
+	assert.Nil(t, s.Retrieve("moo", &model.Neg{}))
+}
+
+func Test_BulkUnsupportedMediaType(t *testing.T) {
+	h := NewHandler(nil, nil)
+	r := httptest.NewRequest("POST", "/neg/_bulk", bytes.NewBufferString(`{"Film": "Tri-X"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h(w, r)
+	assert.Equal(t, 415, w.Code)
+}
@@ -0,0 +1,117 @@
+package neg
+
+import (
+	"github.com/emetsger/negtracker/model"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Parses the value of a header carrying a list of entity tags (e.g. If-Match), per RFC 7232 §3.1.  The returned boolean
+// is true if the header value is the wildcard "*", in which case the returned slice is empty.
+//
+// Malformed members of the list are skipped.
+func parseEtags(header string) (tags []model.Etag, wildcard bool) {
+	if strings.TrimSpace(header) == "*" {
+		return nil, true
+	}
+
+	s := header
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " \t,")
+		if len(s) == 0 {
+			break
+		}
+
+		weak := ""
+		if strings.HasPrefix(s, "W/") {
+			weak = "W/"
+			s = s[2:]
+		}
+
+		if !strings.HasPrefix(s, "\"") {
+			// not an entity tag, skip to the next list member
+			if i := strings.Index(s, ","); i > -1 {
+				s = s[i:]
+				continue
+			}
+			break
+		}
+
+		end := strings.Index(s[1:], "\"")
+		if end < 0 {
+			// unterminated opaque tag
+			break
+		}
+
+		tags = append(tags, model.Etag(weak+s[:end+2]))
+		s = s[end+2:]
+	}
+
+	return tags, false
+}
+
+// Evaluates an If-Match precondition against the current ETag of a resource, per RFC 7232 §3.1.  Returns true if the
+// precondition holds, i.e. the header is the wildcard, or any listed ETag strongly matches the current ETag.
+func ifMatch(header string, current model.Etag) bool {
+	tags, wildcard := parseEtags(header)
+	if wildcard {
+		return true
+	}
+
+	for i := range tags {
+		if tags[i].StrongMatch(current) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Evaluates an If-None-Match precondition against the current ETag of a resource, per RFC 7232 §3.2.  Returns true if
+// the precondition holds, i.e. neither is the header the wildcard, nor does any listed ETag weakly match the current
+// ETag.
+func ifNoneMatch(header string, current model.Etag) bool {
+	tags, wildcard := parseEtags(header)
+	if wildcard {
+		return false
+	}
+
+	for i := range tags {
+		if tags[i].WeakMatch(current) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Evaluates an If-Modified-Since precondition against the last modification time of a resource, per RFC 7232 §3.3.
+// Returns true if the precondition holds, i.e. the resource was modified after the date in the header.  Dates that
+// cannot be parsed are ignored, and the precondition holds.
+func ifModifiedSince(header string, lastModified time.Time) bool {
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return true
+	}
+
+	// HTTP dates have a resolution of one second
+	return lastModified.Truncate(time.Second).After(since)
+}
+
+// Evaluates the preconditions of a conditional GET or HEAD request, per RFC 7232 §6.  Returns true if the selected
+// representation, having the supplied ETag and last modification time, has not been modified, and a 304 should be
+// returned in lieu of the representation.
+//
+// If-Modified-Since is only evaluated in the absence of If-None-Match.
+func notModified(r *http.Request, current model.Etag, lastModified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return !ifNoneMatch(header, current)
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" {
+		return !ifModifiedSince(header, lastModified)
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package neg
+
+import (
+	"github.com/emetsger/negtracker/model"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_ParseEtagsWildcard(t *testing.T) {
+	tags, wildcard := parseEtags(" * ")
+	assert.True(t, wildcard)
+	assert.Empty(t, tags)
+}
+
+func Test_ParseEtagsList(t *testing.T) {
+	tags, wildcard := parseEtags(`"abc", W/"def",  "g,h"`)
+	assert.False(t, wildcard)
+	assert.Equal(t, []model.Etag{`"abc"`, `W/"def"`, `"g,h"`}, tags)
+}
+
+func Test_ParseEtagsMalformed(t *testing.T) {
+	tags, _ := parseEtags(`abc, "def", "unterminated`)
+	assert.Equal(t, []model.Etag{`"def"`}, tags)
+}
+
+func Test_IfMatch(t *testing.T) {
+	assert.True(t, ifMatch(`"abc"`, `"abc"`))
+	assert.True(t, ifMatch(`"xyz", "abc"`, `"abc"`))
+	assert.True(t, ifMatch(`*`, `"abc"`))
+	assert.False(t, ifMatch(`"xyz"`, `"abc"`))
+	// weak validators never match using the strong comparison function
+	assert.False(t, ifMatch(`W/"abc"`, `"abc"`))
+	assert.False(t, ifMatch(`W/"abc"`, `W/"abc"`))
+}
+
+func Test_IfNoneMatch(t *testing.T) {
+	assert.False(t, ifNoneMatch(`"abc"`, `"abc"`))
+	assert.False(t, ifNoneMatch(`"xyz", "abc"`, `"abc"`))
+	assert.False(t, ifNoneMatch(`*`, `"abc"`))
+	// weak validators match using the weak comparison function
+	assert.False(t, ifNoneMatch(`W/"abc"`, `"abc"`))
+	assert.False(t, ifNoneMatch(`"abc"`, `W/"abc"`))
+	assert.True(t, ifNoneMatch(`"xyz"`, `"abc"`))
+	assert.True(t, ifNoneMatch(`W/"xyz", "uvw"`, `"abc"`))
+}
+
+func Test_IfModifiedSince(t *testing.T) {
+	modified := time.Date(2020, 9, 12, 14, 30, 15, int(500*time.Millisecond), time.UTC)
+
+	// sub-second precision of the modification time is ignored
+	assert.False(t, ifModifiedSince(modified.Format(http.TimeFormat), modified))
+	assert.False(t, ifModifiedSince(modified.Add(time.Hour).Format(http.TimeFormat), modified))
+	assert.True(t, ifModifiedSince(modified.Add(-time.Second).Format(http.TimeFormat), modified))
+
+	// unparseable dates are ignored
+	assert.True(t, ifModifiedSince("yesterday", modified))
+}
+
+func Test_NotModified(t *testing.T) {
+	neg := &model.Neg{Id: "moo", Created: time.Now(), Updated: time.Now()}
+
+	r, _ := http.NewRequest(http.MethodGet, "/neg/moo", nil)
+	assert.False(t, notModified(r, neg.GetEtag(), neg.Updated))
+
+	r.Header.Set("If-Modified-Since", neg.Updated.Add(time.Minute).Format(http.TimeFormat))
+	assert.True(t, notModified(r, neg.GetEtag(), neg.Updated))
+
+	// If-None-Match takes precedence over If-Modified-Since
+	r.Header.Set("If-None-Match", `"xyz"`)
+	assert.False(t, notModified(r, neg.GetEtag(), neg.Updated))
+
+	r.Header.Set("If-None-Match", string(neg.GetEtag()))
+	assert.True(t, notModified(r, neg.GetEtag(), neg.Updated))
+
+	// the ETag of another representation does not match
+	assert.False(t, notModified(r, neg.GetEtag().Variant("yaml"), neg.Updated))
+}
@@ -0,0 +1,112 @@
+package neg
+
+import (
+	"fmt"
+	"github.com/emetsger/negtracker/hypermedia"
+	"github.com/emetsger/negtracker/model"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Parses the `expand` query parameter: a comma-separated list of the relations of the business object t whose related
+// resources are inlined in its representation, e.g. "roll,scans".  The parameter may be repeated.  Returns nil if the
+// parameter is absent, or a *paramError naming the first relation that t does not declare, or whose related resources
+// are not retrievable by any of the supplied retrievers.
+func parseExpand(params url.Values, t interface{}, retrievers hypermedia.Retrievers) ([]string, error) {
+	values, ok := params["expand"]
+	if !ok {
+		return nil, nil
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return nil, invalidParam("expand", "must be a comma-separated list of relations")
+			}
+			if !seen[name] {
+				names = append(names, name)
+				seen[name] = true
+			}
+		}
+	}
+
+	if err := hypermedia.Unknown(asWebResource(t), names, retrievers); err != nil {
+		return nil, invalidParam("expand", err.Error())
+	}
+
+	return names, nil
+}
+
+// A business object with the related resources inlined in its representation by the `expand` query parameter
+type expanded struct {
+	// a pointer to a model struct, or a sparse business object
+	t interface{}
+	// the inlined resources, keyed by relation
+	embedded map[string][]hypermedia.Resource
+}
+
+// Returns the representation with the supplied related resources inlined, which were expanded from the named relations.
+// An expanded representation carries a weak ETag variant naming the relations and digesting the state of the inlined
+// resources, so that it is not matched once any of them is updated.  Returns the representation as-is if no relations
+// are named, or if it has no hypermedia controls.
+func (rep representation) expand(names []string, embedded map[string][]hypermedia.Resource) representation {
+	if len(names) == 0 || !rep.hypermedia {
+		return rep
+	}
+
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	expandedRep := rep
+	expandedRep.variant = fmt.Sprintf("expand=%s-%s", strings.Join(sorted, ","), hypermedia.Digest(embedded))
+	if rep.variant != "" {
+		expandedRep.variant = rep.variant + ";" + expandedRep.variant
+	}
+	expandedRep.marshal = func(t interface{}, uri string) ([]byte, error) {
+		return rep.marshal(expanded{t: t, embedded: embedded}, uri)
+	}
+	return expandedRep
+}
+
+// Returns the business object t, identified by the supplied URI, with the hypermedia controls of its representation
+func linked(t interface{}, uri string) hypermedia.Resource {
+	res := hypermedia.Resource{Value: t, Self: uri}
+	if e, ok := t.(expanded); ok {
+		res.Value, res.Embedded = e.t, e.embedded
+	}
+	res.Of = asWebResource(res.Value)
+	return res
+}
+
+// Returns each of the business objects in the slice pointed to by t with the hypermedia controls of its representation.
+// Each is identified by its id within the collection identified by the supplied URI.  If fields are supplied, each is
+// restricted to them.
+func linkedAll(t interface{}, collection string, fields []string) []hypermedia.Resource {
+	restricted := sparseAll(t, fields)
+	result := make([]hypermedia.Resource, len(restricted))
+	for i := range restricted {
+		var value interface{} = restricted[i]
+		if len(fields) == 0 {
+			value = restricted[i].t
+		}
+		e := asWebResource(value)
+		result[i] = hypermedia.Resource{Value: value, Of: e, Self: collection + "/" + url.PathEscape(e.GetId())}
+	}
+	return result
+}
+
+// Returns the business object t, which may be restricted to a subset of its fields, as a model.WebResource
+func asWebResource(t interface{}) model.WebResource {
+	if s, ok := t.(sparse); ok {
+		t = s.t
+	}
+	e, ok := t.(model.WebResource)
+	if !ok {
+		panic(fmt.Sprintf("handler/neg: unable to link entity, unhandled type %T", t))
+	}
+	return e
+}
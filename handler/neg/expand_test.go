@@ -0,0 +1,117 @@
+package neg
+
+import (
+	"encoding/json"
+	"github.com/emetsger/negtracker/hypermedia"
+	"github.com/emetsger/negtracker/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A contact sheet, relating to the Negs printed on it
+type contactSheet struct {
+	Id      string
+	Created time.Time
+	Updated time.Time
+	Negs    []string
+}
+
+func (c *contactSheet) GetId() string          { return c.Id }
+func (c *contactSheet) GetCreated() time.Time  { return c.Created }
+func (c *contactSheet) GetUpdated() time.Time  { return c.Updated }
+func (c *contactSheet) GetEtag() model.Etag    { return model.Etag("\"" + c.Id + "\"") }
+func (c *contactSheet) SetId(id string)        { c.Id = id }
+func (c *contactSheet) SetCreated(t time.Time) { c.Created = t }
+func (c *contactSheet) SetUpdated(t time.Time) { c.Updated = t }
+func (c *contactSheet) GetRelations() []model.Relation {
+	return []model.Relation{
+		{Name: "negs", Collection: "/neg", Ids: c.Negs, New: func() model.WebResource { return &model.Neg{} }},
+	}
+}
+
+// The hypermedia controls of a JSON representation
+type controls struct {
+	Links    map[string]interface{}              `json:"_links"`
+	Embedded map[string][]map[string]interface{} `json:"_embedded"`
+}
+
+func Test_GetExpand(t *testing.T) {
+	s := memStore(t, &contactSheet{Id: "sheet", Negs: []string{"moo", "missing"}}, &represented)
+	getSheet := func(query, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/sheet/sheet?"+query, nil)
+		r.Header.Set("Accept", accept)
+		c := (&Config{Related: hypermedia.Retrievers{"/neg": s.Retrieve}}).withDefaults()
+		get(w, r, s, "sheet", &contactSheet{}, c).ServeHTTP(w, r)
+		return w
+	}
+
+	w := getSheet("", "application/json")
+	require.Equal(t, 200, w.Code)
+	doc := controls{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"href": "/neg/moo"},
+		map[string]interface{}{"href": "/neg/missing"},
+	}, doc.Links["negs"])
+	assert.Nil(t, doc.Embedded)
+	assert.Equal(t, `"sheet"`, w.Header().Get("ETag"))
+
+	w = getSheet("expand=negs", "application/json")
+	require.Equal(t, 200, w.Code)
+	doc = controls{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	// resources that do not exist are linked, but not inlined
+	require.Len(t, doc.Embedded["negs"], 1)
+	assert.Equal(t, "Tri-X", doc.Embedded["negs"][0]["Film"])
+	assert.Equal(t, map[string]interface{}{"href": "/neg/moo"},
+		doc.Embedded["negs"][0]["_links"].(map[string]interface{})["self"])
+
+	// the expanded representation does not match the unexpanded one
+	expanded := model.Etag(w.Header().Get("ETag"))
+	assert.True(t, strings.HasPrefix(string(expanded), `W/"sheet-expand=negs-`))
+
+	// nor once an inlined resource is updated
+	updated := represented
+	updated.Updated = updated.Updated.Add(time.Second)
+	require.Nil(t, s.Update("moo", &updated))
+	assert.NotEqual(t, string(expanded), getSheet("expand=negs", "application/json").Header().Get("ETag"))
+
+	w = getSheet("expand=negs", "application/yaml")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "_embedded:\n")
+
+	w = getSheet("expand=rolls", "application/json")
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown relation 'rolls'")
+
+	// a relation is not expanded without a retriever of its collection
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/sheet/sheet?expand=negs", nil)
+	get(w, r, s, "sheet", &contactSheet{}, (&Config{}).withDefaults()).ServeHTTP(w, r)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "relation 'negs' cannot be expanded")
+}
+
+func Test_GetExpandNeg(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/neg/moo?expand=roll", nil)
+	NewHandler(memStore(t, &represented), nil)(w, r)
+
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown relation 'roll'")
+}
+
+func Test_DecodeRepresentation(t *testing.T) {
+	// a retrieved representation may be submitted as-is
+	body := getAs(t, "application/json").Body.Bytes()
+	n := &model.Neg{}
+	violations, err := decode(body, n)
+	require.Nil(t, err)
+	assert.Empty(t, violations)
+	assert.Equal(t, represented, *n)
+}
@@ -0,0 +1,158 @@
+package neg
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/media"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Newline-delimited JSON: a Neg per line
+	NdjsonMediaType = "application/x-ndjson"
+	// Comma-separated values: a header row naming the fields of a Neg, followed by a row per Neg
+	CsvMediaType = "text/csv"
+)
+
+// The number of Negs written between flushes of an export
+const exportFlushInterval = 100
+
+// The columns of the CSV representation of a Neg
+var csvColumns = []string{
+	"Id", "Created", "Updated", "Film", "EI", "Developer", "FrameNumber", "Tags", "Description", "Format",
+}
+
+// The separator of the tags of a Neg within a CSV field
+const csvTagSeparator = ";"
+
+// Returns an http.HandlerFunc capable of exporting every Neg selected by the query parameters of the request, as
+// newline-delimited JSON or CSV chosen by the Accept header.  The query parameters are those supported by listing,
+// except that every selected Neg is exported: limit and cursor are ignored.
+//
+// Negs are streamed from the storage layer as they are written, so the export of a large catalog is not held in memory.
+// If the storage layer fails once the response has begun, the connection is aborted so the client does not mistake a
+// truncated export for a complete one.
+func export(w http.ResponseWriter, r *http.Request, s store.Api) http.HandlerFunc {
+	mediaType := media.Negotiate(r.Header.Get("Accept"), NdjsonMediaType, CsvMediaType)
+	if mediaType == "" {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.NotAcceptable(w, r, NdjsonMediaType, CsvMediaType)
+		}
+	}
+
+	q, err := parseQuery(r.URL.Query())
+	var perr *paramError
+	if errors.As(err, &perr) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.InvalidParams(w, r, perr.Error(), []handler.FieldError{perr.FieldError})
+		}
+	}
+
+	it, err := s.Iterate(q)
+	if err != nil {
+		return storageFailed(err)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() { _ = it.Close() }()
+
+		w.Header().Set("Content-Type", mediaType)
+		w.Header().Set("Vary", "Accept")
+		w.WriteHeader(200)
+
+		if err := write(w, it, mediaType); err != nil {
+			log.Printf("handler/neg: export aborted: %v", err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// Writes every Neg selected by the listing query parameters in params to w, as the supplied media type: NdjsonMediaType
+// or CsvMediaType.  Negs are streamed from the storage layer as they are written.
+func Export(w io.Writer, s store.Api, params url.Values, mediaType string) error {
+	if mediaType != NdjsonMediaType && mediaType != CsvMediaType {
+		return fmt.Errorf("handler/neg: unable to export as %s", mediaType)
+	}
+
+	q, err := parseQuery(params)
+	if err != nil {
+		return err
+	}
+
+	it, err := s.Iterate(q)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = it.Close() }()
+
+	return write(w, it, mediaType)
+}
+
+// Writes each Neg visited by the iterator to w as the supplied media type.  If w is an http.Flusher, it is flushed
+// periodically so the client receives the export as it is produced.
+func write(w io.Writer, it store.Iterator, mediaType string) error {
+	var encode func(n *model.Neg) error
+	var flush func() error
+
+	if mediaType == CsvMediaType {
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvColumns); err != nil {
+			return err
+		}
+		encode = func(n *model.Neg) error { return cw.Write(csvRecord(n)) }
+		flush = func() error { cw.Flush(); return cw.Error() }
+	} else {
+		enc := json.NewEncoder(w)
+		encode = func(n *model.Neg) error { return enc.Encode(n) }
+		flush = func() error { return nil }
+	}
+
+	count := 0
+	for n := (model.Neg{}); it.Next(&n); n = (model.Neg{}) {
+		if err := encode(&n); err != nil {
+			return err
+		}
+
+		if count++; count%exportFlushInterval == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	}
+
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// Returns the fields of the CSV representation of the Neg, in the order of csvColumns
+func csvRecord(n *model.Neg) []string {
+	return []string{
+		n.Id,
+		n.Created.UTC().Format(time.RFC3339Nano),
+		n.Updated.UTC().Format(time.RFC3339Nano),
+		n.Film,
+		strconv.Itoa(n.EI),
+		n.Developer,
+		n.FrameNumber,
+		strings.Join(n.Tags, csvTagSeparator),
+		n.Description,
+		n.Format,
+	}
+}
@@ -0,0 +1,85 @@
+package neg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var exported = []model.Neg{
+	{Id: "moo", Created: time.Date(2020, 1, 2, 3, 4, 5, 6e6, time.UTC), Film: "Tri-X", EI: 400,
+		Tags: []string{"a", "b"}},
+	{Id: "cow", Created: time.Date(2020, 1, 3, 3, 4, 5, 6e6, time.UTC), Film: "HP5", Description: "comma, \"quote\""},
+}
+
+// Returns a configured store.Api holding the exported Negs
+func exportStore(t *testing.T) store.Api {
+	return memStore(t, &exported[0], &exported[1])
+}
+
+func Test_ExportNdjson(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewHandler(exportStore(t), nil)(w, httptest.NewRequest("GET", "/neg/_export", nil))
+
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, NdjsonMediaType, w.Header().Get("Content-Type"))
+
+	var negs []model.Neg
+	dec := json.NewDecoder(w.Body)
+	for dec.More() {
+		n := model.Neg{}
+		require.Nil(t, dec.Decode(&n))
+		negs = append(negs, n)
+	}
+	assert.Equal(t, exported, negs)
+
+	// the export is filtered as a listing is, but is not paginated
+	w = httptest.NewRecorder()
+	NewHandler(exportStore(t), nil)(w, httptest.NewRequest("GET", "/neg/_export?film=HP5&limit=1", nil))
+	n := model.Neg{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &n))
+	assert.Equal(t, exported[1], n)
+}
+
+func Test_ExportCsv(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/neg/_export", nil)
+	r.Header.Set("Accept", "text/csv")
+	NewHandler(exportStore(t), nil)(w, r)
+
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, CsvMediaType, w.Header().Get("Content-Type"))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.Nil(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, csvColumns, records[0])
+	assert.Equal(t, []string{"moo", "2020-01-02T03:04:05.006Z", "0001-01-01T00:00:00Z", "Tri-X", "400", "", "", "a;b",
+		"", ""}, records[1])
+	assert.Equal(t, "comma, \"quote\"", records[2][8])
+}
+
+func Test_ExportNotAcceptable(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/neg/_export", nil)
+	r.Header.Set("Accept", "application/xml")
+	NewHandler(memStore(t), nil)(w, r)
+	assert.Equal(t, 406, w.Code)
+}
+
+func Test_Export(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.Nil(t, Export(buf, exportStore(t), url.Values{}, CsvMediaType))
+	assert.Contains(t, buf.String(), "Tri-X")
+
+	assert.NotNil(t, Export(buf, memStore(t), url.Values{}, "application/xml"))
+	assert.NotNil(t, Export(buf, memStore(t), url.Values{"sort": {"moo"}}, NdjsonMediaType))
+}
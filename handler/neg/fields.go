@@ -0,0 +1,156 @@
+package neg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Fields of a Neg that may be selected by the `fields` query parameter, keyed by their lower-cased name
+var selectableFields = map[string]string{
+	"id":          "Id",
+	"created":     "Created",
+	"updated":     "Updated",
+	"film":        "Film",
+	"ei":          "EI",
+	"developer":   "Developer",
+	"framenumber": "FrameNumber",
+	"tags":        "Tags",
+	"description": "Description",
+	"format":      "Format",
+}
+
+// Fields of a business object that are retrieved regardless of the `fields` query parameter, as the validators of its
+// representation are derived from them
+var validatorFields = []string{"Id", "Created", "Updated"}
+
+// Parses the `fields` query parameter: a comma-separated list of the names of the fields to include in the
+// representation of a Neg, e.g. "Film,EI,Tags".  Names are case-insensitive, and the parameter may be repeated.
+// Returns nil if the parameter is absent, or a *paramError naming the first unknown field.
+func parseFields(params url.Values) ([]string, error) {
+	values, ok := params["fields"]
+	if !ok {
+		return nil, nil
+	}
+
+	var fields []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			field, ok := selectableFields[strings.ToLower(name)]
+			if !ok {
+				if name == "" {
+					return nil, invalidParam("fields", "must be a comma-separated list of field names")
+				}
+				return nil, invalidParam("fields", fmt.Sprintf("unknown field '%s'", name))
+			}
+			if !seen[field] {
+				fields = append(fields, field)
+				seen[field] = true
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+// Returns the fields to retrieve from the storage layer in order to represent the supplied fields of a business object:
+// the fields themselves, and those the validators of the representation are derived from.
+func retrievedFields(fields []string) []string {
+	return append(append([]string{}, validatorFields...), fields...)
+}
+
+// A business object restricted to a subset of its fields, i.e. a sparse fieldset.  The JSON representation of a
+// sparse business object has a member for each field of the subset, in the order the fields are declared, and no
+// others.
+type sparse struct {
+	// a pointer to a model struct
+	t interface{}
+	// the names of the fields in the subset, as declared by the model struct
+	fields []string
+}
+
+// Restricts each of the business objects in the slice pointed to by t to the supplied fields
+func sparseAll(t interface{}, fields []string) []sparse {
+	slice := reflect.ValueOf(t).Elem()
+	result := make([]sparse, slice.Len())
+	for i := range result {
+		result[i] = sparse{t: slice.Index(i).Addr().Interface(), fields: fields}
+	}
+	return result
+}
+
+func (s sparse) includes(field string) bool {
+	for i := range s.fields {
+		if s.fields[i] == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (s sparse) MarshalJSON() ([]byte, error) {
+	v := reflect.Indirect(reflect.ValueOf(s.t))
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if !s.includes(name) {
+			continue
+		}
+
+		value, err := json.Marshal(v.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Returns a copy of the business object in which the fields outside of the subset have their zero value, for
+// representations that are not made up of the fields of the business object, e.g. JSON-LD.
+func (s sparse) zeroed() interface{} {
+	v := reflect.Indirect(reflect.ValueOf(s.t))
+	copied := reflect.New(v.Type())
+	for i := 0; i < v.NumField(); i++ {
+		if s.includes(v.Type().Field(i).Name) {
+			copied.Elem().Field(i).Set(v.Field(i))
+		}
+	}
+	return copied.Interface()
+}
+
+// Returns the representation restricted to the supplied fields of the business object.  A restricted representation
+// carries a weak ETag variant naming the fields, so that representations of different subsets of the same state do not
+// match each other, or the unrestricted representation.  Returns the representation as-is if no fields are supplied.
+func (rep representation) restrict(fields []string) representation {
+	if len(fields) == 0 {
+		return rep
+	}
+
+	sorted := append([]string{}, fields...)
+	sort.Strings(sorted)
+
+	restricted := rep
+	restricted.variant = "fields=" + strings.Join(sorted, ",")
+	if rep.variant != "" {
+		restricted.variant = rep.variant + ";" + restricted.variant
+	}
+	restricted.marshal = func(t interface{}, uri string) ([]byte, error) {
+		return rep.marshal(sparse{t: t, fields: fields}, uri)
+	}
+	return restricted
+}
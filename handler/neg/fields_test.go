@@ -0,0 +1,107 @@
+package neg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// A store.Api that records the fields it is asked to retrieve, and the query it is asked to list
+type projectingRecorder struct {
+	*memory.MemoryStore
+	fields []string
+	query  store.Query
+}
+
+func (s *projectingRecorder) RetrieveFields(id string, fields []string, t interface{}) error {
+	s.fields = fields
+	return s.MemoryStore.RetrieveFields(id, fields, t)
+}
+
+func (s *projectingRecorder) List(q store.Query, t interface{}) (string, error) {
+	s.query = q
+	return s.MemoryStore.List(q, t)
+}
+
+func Test_ParseFields(t *testing.T) {
+	fields, err := parseFields(url.Values{})
+	require.Nil(t, err)
+	assert.Nil(t, fields)
+
+	params, _ := url.ParseQuery("fields=film,EI,Film&fields=tags")
+	fields, err = parseFields(params)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Film", "EI", "Tags"}, fields)
+
+	for query, reason := range map[string]string{
+		"fields=Film,Speed": "unknown field 'Speed'",
+		"fields=":           "must be a comma-separated list of field names",
+		"fields=Film,,EI":   "must be a comma-separated list of field names",
+	} {
+		params, _ := url.ParseQuery(query)
+		_, err := parseFields(params)
+		require.NotNil(t, err, query)
+		assert.Equal(t, reason, err.(*paramError).Reason, query)
+	}
+}
+
+func Test_SparseMarshal(t *testing.T) {
+	body, err := json.Marshal(sparse{t: &represented, fields: []string{"Tags", "Film", "EI"}})
+	require.Nil(t, err)
+	assert.Equal(t, `{"Film":"Tri-X","EI":400,"Tags":["400","true"]}`, string(body))
+
+	zeroed := sparse{t: &represented, fields: []string{"Film"}}.zeroed().(*model.Neg)
+	assert.Equal(t, model.Neg{Film: "Tri-X"}, *zeroed)
+}
+
+func Test_GetFields(t *testing.T) {
+	s := &projectingRecorder{MemoryStore: memStore(t, &represented)}
+	get := func(query, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/neg/moo?"+query, nil)
+		r.Header.Set("Accept", accept)
+		NewHandler(s, nil)(w, r)
+		return w
+	}
+
+	w := get("fields=Film,EI", "application/json")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"Film":"Tri-X","EI":400,"_links":{"collection":{"href":"/neg"},"self":{"href":"/neg/moo"}}}`,
+		w.Body.String())
+	assert.Equal(t, []string{"Id", "Created", "Updated", "Film", "EI"}, s.fields)
+
+	full := represented.GetEtag()
+	projected := model.Etag(w.Header().Get("ETag"))
+	assert.Equal(t, full.Variant("fields=EI,Film"), projected)
+	assert.False(t, projected.WeakMatch(full))
+
+	// the ETag does not depend on the order the fields are named in
+	assert.Equal(t, string(projected), get("fields=ei,film", "application/json").Header().Get("ETag"))
+	assert.Equal(t, string(full.Variant("csv;fields=EI,Film")), get("fields=EI,Film", "text/csv").Header().Get("ETag"))
+	assert.Equal(t, "Film,EI\nTri-X,400\n", get("fields=EI,Film", "text/csv").Body.String())
+
+	w = get("fields=Film,Speed", "application/json")
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown field 'Speed'")
+}
+
+func Test_ListFields(t *testing.T) {
+	other := represented
+	other.Id = "quack"
+	s := &projectingRecorder{MemoryStore: memStore(t, &represented, &other)}
+	w := httptest.NewRecorder()
+	NewHandler(s, nil)(w, httptest.NewRequest("GET", "/neg?fields=Id,Film", nil))
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, []string{"Id", "Film"}, s.query.Fields)
+	links := `"_links":{"collection":{"href":"/neg"},"self":{"href":"/neg/%s"}}`
+	assert.Equal(t, fmt.Sprintf(`[{"Id":"moo","Film":"Tri-X",`+links+`},{"Id":"quack","Film":"Tri-X",`+links+`}]`,
+		"moo", "quack"), w.Body.String())
+}
@@ -0,0 +1,177 @@
+package neg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/urlutil/strip"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// The number of Negs in a page when the request does not specify a limit
+	defaultLimit = 50
+	// The maximum number of Negs in a page
+	maxLimit = 500
+)
+
+// Query parameters that select Negs whose field is equal to the parameter value
+var eqParams = map[string]string{
+	"film":      "Film",
+	"developer": "Developer",
+	"format":    "Format",
+}
+
+// Query parameters that select Negs whose field is within a range, and a function parsing the parameter value
+var rangeParams = map[string]struct {
+	field string
+	op    store.Operator
+	parse func(string) (interface{}, error)
+}{
+	"ei_min":      {"EI", store.Gte, parseInt},
+	"ei_max":      {"EI", store.Lte, parseInt},
+	"created_min": {"Created", store.Gte, parseTime},
+	"created_max": {"Created", store.Lte, parseTime},
+	"updated_min": {"Updated", store.Gte, parseTime},
+	"updated_max": {"Updated", store.Lte, parseTime},
+}
+
+// Fields of a Neg that may be used to sort a listing, keyed by their lower-cased name
+var sortFields = map[string]string{
+	"id":          "Id",
+	"film":        "Film",
+	"developer":   "Developer",
+	"format":      "Format",
+	"ei":          "EI",
+	"framenumber": "FrameNumber",
+	"created":     "Created",
+	"updated":     "Updated",
+}
+
+// Returns an http.HandlerFunc capable of listing a page of business objects selected by the query parameters of the
+// request.  The business objects are unmarshaled to `t`, which must be a pointer to a slice of model structs, and are
+// written to the response as a JSON array.  Each carries its links, per the JSON representation of an individual
+// business object; related resources are not inlined in a listing.  If there is a following page, a Link header with
+// the relation "next" is written.
+//
+// Supported query parameters:
+//   film, developer, format: select Negs with the given value
+//   tag: select Negs having the given tag; may be repeated, in which case Negs must have every tag
+//   ei_min, ei_max: select Negs with an EI in the inclusive range
+//   created_min, created_max, updated_min, updated_max: select Negs in the inclusive range of RFC 3339 times
+//   sort: the field to sort by, e.g. "Film"; prefix with "-" to sort descending.  Defaults to "Created".
+//   limit: the maximum number of Negs in the page, defaults to 50
+//   cursor: the opaque cursor of the page, obtained from a Link header
+//   fields: a comma-separated list of the fields of each Neg to include, e.g. "Film,EI,Tags"; defaults to every field
+func list(w http.ResponseWriter, r *http.Request, s store.Api, t interface{}) (h http.HandlerFunc) {
+	q, err := parseQuery(r.URL.Query())
+	if err == nil {
+		q.Fields, err = parseFields(r.URL.Query())
+	}
+	var perr *paramError
+	if errors.As(err, &perr) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.InvalidParams(w, r, perr.Error(), []handler.FieldError{perr.FieldError})
+		}
+	}
+
+	next, err := s.List(q, t)
+	if err != nil {
+		return storageFailed(err)
+	}
+
+	body, err := json.Marshal(linkedAll(t, strip.TrailingSlashes(r.URL.Path), q.Fields))
+	if err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.ServerError(w, r)
+		}
+	}
+
+	if next != "" {
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextPage(r.URL, next)))
+	}
+
+	return wrap(body, 200, "application/json", r, w)
+}
+
+// A query parameter that could not be parsed
+type paramError struct {
+	handler.FieldError
+}
+
+func (e *paramError) Error() string {
+	return fmt.Sprintf("Invalid value for '%s': %s", e.Name, e.Reason)
+}
+
+func invalidParam(name, reason string) *paramError {
+	return &paramError{handler.FieldError{Name: name, Reason: reason}}
+}
+
+// Parses the query parameters of a listing request into a store.Query.  A *paramError describing the offending
+// parameter is returned if a parameter is malformed.
+func parseQuery(params url.Values) (store.Query, error) {
+	q := store.Query{Limit: defaultLimit, Sort: store.Sort{Field: "Created"}}
+
+	for param, field := range eqParams {
+		if value := params.Get(param); value != "" {
+			q.Criteria = append(q.Criteria, store.Criterion{Field: field, Op: store.Eq, Value: value})
+		}
+	}
+
+	for _, tag := range params["tag"] {
+		q.Criteria = append(q.Criteria, store.Criterion{Field: "Tags", Op: store.Contains, Value: tag})
+	}
+
+	for param, p := range rangeParams {
+		if value := params.Get(param); value != "" {
+			parsed, err := p.parse(value)
+			if err != nil {
+				return q, invalidParam(param, err.Error())
+			}
+			q.Criteria = append(q.Criteria, store.Criterion{Field: p.field, Op: p.op, Value: parsed})
+		}
+	}
+
+	if value := params.Get("sort"); value != "" {
+		descending := strings.HasPrefix(value, "-")
+		field, ok := sortFields[strings.ToLower(strings.TrimPrefix(value, "-"))]
+		if !ok {
+			return q, invalidParam("sort", fmt.Sprintf("unknown field '%s'", value))
+		}
+		q.Sort = store.Sort{Field: field, Descending: descending}
+	}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return q, invalidParam("limit", fmt.Sprintf("must be an integer between 1 and %d", maxLimit))
+		}
+		q.Limit = limit
+	}
+
+	q.Cursor = params.Get("cursor")
+
+	return q, nil
+}
+
+// Returns the URI of the following page: the URI of the current page, with the cursor of the following page
+func nextPage(current *url.URL, cursor string) string {
+	params := current.Query()
+	params.Set("cursor", cursor)
+	next := url.URL{Path: current.Path, RawQuery: params.Encode()}
+	return next.String()
+}
+
+func parseInt(value string) (interface{}, error) {
+	return strconv.Atoi(value)
+}
+
+func parseTime(value string) (interface{}, error) {
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,49 @@
+package neg
+
+import (
+	"github.com/emetsger/negtracker/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_ParseQueryDefaults(t *testing.T) {
+	q, err := parseQuery(url.Values{})
+	require.Nil(t, err)
+	assert.Equal(t, store.Query{Limit: defaultLimit, Sort: store.Sort{Field: "Created"}}, q)
+}
+
+func Test_ParseQuery(t *testing.T) {
+	params, _ := url.ParseQuery("film=Tri-X&tag=spring&tag=daffodil&ei_min=200&created_max=2020-09-12T14:30:15Z" +
+		"&sort=-ei&limit=10&cursor=moo")
+
+	q, err := parseQuery(params)
+	require.Nil(t, err)
+
+	assert.Equal(t, store.Sort{Field: "EI", Descending: true}, q.Sort)
+	assert.Equal(t, 10, q.Limit)
+	assert.Equal(t, "moo", q.Cursor)
+	assert.ElementsMatch(t, []store.Criterion{
+		{Field: "Film", Op: store.Eq, Value: "Tri-X"},
+		{Field: "Tags", Op: store.Contains, Value: "spring"},
+		{Field: "Tags", Op: store.Contains, Value: "daffodil"},
+		{Field: "EI", Op: store.Gte, Value: 200},
+		{Field: "Created", Op: store.Lte, Value: time.Date(2020, 9, 12, 14, 30, 15, 0, time.UTC)},
+	}, q.Criteria)
+}
+
+func Test_ParseQueryMalformed(t *testing.T) {
+	for _, query := range []string{"ei_min=moo", "updated_min=yesterday", "sort=Description", "limit=0",
+		"limit=100000", "limit=moo"} {
+		params, _ := url.ParseQuery(query)
+		_, err := parseQuery(params)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func Test_NextPage(t *testing.T) {
+	current, _ := url.Parse("/neg?film=Tri-X&cursor=abc&limit=10")
+	assert.Equal(t, "/neg?cursor=def&film=Tri-X&limit=10", nextPage(current, "def"))
+}
@@ -0,0 +1,81 @@
+package neg
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/hypermedia"
+	"github.com/emetsger/negtracker/media"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/urlutil/strip"
+	"net/http"
+	"path"
+)
+
+// The request body of a multi-get, which lists at most as many ids as a page of a listing may hold
+type mgetRequest struct {
+	// The business ids of the business objects to retrieve
+	Ids []string `json:"ids" validate:"min=1,max=500"`
+}
+
+// The response body of a multi-get
+type mgetResult struct {
+	// The business objects that were found, in the order their ids were requested
+	Docs []mgetDoc `json:"docs"`
+	// The requested ids of business objects that do not exist, or have been deleted
+	Missing []string `json:"missing"`
+}
+
+// A business object found by a multi-get
+type mgetDoc struct {
+	// The business id of the business object
+	Id string `json:"id"`
+	// The ETag of the JSON representation of the business object, as a GET of the business object would carry
+	Etag string `json:"etag"`
+	// The JSON representation of the business object
+	Doc hypermedia.Resource `json:"doc"`
+}
+
+// Returns an http.HandlerFunc capable of retrieving each of the business objects whose ids are listed by the JSON
+// request body in the supplied buffer, e.g. `{"ids": ["a", "b"]}`, using a single call to the storage layer.  The
+// business objects are unmarshaled to `t`, which must be a pointer to a slice of model structs.
+//
+// The response lists the business objects that were found, each in its JSON representation with its ETag, and the ids
+// of those that were not.  An id that is listed more than once is retrieved once.
+func mget(w http.ResponseWriter, r *http.Request, buf *bytes.Buffer, s store.Api, t interface{}) http.HandlerFunc {
+	if media.Negotiate(r.Header.Get("Accept"), defaultRepresentation.mediaType) == "" {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.NotAcceptable(w, r, defaultRepresentation.mediaType)
+		}
+	}
+
+	req := &mgetRequest{}
+	if invalid := unmarshal(buf.Bytes(), req); invalid != nil {
+		return invalid
+	} else if invalid := validated(req); invalid != nil {
+		return invalid
+	}
+
+	missing, err := s.RetrieveMany(req.Ids, t)
+	if err != nil {
+		return storageFailed(err)
+	}
+
+	found := linkedAll(t, path.Dir(strip.TrailingSlashes(r.URL.Path)), nil)
+	result := mgetResult{Docs: make([]mgetDoc, len(found)), Missing: missing}
+	for i := range found {
+		result.Docs[i] = mgetDoc{Id: found[i].Of.GetId(), Etag: string(found[i].Of.GetEtag()), Doc: found[i]}
+	}
+	if result.Missing == nil {
+		result.Missing = []string{}
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.ServerError(w, r)
+		}
+	}
+
+	return wrap(body, 200, defaultRepresentation.mediaType, r, w)
+}
@@ -0,0 +1,93 @@
+package neg
+
+import (
+	"encoding/json"
+	"github.com/emetsger/negtracker/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A store.Api that records the ids of each call to RetrieveMany
+type manyRetriever struct {
+	*memory.MemoryStore
+	calls [][]string
+}
+
+func (s *manyRetriever) RetrieveMany(ids []string, t interface{}) ([]string, error) {
+	s.calls = append(s.calls, ids)
+	return s.MemoryStore.RetrieveMany(ids, t)
+}
+
+func Test_Mget(t *testing.T) {
+	other := represented
+	other.Id = "quack"
+	s := &manyRetriever{MemoryStore: memStore(t, &represented, &other)}
+
+	w := httptest.NewRecorder()
+	NewHandler(s, nil)(w, httptest.NewRequest("POST", "/neg/_mget",
+		strings.NewReader(`{"ids": ["quack", "oink", "moo", "quack"]}`)))
+
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, [][]string{{"quack", "oink", "moo", "quack"}}, s.calls)
+
+	result := struct {
+		Docs []struct {
+			Id   string
+			Etag string
+			Doc  map[string]interface{}
+		}
+		Missing []string
+	}{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, []string{"oink"}, result.Missing)
+	require.Len(t, result.Docs, 2)
+	assert.Equal(t, "quack", result.Docs[0].Id)
+	assert.Equal(t, string(other.GetEtag()), result.Docs[0].Etag)
+	assert.Equal(t, "moo", result.Docs[1].Id)
+	assert.Equal(t, string(represented.GetEtag()), result.Docs[1].Etag)
+	assert.Equal(t, "Tri-X", result.Docs[1].Doc["Film"])
+	assert.Equal(t, map[string]interface{}{"href": "/neg/moo"},
+		result.Docs[1].Doc["_links"].(map[string]interface{})["self"])
+}
+
+func Test_MgetNoneMissing(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewHandler(&manyRetriever{MemoryStore: memStore(t, &represented)}, nil)(w,
+		httptest.NewRequest("POST", "/neg/_mget", strings.NewReader(`{"ids": ["moo"]}`)))
+
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"missing":[]`)
+}
+
+func Test_MgetInvalid(t *testing.T) {
+	tooMany := make([]string, 501)
+	for i := range tooMany {
+		tooMany[i] = `"moo"`
+	}
+
+	for body, status := range map[string]int{
+		``:                400,
+		`{"ids": "moo"}`:  422,
+		`{"ids": []}`:     422,
+		`{"id": ["moo"]}`: 422,
+		`["moo"]`:         422,
+		`{"ids": ["moo"]`: 400,
+		`{"ids": [` + strings.Join(tooMany, ",") + `]}`: 422,
+	} {
+		s := &manyRetriever{MemoryStore: memStore(t)}
+		w := httptest.NewRecorder()
+		NewHandler(s, nil)(w, httptest.NewRequest("POST", "/neg/_mget", strings.NewReader(body)))
+		assert.Equal(t, status, w.Code, body)
+		assert.Empty(t, s.calls, body)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/neg/_mget", strings.NewReader(`{"ids": ["moo"]}`))
+	r.Header.Set("Accept", "text/csv")
+	NewHandler(&manyRetriever{MemoryStore: memStore(t)}, nil)(w, r)
+	assert.Equal(t, 406, w.Code)
+}
@@ -2,17 +2,20 @@ package neg
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/hypermedia"
 	"github.com/emetsger/negtracker/id"
+	"github.com/emetsger/negtracker/idempotency"
 	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/router"
 	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/urlutil/strip"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -32,117 +35,568 @@ var negHandler = func(w http.ResponseWriter, r *http.Request) {
 	h.ServeHTTP(w, r)
 }
 
-func NewHandler(s store.Api) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+// The Cache-Control policy used when none is configured: caches may store representations, but must revalidate them
+// (e.g. with a conditional GET) before each use.
+const DefaultCacheControl = "no-cache"
+
+// Represents the configuration of the handler returned by NewHandler
+type Config struct {
+	// Value of the Cache-Control header sent with representations of business objects, e.g. "private, max-age=60".
+	// If empty, DefaultCacheControl is used.
+	CacheControl string
+	// The time responses to POST requests bearing an Idempotency-Key header are retained, so that retries of the
+	// request are answered with the original response rather than creating another business object.  If zero,
+	// idempotency.DefaultTTL is used.
+	IdempotencyTTL time.Duration
+	// The maximum number of responses retained for retries bearing an Idempotency-Key header; the oldest is discarded
+	// once it is exceeded.  If zero, idempotency.DefaultMaxEntries is used.
+	IdempotencyMaxEntries int
+	// The retrievers of the resources related to business objects, which are inlined in their representations by the
+	// `expand` query parameter, keyed by the URI of the collection the resources belong to, e.g. "/roll".  Relations to
+	// a collection without a retriever are linked, but cannot be expanded.
+	Related hypermedia.Retrievers
+}
+
+// Returns a copy of the supplied configuration, with defaults applied to fields that have not been set.  The supplied
+// configuration may be nil.
+func (c *Config) withDefaults() *Config {
+	result := Config{}
+	if c != nil {
+		result = *c
+	}
+
+	if result.CacheControl == "" {
+		result.CacheControl = DefaultCacheControl
+	}
+
+	if result.IdempotencyTTL == 0 {
+		result.IdempotencyTTL = idempotency.DefaultTTL
+	}
+
+	if result.IdempotencyMaxEntries == 0 {
+		result.IdempotencyMaxEntries = idempotency.DefaultMaxEntries
+	}
+
+	return &result
+}
+
+// Returns an http.HandlerFunc serving the collection of Negs at "/neg", and individual Negs at "/neg/{id}".
+func NewHandler(s store.Api, c *Config) http.HandlerFunc {
+	rt := router.New()
+	Routes(rt.Sub("/neg"), s, c)
+	return rt.ServeHTTP
+}
+
+// Registers the routes of the collection of Negs ("/"), its export ("/_export"), bulk import ("/_bulk") and multi-get
+// ("/_mget"), and of individual Negs ("/{id}") with the supplied router, which is expected to be a sub-router, e.g.
+// rt.Sub("/neg").
+func Routes(rt *router.Router, s store.Api, c *Config) {
+	c = c.withDefaults()
+
+	rt.HandleFunc(http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) {
+		negs := &[]model.Neg{}
+		list(w, r, s, negs).ServeHTTP(w, r)
+	})
+
+	// retries of a create bearing an Idempotency-Key are answered with the original response
+	create := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var h http.HandlerFunc
-		switch r.Method {
-		case http.MethodGet:
-			if id := parseIdFromUri(r.URL.String()); id == "" {
-				// id could not be parsed from the URI
-				h = func(w http.ResponseWriter, r *http.Request) {
-					handler.MalformedRequest(w, r, "Malformed request")
-				}
-			} else {
-				neg := &model.Neg{}
-				h = get(w, r, s, id, neg)
+		buf := &bytes.Buffer{}
+		_, _ = io.Copy(buf, r.Body)
+		if buf.Len() < 1 {
+			// no request body, nothing to create
+			h = func(w http.ResponseWriter, r *http.Request) {
+				handler.MalformedRequest(w, r, "Malformed request")
 			}
-		case http.MethodPost:
-			buf := &bytes.Buffer{}
-			_, _ = io.Copy(buf, r.Body)
-			if buf.Len() < 1 {
-				// no request body, nothing to create
-				h = func(w http.ResponseWriter, r *http.Request) {
-					handler.MalformedRequest(w, r, "Malformed request")
-				}
-			} else {
-				n := &model.Neg{}
-				h = post(w, r, buf, n, s)
+		} else {
+			n := &model.Neg{}
+			h = post(w, r, buf, n, s)
+		}
+		h.ServeHTTP(w, r)
+	})
+	rt.Handle(http.MethodPost, "/", idempotency.Handler(idempotency.NewCache(c.IdempotencyTTL, c.IdempotencyMaxEntries), create))
+
+	rt.HandleFunc(http.MethodOptions, "/", options(true))
+
+	rt.HandleFunc(http.MethodGet, "/_export", func(w http.ResponseWriter, r *http.Request) {
+		export(w, r, s).ServeHTTP(w, r)
+	})
+
+	rt.HandleFunc(http.MethodPost, "/_bulk", func(w http.ResponseWriter, r *http.Request) {
+		bulk(w, r, s, func() interface{} { return &model.Neg{} }).ServeHTTP(w, r)
+	})
+
+	rt.HandleFunc(http.MethodPost, "/_mget", func(w http.ResponseWriter, r *http.Request) {
+		var h http.HandlerFunc
+		buf := &bytes.Buffer{}
+		_, _ = io.Copy(buf, r.Body)
+		if buf.Len() < 1 {
+			// no ids to retrieve
+			h = func(w http.ResponseWriter, r *http.Request) {
+				handler.MalformedRequest(w, r, "Malformed request")
 			}
-		default:
+		} else {
+			h = mget(w, r, buf, s, &[]model.Neg{})
+		}
+		h.ServeHTTP(w, r)
+	})
+
+	rt.HandleFunc(http.MethodGet, "/{id}", func(w http.ResponseWriter, r *http.Request) {
+		neg := &model.Neg{}
+		get(w, r, s, router.Param(r, "id"), neg, c).ServeHTTP(w, r)
+	})
+
+	rt.HandleFunc(http.MethodPut, "/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var h http.HandlerFunc
+		buf := &bytes.Buffer{}
+		_, _ = io.Copy(buf, r.Body)
+		if buf.Len() < 1 {
+			// there is no replacement state
 			h = func(w http.ResponseWriter, r *http.Request) {
-				handler.NotImplemented(w, r)
+				handler.MalformedRequest(w, r, "Malformed request")
 			}
+		} else {
+			h = put(w, r, buf, s, router.Param(r, "id"), &model.Neg{}, &model.Neg{})
 		}
+		h.ServeHTTP(w, r)
+	})
 
+	rt.HandleFunc(http.MethodPatch, "/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var h http.HandlerFunc
+		buf := &bytes.Buffer{}
+		_, _ = io.Copy(buf, r.Body)
+		if buf.Len() < 1 {
+			// there is no patch document
+			h = func(w http.ResponseWriter, r *http.Request) {
+				handler.MalformedRequest(w, r, "Malformed request")
+			}
+		} else {
+			h = modify(w, r, buf, s, router.Param(r, "id"), &model.Neg{}, &model.Neg{})
+		}
 		h.ServeHTTP(w, r)
-	}
+	})
+
+	rt.HandleFunc(http.MethodDelete, "/{id}", func(w http.ResponseWriter, r *http.Request) {
+		del(w, r, s, router.Param(r, "id")).ServeHTTP(w, r)
+	})
+
+	rt.HandleFunc(http.MethodOptions, "/{id}", options(false))
 }
 
+// Returns an http.HandlerFunc capable of creating a business object from the state in the supplied buffer.  The response
+// carries the Location and validators of the created business object.
+//
+// The body of the response depends on the return preference of the request (RFC 7240):
+//   return=representation: the created business object, in the representation selected by the Accept header
+//   return=minimal: no body
+//   otherwise: the identifier of the created business object, as text/plain
+//
+// When a preference is applied, the response varies by the Prefer header (RFC 7240 §2) as well as the Accept header.
 func post(w http.ResponseWriter, r *http.Request, buf *bytes.Buffer, t interface{}, s store.Api) (h http.HandlerFunc) {
-	if err := json.Unmarshal(buf.Bytes(), t); err != nil {
-		// malformed body
+	preference := parsePrefer(r.Header.Get("Prefer"))["return"]
+	rep, acceptable := defaultRepresentation, true
+	if preference == "representation" {
+		rep, acceptable = negotiate(r.Header.Get("Accept"))
+	}
+
+	if !acceptable {
 		h = func(w http.ResponseWriter, r *http.Request) {
-			handler.MalformedRequest(w, r, "Malformed request")
+			handler.NotAcceptable(w, r, availableMediaTypes()...)
 		}
+	} else if invalid := unmarshal(buf.Bytes(), t); invalid != nil {
+		// malformed body, or unknown fields
+		h = invalid
+	} else if invalid := validated(t); invalid != nil {
+		h = invalid
 	} else {
-		if e, ok := t.(model.WebResource); ok == true {
-			if e.GetId() == "" {
-				e.SetId(id.Mint())
-			}
-			e.SetCreated(time.Now())
-			e.SetUpdated(time.Now())
+		e, ok := t.(model.WebResource)
+		if !ok {
+			panic(fmt.Sprintf("handler/neg: unable to determine id of created entity, unhandled type %T", t))
+		}
+		if e.GetId() == "" {
+			e.SetId(id.Mint())
 		}
+		created := now()
+		e.SetCreated(created)
+		e.SetUpdated(created)
+
 		if _, err := s.Store(t); err != nil {
 			// error storing the neg
-			if errors.Is(err, store.DuplicateKeyErr) {
-				h = func(w http.ResponseWriter, r *http.Request) {
-					handler.Conflict(w, r, err.Error())
+			h = storageFailed(err)
+		} else {
+			w.Header().Set("Location", strip.TrailingSlashes(r.URL.Path)+"/"+url.PathEscape(e.GetId()))
+			setValidators(w, e, rep)
+
+			switch preference {
+			case "representation":
+				if body, err := rep.marshal(t, w.Header().Get("Location")); err != nil {
+					h = func(w http.ResponseWriter, r *http.Request) {
+						handler.ServerError(w, r)
+					}
+				} else {
+					w.Header().Set("Content-Location", w.Header().Get("Location"))
+					w.Header().Set("Preference-Applied", "return="+preference)
+					w.Header().Set("Vary", "Accept, Prefer")
+					h = wrap(body, 201, rep.mediaType, r, w)
 				}
-			} else {
+			case "minimal":
+				w.Header().Set("Preference-Applied", "return="+preference)
+				w.Header().Set("Vary", "Accept, Prefer")
 				h = func(w http.ResponseWriter, r *http.Request) {
-					handler.ServerError(w, r)
+					w.WriteHeader(201)
 				}
-			}
-		} else {
-			// return a 201 TODO decide on id approach
-			if e, ok := t.(model.WebResource); ok == true {
+			default:
 				h = wrap([]byte(e.GetId()), 201, "text/plain", r, w)
-			} else {
-				panic(fmt.Sprintf("handler/neg: unable to determine id of created entity, unhandled type %T", t))
 			}
+		}
+	}
+	return h
+}
+
+// Returns an http.HandlerFunc capable of replacing the business object specified by id with the state in the supplied
+// buffer, or of creating the business object with that state if it does not exist.
+//
+// Replacing an existing business object requires an If-Match header matching the ETag of its current state.  Creating
+// a business object requires the absence of If-Match, which cannot match a business object that does not exist.  A
+// request bearing `If-None-Match: *` only creates: if the business object exists it is not replaced, and a 412 results.
+// A business object that has been deleted is neither replaced nor created.
+//
+// The existing business object is retrieved into `current`, and the replacement state is unmarshaled into `t`; both
+// must be pointers to the same model type.  The creation time of a replaced business object is preserved, and its
+// update time is moved forward.
+//
+// Requests are processed under the lock of the BusinessId of the business object, so that concurrent requests for the
+// same business object are applied in turn: of racing requests creating the same business object, the first creates it
+// and the others are evaluated against its state.
+func put(w http.ResponseWriter, r *http.Request, buf *bytes.Buffer, s store.Api, resId string, current,
+	t interface{}) (h http.HandlerFunc) {
+	bid := id.GetId(resId, current)
+	bid.Lock()
+	defer bid.Unlock()
+
+	precondition := r.Header.Get("If-Match")
+	err := s.Retrieve(resId, current)
+	if store.CodeOf(err) == store.CodeNotFound {
+		if precondition != "" {
+			return func(w http.ResponseWriter, r *http.Request) {
+				handler.PreconditionFailed(w, r, "If-Match header does not match, the resource does not exist")
+			}
+		}
+		return create(w, r, buf, s, resId, t)
+	} else if err != nil {
+		return storageFailed(err)
+	}
+
+	existing, ok := current.(model.WebResource)
+	if !ok {
+		panic(fmt.Sprintf("handler/neg: unable to determine etag of existing entity, unhandled type %T", current))
+	}
+
+	if header := r.Header.Get("If-None-Match"); header != "" && !ifNoneMatch(header, existing.GetEtag()) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.PreconditionFailed(w, r, "If-None-Match header matches the current ETag")
+		}
+	}
+
+	if precondition == "" {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.PreconditionRequired(w, r, "If-Match header is required")
+		}
+	}
 
+	if !ifMatch(precondition, existing.GetEtag()) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.PreconditionFailed(w, r, "If-Match header does not match the current ETag")
 		}
 	}
+
+	if invalid := unmarshal(buf.Bytes(), t); invalid != nil {
+		// malformed body, or unknown fields
+		return invalid
+	}
+
+	return update(w, r, s, resId, existing, t)
+}
+
+// Returns an http.HandlerFunc capable of creating the business object specified by id with the state in the supplied
+// buffer, which is unmarshaled into `t`.  The created business object is written to the response along with its
+// Location and validators, in the representation selected by the Accept header.
+//
+// If the state is invalid, or none of the representations is acceptable, a 4xx is written and the business object is
+// not created.
+func create(w http.ResponseWriter, r *http.Request, buf *bytes.Buffer, s store.Api, resId string,
+	t interface{}) (h http.HandlerFunc) {
+	if invalid := unmarshal(buf.Bytes(), t); invalid != nil {
+		// malformed body, or unknown fields
+		return invalid
+	}
+
+	e, ok := t.(model.WebResource)
+	if !ok {
+		panic(fmt.Sprintf("handler/neg: unable to create entity, unhandled type %T", t))
+	}
+
+	if e.GetId() != "" && e.GetId() != resId {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.MalformedRequest(w, r, "Id in request body does not match the request URI")
+		}
+	}
+
+	// the id is validated along with the state, whether or not the body carries it
+	e.SetId(resId)
+	if invalid := validated(t); invalid != nil {
+		return invalid
+	}
+
+	w.Header().Set("Vary", "Accept")
+	rep, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.NotAcceptable(w, r, availableMediaTypes()...)
+		}
+	}
+
+	created := now()
+	e.SetCreated(created)
+	e.SetUpdated(created)
+
+	uri := strip.TrailingSlashes(r.URL.Path)
+	if _, err := s.Store(t); err != nil {
+		h = storageFailed(err)
+	} else if body, err := rep.marshal(t, uri); err != nil {
+		h = func(w http.ResponseWriter, r *http.Request) {
+			handler.ServerError(w, r)
+		}
+	} else {
+		w.Header().Set("Location", uri)
+		w.Header().Set("Content-Location", uri)
+		setValidators(w, e, rep)
+		h = wrap(body, 201, rep.mediaType, r, w)
+	}
+
 	return h
 }
 
-// Returns an http.HandlerFunc capable of retrieving the business object specified by id and type from the storage
-// layer.  The business object is marshaled to JSON, and written to the response.
-func get(w http.ResponseWriter, r *http.Request, s store.Api, id string, t interface{}) (h http.HandlerFunc) {
-	if err := s.Retrieve(id, t); err != nil {
+// Returns an http.HandlerFunc capable of durably persisting `t` as the new state of the business object specified by
+// id.  The identifier and creation time of the existing business object are carried over to `t`, and its update time is
+// moved forward.  The updated business object is written to the response along with its ETag, in the representation
+// selected by the Accept header.
+//
+// If `t` violates the constraints of its model type, or none of its representations is acceptable, a 422 or 406 is
+// written and the business object is not updated.
+func update(w http.ResponseWriter, r *http.Request, s store.Api, id string, existing model.WebResource,
+	t interface{}) (h http.HandlerFunc) {
+	replacement, ok := t.(model.WebResource)
+	if !ok {
+		panic(fmt.Sprintf("handler/neg: unable to update entity, unhandled type %T", t))
+	}
+
+	if replacement.GetId() != "" && replacement.GetId() != id {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.MalformedRequest(w, r, "Id in request body does not match the request URI")
+		}
+	}
+
+	if invalid := validated(t); invalid != nil {
+		return invalid
+	}
+
+	w.Header().Set("Vary", "Accept")
+	rep, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.NotAcceptable(w, r, availableMediaTypes()...)
+		}
+	}
+
+	replacement.SetId(id)
+	replacement.SetCreated(existing.GetCreated())
+	replacement.SetUpdated(after(existing.GetUpdated()))
+
+	if err := s.Update(id, t); err != nil {
+		h = storageFailed(err)
+	} else if body, err := rep.marshal(t, strip.TrailingSlashes(r.URL.Path)); err != nil {
 		h = func(w http.ResponseWriter, r *http.Request) {
 			handler.ServerError(w, r)
 		}
 	} else {
-		if body, err := json.Marshal(t); err != nil {
-			h = func(w http.ResponseWriter, r *http.Request) {
-				handler.ServerError(w, r)
-			}
-		} else {
-			if e, ok := t.(model.WebResource); ok == true {
-				w.Header().Add("ETag", string(e.GetEtag()))
+		setValidators(w, replacement, rep)
+		h = wrap(body, 200, rep.mediaType, r, w)
+	}
+
+	return h
+}
+
+// Returns an http.HandlerFunc capable of retrieving the business object specified by id and type from the storage
+// layer.  The business object is written to the response in the representation selected by the Accept header, or a
+// 406 is written if none of its representations is acceptable.  The business object must exist for a 406 to result.
+//
+// Conditional requests are supported: if the If-None-Match or If-Modified-Since preconditions of the request do not
+// hold, a 304 is written in lieu of the business object.
+//
+// The `fields` query parameter restricts the representation to the named fields, e.g. `?fields=Film,EI,Tags`, which
+// are the only fields retrieved from the storage layer besides those the validators are derived from.  The `expand`
+// query parameter inlines the resources related to the business object by the named relations, e.g. `?expand=roll`,
+// in representations carrying hypermedia controls; see hypermedia.Expand.  Related resources are retrieved by the
+// retrievers of Config.Related, rather than from s.
+func get(w http.ResponseWriter, r *http.Request, s store.Api, id string, t interface{}, c *Config) (h http.HandlerFunc) {
+	w.Header().Set("Vary", "Accept")
+
+	fields, err := parseFields(r.URL.Query())
+	var names []string
+	if err == nil {
+		names, err = parseExpand(r.URL.Query(), t, c.Related)
+	}
+	var perr *paramError
+	if errors.As(err, &perr) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.InvalidParams(w, r, perr.Error(), []handler.FieldError{perr.FieldError})
+		}
+	}
+
+	// the related resources of a business object are linked from each of its representations, so every field of a
+	// business object having relations is retrieved
+	if len(fields) > 0 && hypermedia.Relations(asWebResource(t)) == nil {
+		err = s.RetrieveFields(id, retrievedFields(fields), t)
+	} else {
+		err = s.Retrieve(id, t)
+	}
+
+	if err != nil {
+		return storageFailed(err)
+	}
+
+	rep, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.NotAcceptable(w, r, availableMediaTypes()...)
+		}
+	}
+
+	uri := strip.TrailingSlashes(r.URL.Path)
+	e := asWebResource(t)
+	if len(names) > 0 && rep.hypermedia {
+		embedded, err := hypermedia.Expand(e, uri, names, c.Related)
+		var ferr *hypermedia.FanOutError
+		if errors.As(err, &ferr) {
+			perr := invalidParam("expand", ferr.Error())
+			return func(w http.ResponseWriter, r *http.Request) {
+				handler.InvalidParams(w, r, perr.Error(), []handler.FieldError{perr.FieldError})
 			}
-			h = wrap(body, 200, "application/json", r, w)
+		} else if err != nil {
+			return storageFailed(err)
+		}
+		rep = rep.expand(names, embedded)
+	}
+
+	rep = rep.restrict(fields)
+	body, err := rep.marshal(t, uri)
+	if err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.ServerError(w, r)
+		}
+	}
+
+	w.Header().Set("Cache-Control", c.CacheControl)
+	setValidators(w, e, rep)
+	if notModified(r, rep.etag(e.GetEtag()), e.GetUpdated()) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(304)
+		}
+	}
+	return wrap(body, 200, rep.mediaType, r, w)
+}
+
+// Sets the validators of the representation of the business object on the response: its ETag, and the time the
+// business object was last modified.
+func setValidators(w http.ResponseWriter, e model.WebResource, rep representation) {
+	w.Header().Set("ETag", string(rep.etag(e.GetEtag())))
+	w.Header().Set("Last-Modified", e.GetUpdated().UTC().Format(http.TimeFormat))
+}
+
+// Returns an http.HandlerFunc capable of deleting the business object specified by id.  The business object is
+// retained by the storage layer as a tombstone, so subsequent requests for it result in a 410.
+func del(w http.ResponseWriter, r *http.Request, s store.Api, id string) (h http.HandlerFunc) {
+	if err := s.Delete(id); err != nil {
+		h = storageFailed(err)
+	} else {
+		h = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(204)
 		}
 	}
 	return h
 }
 
-// TODO: test, e.g., when the parsed id is not valid, things panic in the store layer, and empty response is returned
-func parseIdFromUri(uri string) string {
-	index := strings.LastIndex(uri, "/")
-	if index > -1 && index < len(uri) {
-		return uri[index+1:]
+// The number of seconds a client is advised to wait before retrying a request that failed with a temporary storage
+// error
+const retryAfter = 5
+
+// Returns an http.HandlerFunc that responds to an error returned by the storage layer, choosing the status code by the
+// code of the error.  Temporary errors result in a 503, so that clients may retry.
+func storageFailed(err error) http.HandlerFunc {
+	switch store.CodeOf(err) {
+	case store.CodeDeleted:
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.Gone(w, r)
+		}
+	case store.CodeNotFound:
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.NotFound(w, r)
+		}
+	case store.CodeDuplicateKey:
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.Conflict(w, r, "A resource with the same id already exists")
+		}
+	case store.CodeInvalidQuery:
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.MalformedRequest(w, r, "Invalid query")
+		}
 	}
 
-	return ""
+	if store.Temporary(err) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.ServiceUnavailable(w, r, retryAfter)
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler.ServerError(w, r)
+	}
+}
+
+// Returns an http.HandlerFunc describing the media types of the request bodies accepted by the target resource, in the
+// Accept-Post (collection) or Accept-Patch (individual Neg) header.  The router describes the methods it supports in the
+// Allow header.
+func options(collection bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if collection {
+			w.Header().Set("Accept-Post", "application/json")
+		} else {
+			w.Header().Set("Accept-Patch", acceptPatch)
+		}
+		w.WriteHeader(204)
+	}
+}
+
+// Returns the current UTC time, truncated to the millisecond precision retained by the storage layer.  Timestamps
+// that are set with full precision would produce ETags that differ once the business object is retrieved.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// Returns the current time per now(), guaranteed to be strictly after the supplied time.  ETags are derived from
+// update times, so an update within the same millisecond as its predecessor must still move the update time forward.
+func after(previous time.Time) time.Time {
+	if t := now(); t.After(previous) {
+		return t
+	}
+	return previous.Add(time.Millisecond)
 }
 
 func wrap(body []byte, status int, mediaType string, r *http.Request, w http.ResponseWriter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
 		w.Header().Set("Content-Type", mediaType)
-		// TODO: need to add Location header for POST but not have it for GET
 		if status > 199 && status < 600 {
 			w.WriteHeader(status)
 		}
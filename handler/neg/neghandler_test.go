@@ -0,0 +1,107 @@
+package neg
+
+import (
+	"context"
+	"errors"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+)
+
+// Returns a configured store.Api holding the supplied business objects
+func memStore(t *testing.T, objs ...model.WebResource) *memory.MemoryStore {
+	s := &memory.MemoryStore{}
+	s.Configure(nil)
+	for i := range objs {
+		_, err := s.Store(objs[i])
+		require.Nil(t, err)
+	}
+	return s
+}
+
+// Returns the Neg with the supplied id, which must exist in the store
+func retrieved(t *testing.T, s store.Api, id string) model.Neg {
+	n := model.Neg{}
+	require.Nil(t, s.Retrieve(id, &n))
+	return n
+}
+
+func Test_RoutesId(t *testing.T) {
+	s := &retrieveRecorder{MemoryStore: memStore(t)}
+	h := NewHandler(s, nil)
+
+	for uri, id := range map[string]string{
+		"/neg/moo":       "moo",
+		"/neg/moo/":      "moo",
+		"/neg/moo?x=1":   "moo",
+		"/neg/m%2Foo%20": "m/oo ",
+	} {
+		h(httptest.NewRecorder(), httptest.NewRequest("GET", uri, nil))
+		assert.Equal(t, id, s.id, uri)
+	}
+}
+
+// A store.Api that records the id of the last retrieval
+type retrieveRecorder struct {
+	*memory.MemoryStore
+	id string
+}
+
+func (s *retrieveRecorder) Retrieve(id string, t interface{}) error {
+	s.id = id
+	return s.MemoryStore.Retrieve(id, t)
+}
+
+func Test_StorageFailed(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+	}{
+		{store.SentinelErr(store.DeletedErr, "", nil), 410},
+		{store.SentinelErr(store.NotFoundErr, "", nil), 404},
+		{store.SentinelErr(store.DuplicateKeyErr, "", nil), 409},
+		{store.SentinelErr(store.InvalidQueryErr, "", nil), 400},
+		{store.SentinelErr(store.UnavailableErr, "", nil), 503},
+		{store.GenericErr("", context.DeadlineExceeded), 503},
+		{store.SentinelErr(store.DecodingErr, "", nil), 500},
+		{store.GenericErr("", errors.New("driver error")), 500},
+	} {
+		w := httptest.NewRecorder()
+		storageFailed(tc.err)(w, httptest.NewRequest("GET", "/neg/moo", nil))
+		assert.Equal(t, tc.status, w.Code, "error: %v", tc.err)
+	}
+}
+
+func Test_MethodNotAllowed(t *testing.T) {
+	h := NewHandler(nil, nil)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("TRACE", "/neg", nil))
+	assert.Equal(t, 405, w.Code)
+	assert.Equal(t, "GET, HEAD, POST, OPTIONS", w.Header().Get("Allow"))
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest("POST", "/neg/moo", nil))
+	assert.Equal(t, 405, w.Code)
+	assert.Equal(t, "GET, HEAD, PUT, PATCH, DELETE, OPTIONS", w.Header().Get("Allow"))
+}
+
+func Test_Options(t *testing.T) {
+	h := NewHandler(nil, nil)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("OPTIONS", "/neg/", nil))
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, "GET, HEAD, POST, OPTIONS", w.Header().Get("Allow"))
+	assert.Equal(t, "application/json", w.Header().Get("Accept-Post"))
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest("OPTIONS", "/neg/moo", nil))
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, "GET, HEAD, PUT, PATCH, DELETE, OPTIONS", w.Header().Get("Allow"))
+	assert.Equal(t, acceptPatch, w.Header().Get("Accept-Patch"))
+}
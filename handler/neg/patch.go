@@ -0,0 +1,119 @@
+package neg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/id"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/patch"
+	"github.com/emetsger/negtracker/store"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJsonPatch  = "application/json-patch+json"
+)
+
+// Value of the Accept-Patch header, advertising the supported patch document media types
+var acceptPatch = strings.Join([]string{mediaTypeMergePatch, mediaTypeJsonPatch}, ", ")
+
+// Returns an http.HandlerFunc capable of modifying the business object specified by resId by applying the patch
+// document in the supplied buffer.  The patch document must be a JSON Merge Patch (RFC 7396) or a JSON Patch
+// (RFC 6902), as indicated by the Content-Type of the request.  If the request carries an If-Match header, it must
+// match the ETag of the current state of the business object.
+//
+// The read-modify-write cycle is performed while holding the lock of the id.BusinessId for the business object, so
+// concurrent modifications are serialized.  The existing business object is retrieved into `current`, and the patched
+// state is unmarshaled into `t`; both must be pointers to the same model type.
+func modify(w http.ResponseWriter, r *http.Request, buf *bytes.Buffer, s store.Api, resId string, current,
+	t interface{}) (h http.HandlerFunc) {
+	var apply func(doc, patch []byte) ([]byte, error)
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case err == nil && mediaType == mediaTypeMergePatch:
+		apply = patch.Merge
+	case err == nil && mediaType == mediaTypeJsonPatch:
+		apply = patch.Apply
+	default:
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Accept-Patch", acceptPatch)
+			handler.UnsupportedMediaType(w, r, fmt.Sprintf("Content-Type must be one of %s", acceptPatch))
+		}
+	}
+
+	bid := id.GetId(resId, current)
+	bid.Lock()
+	defer bid.Unlock()
+
+	if err := s.Retrieve(resId, current); err != nil {
+		return storageFailed(err)
+	}
+
+	existing, ok := current.(model.WebResource)
+	if !ok {
+		panic(fmt.Sprintf("handler/neg: unable to determine etag of existing entity, unhandled type %T", current))
+	}
+
+	if precondition := r.Header.Get("If-Match"); precondition != "" && !ifMatch(precondition, existing.GetEtag()) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.PreconditionFailed(w, r, "If-Match header does not match the current ETag")
+		}
+	}
+
+	var doc, patched []byte
+	if doc, err = json.Marshal(current); err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.ServerError(w, r)
+		}
+	}
+
+	if patched, err = apply(doc, buf.Bytes()); err != nil {
+		return patchFailed(err)
+	}
+
+	if invalid := unmarshal(patched, t); invalid != nil {
+		// the patch produced a document that cannot be represented by the model type
+		return invalid
+	}
+
+	return update(w, r, s, resId, existing, t)
+}
+
+// Returns an http.HandlerFunc that reports the failure to apply a patch document.  JSON Patch failures are reported as a
+// problem whose extension members identify the offending operation: a failed `test` operation results in a 409, an
+// operation that cannot be applied to the business object results in a 422, and a malformed patch document results in a
+// 400.
+func patchFailed(err error) http.HandlerFunc {
+	var perr *patch.Error
+	if !errors.As(err, &perr) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.MalformedRequest(w, r, err.Error())
+		}
+	}
+
+	p := handler.NewProblem(400, "malformed-patch", perr.Reason)
+	switch {
+	case errors.Is(err, patch.TestFailedErr):
+		p = handler.NewProblem(409, "patch-test-failed", perr.Reason)
+	case errors.Is(err, patch.UnprocessableErr):
+		p = handler.NewProblem(422, "unprocessable-patch", perr.Reason)
+	}
+
+	p.With("index", perr.Index)
+	if perr.Op != "" {
+		p.With("op", perr.Op)
+	}
+	if perr.Path != "" {
+		p.With("path", perr.Path)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler.WriteProblem(w, r, p)
+	}
+}
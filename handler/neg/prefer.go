@@ -0,0 +1,32 @@
+package neg
+
+import (
+	"strings"
+)
+
+// Parses the value of a Prefer header (RFC 7240 §2) into a map of preference names to values.  Preference names are
+// lower-cased, and preferences without a value map to the empty string.  Parameters of preferences are discarded, and if
+// a preference is repeated, the first occurrence wins.
+func parsePrefer(header string) map[string]string {
+	preferences := map[string]string{}
+
+	for _, member := range strings.Split(header, ",") {
+		// discard the parameters of the preference
+		member = strings.TrimSpace(strings.SplitN(member, ";", 2)[0])
+		if member == "" {
+			continue
+		}
+
+		name, value := member, ""
+		if i := strings.Index(member, "="); i > -1 {
+			name, value = member[:i], strings.Trim(strings.TrimSpace(member[i+1:]), `"`)
+		}
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, exists := preferences[name]; !exists {
+			preferences[name] = value
+		}
+	}
+
+	return preferences
+}
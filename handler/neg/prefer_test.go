@@ -0,0 +1,13 @@
+package neg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_ParsePrefer(t *testing.T) {
+	assert.Equal(t, map[string]string{}, parsePrefer(""))
+	assert.Equal(t, map[string]string{"return": "minimal"}, parsePrefer("return=minimal"))
+	assert.Equal(t, map[string]string{"return": "representation", "respond-async": "", "wait": "10"},
+		parsePrefer(`Return="representation"; foo=bar, respond-async, wait=10, return=minimal`))
+}
@@ -0,0 +1,112 @@
+package neg
+
+import (
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func putNeg(s store.Api, id, body string, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/neg/"+id, strings.NewReader(body))
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	NewHandler(s, nil)(w, r)
+	return w
+}
+
+func Test_PutCreate(t *testing.T) {
+	s := memStore(t)
+
+	w := putNeg(s, "moo", `{"Film":"Tri-X"}`, nil)
+	require.Equal(t, 201, w.Code)
+	assert.Equal(t, "/neg/moo", w.Header().Get("Location"))
+	assert.Equal(t, "/neg/moo", w.Header().Get("Content-Location"))
+
+	created := retrieved(t, s, "moo")
+	assert.Equal(t, "moo", created.Id)
+	assert.Equal(t, "Tri-X", created.Film)
+	assert.False(t, created.Created.IsZero())
+	assert.Equal(t, created.Created, created.Updated)
+	assert.Equal(t, string(created.GetEtag()), w.Header().Get("ETag"))
+
+	// once it exists, replacing it requires a precondition
+	assert.Equal(t, 428, putNeg(s, "moo", `{"Film":"HP5"}`, nil).Code)
+	assert.Equal(t, created, retrieved(t, s, "moo"))
+
+	// ids colliding with the sub-resources of the collection are rejected, whether or not the body carries them
+	for _, body := range []string{`{"Film":"Tri-X"}`, `{"Id":"_foo","Film":"Tri-X"}`} {
+		assert.Equal(t, 422, putNeg(s, "_foo", body, nil).Code, body)
+	}
+	assert.Equal(t, store.CodeNotFound, store.CodeOf(s.Retrieve("_foo", &model.Neg{})))
+}
+
+func Test_PutCreateIfNoneMatch(t *testing.T) {
+	s := memStore(t)
+
+	only := map[string]string{"If-None-Match": "*"}
+	require.Equal(t, 201, putNeg(s, "moo", `{"Film":"Tri-X"}`, only).Code)
+
+	w := putNeg(s, "moo", `{"Film":"HP5"}`, only)
+	assert.Equal(t, 412, w.Code)
+	assert.Equal(t, "Tri-X", retrieved(t, s, "moo").Film)
+
+	// a request matching the current state, but also requiring there to be none, does not replace it
+	existing := retrieved(t, s, "moo")
+	both := map[string]string{"If-None-Match": "*", "If-Match": string(existing.GetEtag())}
+	assert.Equal(t, 412, putNeg(s, "moo", `{"Film":"HP5"}`, both).Code)
+	assert.Equal(t, "Tri-X", retrieved(t, s, "moo").Film)
+}
+
+func Test_PutCreateInvalid(t *testing.T) {
+	s := memStore(t)
+
+	// a precondition on the state of a resource that does not exist cannot match
+	assert.Equal(t, 412, putNeg(s, "moo", `{"Film":"Tri-X"}`, map[string]string{"If-Match": "*"}).Code)
+	assert.Equal(t, 400, putNeg(s, "moo", `{"Id":"oink","Film":"Tri-X"}`, nil).Code)
+	assert.Equal(t, 400, putNeg(s, "moo", `{"Film":`, nil).Code)
+	assert.Equal(t, 406, putNeg(s, "moo", `{"Film":"Tri-X"}`, map[string]string{"Accept": "image/png"}).Code)
+	assert.Equal(t, store.CodeNotFound, store.CodeOf(s.Retrieve("moo", &model.Neg{})))
+}
+
+func Test_PutDeleted(t *testing.T) {
+	s := memStore(t)
+	require.Equal(t, 201, putNeg(s, "moo", `{"Film":"Tri-X"}`, nil).Code)
+	created := retrieved(t, s, "moo")
+	etag := string(created.GetEtag())
+	require.Nil(t, s.Delete("moo"))
+
+	// a deleted Neg is neither replaced nor created anew
+	assert.Equal(t, 410, putNeg(s, "moo", `{"Film":"HP5"}`, map[string]string{"If-Match": etag}).Code)
+	assert.Equal(t, 410, putNeg(s, "moo", `{"Film":"HP5"}`, nil).Code)
+	assert.Equal(t, store.CodeDeleted, store.CodeOf(s.Retrieve("moo", &model.Neg{})))
+}
+
+func Test_PutCreateConcurrently(t *testing.T) {
+	s := memStore(t)
+
+	const creators = 10
+	codes := make(chan int, creators)
+	wg := sync.WaitGroup{}
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- putNeg(s, "moo", `{"Film":"Tri-X"}`, map[string]string{"If-None-Match": "*"}).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	assert.Equal(t, map[int]int{201: 1, 412: creators - 1}, counts)
+}
@@ -0,0 +1,204 @@
+package neg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/emetsger/negtracker/media"
+	"github.com/emetsger/negtracker/model"
+	"gopkg.in/yaml.v3"
+	"time"
+)
+
+// A serialization of a business object in a particular media type
+type representation struct {
+	// The media type of the representation, sent as the Content-Type of responses
+	mediaType string
+	// The name of the ETag variant of the representation, or empty if the representation carries the ETag of the
+	// business object itself
+	variant string
+	// Serializes the business object, which is identified by the supplied URI
+	marshal func(t interface{}, uri string) ([]byte, error)
+	// Whether the representation carries hypermedia controls, i.e. links to related resources, which may be inlined
+	hypermedia bool
+}
+
+// The representations of a Neg, from the most to the least preferred by the server.
+//
+// The JSON representation is the one accepted by PUT and PATCH, so it carries the strong ETag of the Neg, which may be
+// used in an If-Match precondition.  Every other representation carries a weak variant of the strong ETag.
+//
+// The JSON and YAML representations carry hypermedia controls: the `_links` and `_embedded` members of package
+// hypermedia.
+var representations = []representation{
+	{mediaType: "application/json", marshal: marshalJson, hypermedia: true},
+	{mediaType: "application/ld+json", variant: "jsonld", marshal: marshalJsonLd},
+	{mediaType: "application/yaml", variant: "yaml", marshal: marshalYaml, hypermedia: true},
+	{mediaType: "text/csv", variant: "csv", marshal: marshalCsv},
+}
+
+// The representation of a business object when the request does not state a preference, e.g. after creation
+var defaultRepresentation = representations[0]
+
+// Selects the representation most preferred by the Accept header, per media.Negotiate.  The returned boolean is false
+// if no representation is acceptable.
+func negotiate(accept string) (representation, bool) {
+	offers := make([]string, len(representations))
+	for i := range representations {
+		offers[i] = representations[i].mediaType
+	}
+
+	selected := media.Negotiate(accept, offers...)
+	for i := range representations {
+		if representations[i].mediaType == selected {
+			return representations[i], true
+		}
+	}
+
+	return representation{}, false
+}
+
+// Returns the media types of the representations, for describing them to a client that accepts none of them
+func availableMediaTypes() []string {
+	available := make([]string, len(representations))
+	for i := range representations {
+		available[i] = representations[i].mediaType
+	}
+	return available
+}
+
+// Returns the ETag of this representation of a business object having the supplied ETag
+func (rep representation) etag(e model.Etag) model.Etag {
+	if rep.variant == "" {
+		return e
+	}
+	return e.Variant(rep.variant)
+}
+
+func marshalJson(t interface{}, uri string) ([]byte, error) {
+	return json.Marshal(linked(t, uri))
+}
+
+// A Neg described using the terms of the schema.org Photograph type
+type photograph struct {
+	Context      string          `json:"@context"`
+	Type         string          `json:"@type"`
+	Id           string          `json:"@id"`
+	Identifier   string          `json:"identifier"`
+	DateCreated  string          `json:"dateCreated,omitempty"`
+	DateModified string          `json:"dateModified,omitempty"`
+	Material     string          `json:"material,omitempty"`
+	Position     string          `json:"position,omitempty"`
+	Description  string          `json:"description,omitempty"`
+	Keywords     []string        `json:"keywords,omitempty"`
+	Properties   []propertyValue `json:"additionalProperty,omitempty"`
+}
+
+// A characteristic of a Neg that has no corresponding schema.org term, described as a schema.org PropertyValue
+type propertyValue struct {
+	Type  string      `json:"@type"`
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// Serializes a Neg as JSON-LD, using schema.org Photograph terms: the film stock is the `material` of the photograph,
+// and the frame number its `position`.  The EI, developer, and format of the Neg are described as additional
+// properties.
+func marshalJsonLd(t interface{}, uri string) ([]byte, error) {
+	n := asNeg(t)
+	p := photograph{
+		Context:      "https://schema.org",
+		Type:         "Photograph",
+		Id:           uri,
+		Identifier:   n.Id,
+		DateCreated:  ldTime(n.Created),
+		DateModified: ldTime(n.Updated),
+		Material:     n.Film,
+		Position:     n.FrameNumber,
+		Description:  n.Description,
+		Keywords:     n.Tags,
+	}
+
+	if n.EI != 0 {
+		p.Properties = append(p.Properties, propertyValue{"PropertyValue", "EI", n.EI})
+	}
+	if n.Developer != "" {
+		p.Properties = append(p.Properties, propertyValue{"PropertyValue", "Developer", n.Developer})
+	}
+	if n.Format != "" {
+		p.Properties = append(p.Properties, propertyValue{"PropertyValue", "Format", n.Format})
+	}
+
+	return json.Marshal(p)
+}
+
+func ldTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Serializes a business object as YAML.  Field names and their order are those of the JSON representation, as are its
+// hypermedia controls.
+func marshalYaml(t interface{}, uri string) ([]byte, error) {
+	data, err := json.Marshal(linked(t, uri))
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is YAML in flow style; parse it and re-emit it in block style
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	blockStyle(&doc)
+
+	return yaml.Marshal(&doc)
+}
+
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for i := range n.Content {
+		blockStyle(n.Content[i])
+	}
+}
+
+// Serializes a Neg as CSV: a header row naming the fields of the Neg, followed by a single row.  A Neg restricted to a
+// subset of its fields has a column for each field of the subset.
+func marshalCsv(t interface{}, uri string) ([]byte, error) {
+	columns, record := csvColumns, csvRecord(asNeg(t))
+	if s, ok := t.(sparse); ok {
+		columns, record = nil, nil
+		for i := range csvColumns {
+			if s.includes(csvColumns[i]) {
+				columns = append(columns, csvColumns[i])
+				record = append(record, csvRecord(asNeg(t))[i])
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	cw := csv.NewWriter(buf)
+	_ = cw.Write(columns)
+	_ = cw.Write(record)
+	cw.Flush()
+	return buf.Bytes(), cw.Error()
+}
+
+// Returns the Neg t, or a copy of it with only the fields of the subset if t is restricted to a subset of its fields.
+// Related resources inlined in t are disregarded.
+func asNeg(t interface{}) *model.Neg {
+	if e, ok := t.(expanded); ok {
+		t = e.t
+	}
+	if s, ok := t.(sparse); ok {
+		t = s.zeroed()
+	}
+	n, ok := t.(*model.Neg)
+	if !ok {
+		panic(fmt.Sprintf("handler/neg: unable to represent entity, unhandled type %T", t))
+	}
+	return n
+}
@@ -0,0 +1,116 @@
+package neg
+
+import (
+	"encoding/json"
+	"github.com/emetsger/negtracker/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var represented = model.Neg{
+	Id:          "moo",
+	Created:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	Updated:     time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC),
+	Film:        "Tri-X",
+	EI:          400,
+	FrameNumber: "12",
+	Tags:        []string{"400", "true"},
+	Format:      "120",
+}
+
+func getAs(t *testing.T, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/neg/moo", nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	NewHandler(memStore(t, &represented), nil)(w, r)
+	return w
+}
+
+func Test_Negotiate(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"":                                     "application/json",
+		"*/*":                                  "application/json",
+		"application/*":                        "application/json",
+		"text/csv":                             "text/csv",
+		"application/json;q=0.5, text/csv":     "text/csv",
+		"application/yaml, application/json":   "application/json",
+		"application/ld+json;q=0.9, */*;q=0.1": "application/ld+json",
+		"text/*":                               "text/csv",
+	} {
+		w := getAs(t, accept)
+		assert.Equal(t, 200, w.Code, accept)
+		assert.Equal(t, expected, w.Header().Get("Content-Type"), accept)
+		assert.Equal(t, "Accept", w.Header().Get("Vary"), accept)
+	}
+
+	w := getAs(t, "application/xml, application/json;q=0")
+	assert.Equal(t, 406, w.Code)
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+}
+
+func Test_RepresentationEtags(t *testing.T) {
+	etags := map[string]bool{}
+	for _, rep := range representations {
+		etag := getAs(t, rep.mediaType).Header().Get("ETag")
+		assert.False(t, etags[etag], rep.mediaType)
+		etags[etag] = true
+	}
+
+	assert.Equal(t, string(represented.GetEtag()), getAs(t, "application/json").Header().Get("ETag"))
+	assert.Equal(t, string(represented.GetEtag().Variant("yaml")),
+		getAs(t, "application/yaml").Header().Get("ETag"))
+
+	// a conditional request is evaluated against the ETag of the selected representation
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/neg/moo", nil)
+	r.Header.Set("Accept", "application/yaml")
+	r.Header.Set("If-None-Match", string(represented.GetEtag()))
+	s := memStore(t, &represented)
+	NewHandler(s, nil)(w, r)
+	assert.Equal(t, 200, w.Code)
+
+	r.Header.Set("If-None-Match", string(represented.GetEtag().Variant("yaml")))
+	w = httptest.NewRecorder()
+	NewHandler(s, nil)(w, r)
+	assert.Equal(t, 304, w.Code)
+}
+
+func Test_MarshalJsonLd(t *testing.T) {
+	w := getAs(t, "application/ld+json")
+	doc := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+
+	assert.Equal(t, "https://schema.org", doc["@context"])
+	assert.Equal(t, "Photograph", doc["@type"])
+	assert.Equal(t, "/neg/moo", doc["@id"])
+	assert.Equal(t, "moo", doc["identifier"])
+	assert.Equal(t, "Tri-X", doc["material"])
+	assert.Equal(t, "12", doc["position"])
+	assert.Equal(t, "2020-01-02T03:04:05Z", doc["dateCreated"])
+	assert.NotContains(t, doc, "description")
+	assert.Len(t, doc["additionalProperty"], 2)
+}
+
+func Test_MarshalYaml(t *testing.T) {
+	w := getAs(t, "application/yaml")
+	assert.Contains(t, w.Body.String(), "Id: moo\n")
+
+	doc := map[string]interface{}{}
+	require.Nil(t, yaml.Unmarshal(w.Body.Bytes(), &doc))
+	// strings that resemble other YAML types must survive the round trip
+	assert.Equal(t, []interface{}{"400", "true"}, doc["Tags"])
+	assert.Equal(t, 400, doc["EI"])
+	assert.Equal(t, "Tri-X", doc["Film"])
+}
+
+func Test_MarshalCsv(t *testing.T) {
+	w := getAs(t, "text/csv")
+	assert.Equal(t, "Id,Created,Updated,Film,EI,Developer,FrameNumber,Tags,Description,Format\n"+
+		"moo,2020-01-02T03:04:05Z,2020-01-02T03:04:06Z,Tri-X,400,,12,400;true,,120\n", w.Body.String())
+}
@@ -0,0 +1,98 @@
+package neg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/hypermedia"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/validate"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The prefix of the error returned by a json.Decoder that disallows unknown fields
+const unknownFieldPrefix = "json: unknown field "
+
+// Strictly unmarshals the JSON in data to t: fields that are not declared by the model type of t are rejected, as are
+// values whose type does not match the declared type of their field.  Returns nil on success, otherwise an
+// http.HandlerFunc responding with a 400 for malformed JSON, or a 422 listing the offending fields.
+func unmarshal(data []byte, t interface{}) http.HandlerFunc {
+	violations, err := decode(data, t)
+
+	if err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.MalformedRequest(w, r, "Malformed request")
+		}
+	}
+
+	if len(violations) > 0 {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handler.UnprocessableEntity(w, r, "Request body is invalid", violations)
+		}
+	}
+
+	return nil
+}
+
+// Strictly unmarshals the JSON in data to t, per unmarshal.  Returns the reasons each offending field was rejected, or
+// an error if the JSON is malformed.  The hypermedia controls of a representation are not fields of the business
+// object, and are disregarded, so that a representation that was retrieved may be submitted as-is.
+func decode(data []byte, t interface{}) (violations map[string][]string, err error) {
+	dec := json.NewDecoder(bytes.NewReader(hypermedia.Strip(data)))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(t)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected content following the JSON value")
+	}
+
+	if err == nil {
+		return nil, nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		return map[string][]string{typeErr.Field: {fmt.Sprintf("must be of type %s", typeErr.Type)}}, nil
+	case strings.HasPrefix(err.Error(), unknownFieldPrefix):
+		field, unquoteErr := strconv.Unquote(strings.TrimPrefix(err.Error(), unknownFieldPrefix))
+		if unquoteErr != nil {
+			field = strings.TrimPrefix(err.Error(), unknownFieldPrefix)
+		}
+		return map[string][]string{field: {"is not a known field"}}, nil
+	default:
+		return nil, err
+	}
+}
+
+// Business ids may not begin with the prefix of the sub-resources of a collection, e.g. "_bulk", which take precedence
+// over "/{id}": a business object with such an id could not be retrieved, replaced, patched or deleted.
+const reservedIdPrefix = "_"
+
+// Validates the model struct t against the constraints declared by its tags, and its business id, if any, against
+// reservedIdPrefix.  Returns the violated constraints, or nil if there are none.
+func violationsOf(t interface{}) validate.Violations {
+	violations := validate.Struct(t)
+	if e, ok := t.(model.WebResource); ok && strings.HasPrefix(e.GetId(), reservedIdPrefix) {
+		violations = append(violations, validate.Violation{Field: "Id",
+			Reason: fmt.Sprintf("must not begin with %s", reservedIdPrefix)})
+	}
+	return violations
+}
+
+// Validates the model struct t per violationsOf.  Returns nil if t is valid, otherwise an http.HandlerFunc responding
+// with a 422 listing the violations of each field.
+func validated(t interface{}) http.HandlerFunc {
+	violations := violationsOf(t)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler.UnprocessableEntity(w, r, "Request body is invalid", violations.ByField())
+	}
+}
@@ -0,0 +1,85 @@
+package neg
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Invokes the http.HandlerFunc, returning the status and the violations of the problem it writes
+func problemViolations(t *testing.T, h http.HandlerFunc) (int, map[string]interface{}) {
+	require.NotNil(t, h)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("POST", "/neg", nil))
+
+	problem := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	v, _ := problem["violations"].(map[string]interface{})
+	return w.Code, v
+}
+
+func Test_Unmarshal(t *testing.T) {
+	neg := &model.Neg{}
+	assert.Nil(t, unmarshal([]byte(`{"film": "Tri-X", "EI": 400}`), neg))
+	assert.Equal(t, model.Neg{Film: "Tri-X", EI: 400}, *neg)
+
+	status, v := problemViolations(t, unmarshal([]byte(`{"Film": "Tri-X", "Speed": 400}`), &model.Neg{}))
+	assert.Equal(t, 422, status)
+	assert.Equal(t, map[string]interface{}{"Speed": []interface{}{"is not a known field"}}, v)
+
+	status, v = problemViolations(t, unmarshal([]byte(`{"Film": "Tri-X", "EI": "400"}`), &model.Neg{}))
+	assert.Equal(t, 422, status)
+	assert.Equal(t, map[string]interface{}{"EI": []interface{}{"must be of type int"}}, v)
+
+	status, _ = problemViolations(t, unmarshal([]byte(`{"Film": `), &model.Neg{}))
+	assert.Equal(t, 400, status)
+
+	status, _ = problemViolations(t, unmarshal([]byte(`{"Film": "Tri-X"} {}`), &model.Neg{}))
+	assert.Equal(t, 400, status)
+}
+
+func Test_Validated(t *testing.T) {
+	assert.Nil(t, validated(&model.Neg{Film: "Tri-X", EI: 400, Format: "120"}))
+
+	status, v := problemViolations(t, validated(&model.Neg{EI: -1, Format: "126"}))
+	assert.Equal(t, 422, status)
+	assert.Equal(t, []interface{}{"is required"}, v["Film"])
+	assert.Equal(t, []interface{}{"must be at least 0"}, v["EI"])
+	assert.Contains(t, v, "Format")
+}
+
+// business ids colliding with the sub-resources of the collection are rejected, whether POSTed or imported
+func Test_ReservedId(t *testing.T) {
+	status, v := problemViolations(t, validated(&model.Neg{Id: "_mget", Film: "Tri-X"}))
+	assert.Equal(t, 422, status)
+	assert.Equal(t, map[string]interface{}{"Id": []interface{}{"must not begin with _"}}, v)
+
+	s := &memory.MemoryStore{}
+	s.Configure(nil)
+	h := NewHandler(s, nil)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("POST", "/neg", bytes.NewBufferString(`{"Id": "_bulk", "Film": "Tri-X"}`)))
+	assert.Equal(t, 422, w.Code)
+
+	r := httptest.NewRequest("POST", "/neg/_bulk", bytes.NewBufferString(`{"Id": "_export", "Film": "Tri-X"}`))
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	w = httptest.NewRecorder()
+	h(w, r)
+	result := bulkResult{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 422, result.Status)
+	assert.Contains(t, result.Violations, "Id")
+
+	for _, id := range []string{"_bulk", "_export"} {
+		assert.Equal(t, store.CodeNotFound, store.CodeOf(s.Retrieve(id, &model.Neg{})))
+	}
+}
@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Responds with a 406, listing the media types the resource is available as.
+func NotAcceptable(w http.ResponseWriter, r *http.Request, available ...string) {
+	WriteProblem(w, r, NewProblem(406, "not-acceptable", "Resource is only available as "+
+		strings.Join(available, ", ")).With("available", available))
+}
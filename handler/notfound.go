@@ -0,0 +1,9 @@
+package handler
+
+import (
+	"net/http"
+)
+
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, NewProblem(404, "not-found", "Resource not found"))
+}
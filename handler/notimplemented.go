@@ -2,13 +2,10 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 )
 
+// Responds with a 501, for methods that are not recognized by the server.  Methods that are recognized but not
+// supported by the target resource should be answered with MethodNotAllowed.
 func NotImplemented(w http.ResponseWriter, r *http.Request) {
-	bytes := []byte("Method not implemented")
-	w.Header().Set("Content-Type", "text/plain")
-	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
-	w.WriteHeader(500)
-	_, _ = w.Write(bytes)
-}
\ No newline at end of file
+	WriteProblem(w, r, NewProblem(501, "not-implemented", "Method not implemented"))
+}
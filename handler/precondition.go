@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"net/http"
+)
+
+func PreconditionFailed(w http.ResponseWriter, r *http.Request, reason string) {
+	WriteProblem(w, r, NewProblem(412, "precondition-failed", reason))
+}
+
+func PreconditionRequired(w http.ResponseWriter, r *http.Request, reason string) {
+	WriteProblem(w, r, NewProblem(428, "precondition-required", reason))
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/emetsger/negtracker/media"
+	"net/http"
+	"strconv"
+)
+
+// The media type of a problem details object, per RFC 7807
+const ProblemMediaType = "application/problem+json"
+
+// The prefix of the URI references identifying problem types.  References are relative to the URI of the server.
+const problemTypePrefix = "/problems/"
+
+// Media types that a problem may be represented as, in order of preference
+var problemOffers = []string{ProblemMediaType, "application/json", "text/plain"}
+
+// Describes an error that occurred while processing a request, per RFC 7807.  Problems are written to the response as
+// application/problem+json, unless the client prefers plain text.
+type Problem struct {
+	// A URI reference identifying the problem type, e.g. "/problems/not-found"
+	Type string
+	// A short, human-readable summary of the problem type, e.g. "Not Found"
+	Title string
+	// The HTTP status code of the response
+	Status int
+	// A human-readable explanation specific to this occurrence of the problem
+	Detail string
+	// A URI reference identifying this occurrence of the problem, i.e. the URI of the request
+	Instance string
+	// The correlation id of the request, see RequestId
+	RequestId string
+	// Extension members of the problem, e.g. the parameters that failed validation.  Members are written alongside the
+	// standard members, which they must not collide with.
+	Extensions map[string]interface{}
+}
+
+// A request parameter or field that failed validation, written as a member of the "invalid-params" extension
+type FieldError struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Creates a problem with the supplied status, and a type and title derived from the status.  The type may be refined
+// with a more specific value by the caller.
+func NewProblem(status int, slug, detail string) *Problem {
+	return &Problem{
+		Type:   problemTypePrefix + slug,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Adds an extension member to the problem, returning the problem.
+func (p *Problem) With(name string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]interface{}{}
+	}
+	p.Extensions[name] = value
+	return p
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := map[string]interface{}{}
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	if p.RequestId != "" {
+		members["requestId"] = p.RequestId
+	}
+
+	return json.Marshal(members)
+}
+
+// Writes the problem to the response, in the representation selected by the Accept header of the request.  The instance
+// and correlation id of the problem are taken from the request if they have not been set.
+//
+// Problems are always written, even if the client accepts none of the offered media types: an error response in an
+// unacceptable media type is more useful than a 406.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.RequestURI()
+	}
+	if p.RequestId == "" {
+		p.RequestId = r.Header.Get(RequestIdHeader)
+	}
+
+	mediaType := media.Negotiate(r.Header.Get("Accept"), problemOffers...)
+
+	var body []byte
+	switch mediaType {
+	case "text/plain":
+		body = []byte(p.Detail)
+		if len(body) == 0 {
+			body = []byte(p.Title)
+		}
+	case "":
+		mediaType = ProblemMediaType
+		fallthrough
+	default:
+		var err error
+		if body, err = json.Marshal(p); err != nil {
+			panic("handler: unable to marshal problem, " + err.Error())
+		}
+	}
+
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(body)
+}
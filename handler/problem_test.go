@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteProblem(t *testing.T) {
+	r := httptest.NewRequest("GET", "/neg?limit=0", nil)
+	r.Header.Set(RequestIdHeader, "moo")
+	w := httptest.NewRecorder()
+
+	InvalidParams(w, r, "Invalid value for 'limit'", []FieldError{{Name: "limit", Reason: "out of range"}})
+
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, ProblemMediaType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+
+	problem := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, map[string]interface{}{
+		"type":           "/problems/invalid-params",
+		"title":          "Bad Request",
+		"status":         float64(400),
+		"detail":         "Invalid value for 'limit'",
+		"instance":       "/neg?limit=0",
+		"requestId":      "moo",
+		"invalid-params": []interface{}{map[string]interface{}{"name": "limit", "reason": "out of range"}},
+	}, problem)
+}
+
+func TestWriteProblem_Negotiation(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"":                               ProblemMediaType,
+		"*/*":                            ProblemMediaType,
+		"application/json":               "application/json",
+		"text/plain":                     "text/plain",
+		"text/*, application/json;q=0.5": "text/plain",
+		"image/png":                      ProblemMediaType,
+	} {
+		r := httptest.NewRequest("GET", "/neg/moo", nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+
+		NotFound(w, r)
+
+		assert.Equal(t, 404, w.Code)
+		assert.Equal(t, expected, w.Header().Get("Content-Type"), "Accept: %s", accept)
+		if expected == "text/plain" {
+			assert.Equal(t, "Resource not found", w.Body.String())
+		}
+	}
+}
+
+func TestRequestId(t *testing.T) {
+	var correlationId string
+	h := RequestId(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correlationId = r.Header.Get(RequestIdHeader)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/neg", nil))
+	assert.NotEmpty(t, correlationId)
+	assert.Equal(t, correlationId, w.Header().Get(RequestIdHeader))
+
+	r := httptest.NewRequest("GET", "/neg", nil)
+	r.Header.Set(RequestIdHeader, "moo")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "moo", correlationId)
+	assert.Equal(t, "moo", w.Header().Get(RequestIdHeader))
+}
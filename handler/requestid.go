@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"github.com/emetsger/negtracker/id"
+	"net/http"
+)
+
+// The header carrying the correlation id of a request
+const RequestIdHeader = "X-Request-Id"
+
+// Returns an http.Handler that assigns a correlation id to each request before invoking h.  The correlation id supplied
+// by the client (or a proxy) in the X-Request-Id header is used if present, otherwise one is minted.  The correlation id
+// is echoed in the X-Request-Id header of the response, and is included in problems written by WriteProblem.
+func RequestId(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correlationId := r.Header.Get(RequestIdHeader)
+		if correlationId == "" {
+			correlationId = id.Mint()
+			r.Header.Set(RequestIdHeader, correlationId)
+		}
+
+		w.Header().Set(RequestIdHeader, correlationId)
+		h.ServeHTTP(w, r)
+	})
+}
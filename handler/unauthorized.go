@@ -0,0 +1,14 @@
+package handler
+
+import (
+	"net/http"
+)
+
+func Unauthorized(w http.ResponseWriter, r *http.Request, challenge string) {
+	w.Header().Set("WWW-Authenticate", challenge)
+	WriteProblem(w, r, NewProblem(401, "unauthorized", "Authorization required"))
+}
+
+func Forbidden(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, NewProblem(403, "forbidden", "Forbidden"))
+}
@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Responds with a 503, advising the client to retry the request after the supplied number of seconds.
+func ServiceUnavailable(w http.ResponseWriter, r *http.Request, retryAfter int) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	WriteProblem(w, r, NewProblem(503, "service-unavailable", "Service temporarily unavailable"))
+}
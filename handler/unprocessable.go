@@ -0,0 +1,11 @@
+package handler
+
+import (
+	"net/http"
+)
+
+// Responds with a 422, listing the reasons each field of the request body is invalid in the "violations" extension
+// member of the problem.
+func UnprocessableEntity(w http.ResponseWriter, r *http.Request, reason string, violations map[string][]string) {
+	WriteProblem(w, r, NewProblem(422, "validation-failed", reason).With("violations", violations))
+}
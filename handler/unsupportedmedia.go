@@ -0,0 +1,9 @@
+package handler
+
+import (
+	"net/http"
+)
+
+func UnsupportedMediaType(w http.ResponseWriter, r *http.Request, reason string) {
+	WriteProblem(w, r, NewProblem(415, "unsupported-media-type", reason))
+}
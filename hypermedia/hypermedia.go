@@ -0,0 +1,299 @@
+// Provides the hypermedia controls of the JSON representation of business objects, following the conventions of HAL
+// (draft-kelly-json-hal): a `_links` member linking the representation to itself, to the collection it belongs to, and
+// to the resources it relates to; and an `_embedded` member inlining related resources on request, so that clients
+// may traverse relationships without constructing URIs themselves.
+package hypermedia
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// The member of a representation holding its links
+	LinksMember = "_links"
+	// The member of a representation holding the related resources inlined in it
+	EmbeddedMember = "_embedded"
+	// The relation of the link to the representation itself (RFC 4287)
+	RelSelf = "self"
+	// The relation of the link to the collection the business object belongs to (RFC 6573)
+	RelCollection = "collection"
+)
+
+// The maximum number of related resources that may be inlined in a single representation
+const MaxExpanded = 50
+
+// A link to a resource
+type Link struct {
+	// The URI of the resource, relative to the URI of the server
+	Href string `json:"href"`
+}
+
+// The links of a representation, keyed by relation.  The self and collection relations link to a single resource;
+// every other relation links to an array of resources, which may be empty.
+type Links map[string]interface{}
+
+// Returns the links of the representation of a business object identified by the supplied URI: to itself, to the
+// collection it belongs to, i.e. the parent of the URI, and to each of its related resources.
+func LinksOf(r model.WebResource, self string) Links {
+	links := Links{RelSelf: Link{self}}
+	if i := strings.LastIndex(self, "/"); i > 0 {
+		links[RelCollection] = Link{self[:i]}
+	}
+
+	for _, rel := range Relations(r) {
+		related := make([]Link, len(rel.Ids))
+		for i := range rel.Ids {
+			related[i] = Link{href(rel, rel.Ids[i])}
+		}
+		links[rel.Name] = related
+	}
+
+	return links
+}
+
+// Returns the relations of a business object, or nil if it does not relate to other business objects
+func Relations(r model.WebResource) []model.Relation {
+	if related, ok := r.(model.Related); ok {
+		return related.GetRelations()
+	}
+	return nil
+}
+
+// Returns the URI of the related resource identified by id
+func href(rel model.Relation, id string) string {
+	return strings.TrimSuffix(rel.Collection, "/") + "/" + url.PathEscape(id)
+}
+
+// A business object with the hypermedia controls of its representation.  The JSON representation of a Resource is the
+// JSON object representing the business object, followed by the `_links` member, and the `_embedded` member if any
+// related resources are inlined.
+type Resource struct {
+	// The value serialized as the members of the representation: the business object, or a value standing in for it,
+	// e.g. a subset of its fields.  Its JSON representation must be an object.
+	Value interface{}
+	// The business object, from which the links of the representation are derived
+	Of model.WebResource
+	// The URI of the representation
+	Self string
+	// The related resources inlined in the representation, keyed by relation
+	Embedded map[string][]Resource
+}
+
+func (res Resource) MarshalJSON() ([]byte, error) {
+	value, err := json.Marshal(res.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	value = bytes.TrimSpace(value)
+	if len(value) < 2 || value[0] != '{' || value[len(value)-1] != '}' {
+		return nil, fmt.Errorf("hypermedia: representation of %T is not a JSON object", res.Value)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(value[:len(value)-1])
+	if len(bytes.TrimSpace(value[1:len(value)-1])) > 0 {
+		buf.WriteByte(',')
+	}
+
+	if err := member(buf, LinksMember, LinksOf(res.Of, res.Self)); err != nil {
+		return nil, err
+	}
+	if len(res.Embedded) > 0 {
+		buf.WriteByte(',')
+		if err := member(buf, EmbeddedMember, res.Embedded); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Writes the object member with the supplied name and value to the buffer
+func member(buf *bytes.Buffer, name string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	key, _ := json.Marshal(name)
+	buf.Write(key)
+	buf.WriteByte(':')
+	buf.Write(data)
+	return nil
+}
+
+// Returned by Expand when inlining the related resources would exceed MaxExpanded
+type FanOutError struct {
+	// The number of related resources that would have been inlined
+	Count int
+}
+
+func (e *FanOutError) Error() string {
+	return fmt.Sprintf("would inline %d related resources, at most %d may be inlined", e.Count, MaxExpanded)
+}
+
+// Retrieves the business object identified by id into t, e.g. store.Api.Retrieve
+type Retriever func(id string, t interface{}) error
+
+// The retrievers of related resources, keyed by the URI of the collection the resources belong to, e.g. "/neg".  Each
+// type of business object is typically kept by a store of its own, so the resources of a relation are retrieved by the
+// retriever of its collection.
+type Retrievers map[string]Retriever
+
+// Returned by Unknown and Expand when a name does not identify a relation of the business object, or identifies a
+// relation whose related resources cannot be retrieved
+type RelationError struct {
+	// The offending name
+	Name string
+	// The collection of the related resources, if the relation is declared but no retriever is supplied for it
+	Collection string
+}
+
+func (e *RelationError) Error() string {
+	if e.Collection != "" {
+		return fmt.Sprintf("relation '%s' cannot be expanded, resources of %s are not retrievable", e.Name, e.Collection)
+	}
+	return fmt.Sprintf("unknown relation '%s'", e.Name)
+}
+
+// Returns a *RelationError for the first of the supplied names that does not identify a relation of the business
+// object, or whose collection has no retriever, or nil if the related resources of every name may be retrieved.  A zero
+// business object may be supplied, as every relation of its type is declared regardless of its state.
+func Unknown(r model.WebResource, names []string, retrievers Retrievers) error {
+	relations := map[string]model.Relation{}
+	for _, rel := range Relations(r) {
+		relations[rel.Name] = rel
+	}
+
+	for _, name := range names {
+		if _, err := relationOf(relations, name, retrievers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the named relation and the retriever of its collection, or a *RelationError if there is no such relation, or
+// no retriever for it
+func relationOf(relations map[string]model.Relation, name string, retrievers Retrievers) (Retriever, error) {
+	rel, ok := relations[name]
+	if !ok {
+		return nil, &RelationError{Name: name}
+	}
+	retrieve, ok := retrievers[rel.Collection]
+	if !ok {
+		return nil, &RelationError{Name: name, Collection: rel.Collection}
+	}
+	return retrieve, nil
+}
+
+// Inlines the resources related to a business object by the named relations, one level deep: an inlined resource links
+// to its own related resources, but does not inline them.  The representation of the business object is identified by
+// the URI self.  Related resources are retrieved by the retriever of the collection they belong to.
+//
+// Cycles are not followed: a business object related to itself is not inlined in its own representation, and a
+// resource related by more than one relation is inlined once, under the first relation named.  Related resources that
+// do not exist, or have been deleted, are linked but not inlined.  If more than MaxExpanded resources would be inlined,
+// a *FanOutError is returned without retrieving any of them, and a *RelationError is returned if a name does not
+// identify a relation of the business object, or no retriever is supplied for the collection of the relation.
+func Expand(r model.WebResource, self string, names []string, retrievers Retrievers) (map[string][]Resource, error) {
+	type target struct {
+		rel      model.Relation
+		id       string
+		retrieve Retriever
+	}
+
+	relations := map[string]model.Relation{}
+	for _, rel := range Relations(r) {
+		relations[rel.Name] = rel
+	}
+
+	var targets []target
+	seen := map[string]bool{self: true}
+	for _, name := range names {
+		retrieve, err := relationOf(relations, name, retrievers)
+		if err != nil {
+			return nil, err
+		}
+		rel := relations[name]
+		for _, id := range rel.Ids {
+			if uri := href(rel, id); !seen[uri] {
+				seen[uri] = true
+				targets = append(targets, target{rel, id, retrieve})
+			}
+		}
+	}
+
+	if len(targets) > MaxExpanded {
+		return nil, &FanOutError{len(targets)}
+	}
+
+	embedded := map[string][]Resource{}
+	for _, target := range targets {
+		related := target.rel.New()
+		err := target.retrieve(target.id, related)
+		switch store.CodeOf(err) {
+		case store.CodeNotFound, store.CodeDeleted:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		uri := href(target.rel, target.id)
+		embedded[target.rel.Name] = append(embedded[target.rel.Name], Resource{Value: related, Of: related, Self: uri})
+	}
+
+	return embedded, nil
+}
+
+// Returns a digest of the state of the inlined resources, which changes whenever any of them is updated, so that the
+// validators of a representation may account for the resources inlined in it.
+func Digest(embedded map[string][]Resource) string {
+	var names []string
+	for name := range embedded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		for _, res := range embedded[name] {
+			_, _ = fmt.Fprintf(h, "%s %s %s\n", name, res.Self, res.Of.GetEtag())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Removes the hypermedia controls from the JSON object in data, so that a representation may be submitted as the state
+// of a business object, e.g. by PUT after a GET.  Returns data as-is if it is not a JSON object, or has no controls.
+func Strip(data []byte) []byte {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return data
+	}
+
+	_, links := members[LinksMember]
+	_, embedded := members[EmbeddedMember]
+	if !links && !embedded {
+		return data
+	}
+
+	delete(members, LinksMember)
+	delete(members, EmbeddedMember)
+	stripped, err := json.Marshal(members)
+	if err != nil {
+		return data
+	}
+	return stripped
+}
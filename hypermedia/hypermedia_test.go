@@ -0,0 +1,199 @@
+package hypermedia
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// A roll of film, relating to the negatives exposed on it, and to the roll exposed before it
+type roll struct {
+	Id       string
+	Created  time.Time
+	Updated  time.Time
+	Negs     []string
+	Previous string
+}
+
+func (r *roll) GetId() string         { return r.Id }
+func (r *roll) GetCreated() time.Time { return r.Created }
+func (r *roll) GetUpdated() time.Time { return r.Updated }
+func (r *roll) GetEtag() model.Etag {
+	return model.Etag(fmt.Sprintf("\"%s-%d\"", r.Id, r.Updated.Unix()))
+}
+func (r *roll) SetId(id string)        { r.Id = id }
+func (r *roll) SetCreated(t time.Time) { r.Created = t }
+func (r *roll) SetUpdated(t time.Time) { r.Updated = t }
+func (r *roll) GetRelations() []model.Relation {
+	var previous []string
+	if r.Previous != "" {
+		previous = []string{r.Previous}
+	}
+	return []model.Relation{
+		{Name: "negs", Collection: "/neg", Ids: r.Negs, New: func() model.WebResource { return &model.Neg{} }},
+		{Name: "previous", Collection: "/roll", Ids: previous, New: func() model.WebResource { return &roll{} }},
+	}
+}
+
+// Retrieves Negs and rolls from maps keyed by id, counting retrievals
+type retriever struct {
+	negs      map[string]model.Neg
+	rolls     map[string]roll
+	retrieved int
+}
+
+func (s *retriever) Retrieve(id string, t interface{}) error {
+	s.retrieved++
+	switch v := t.(type) {
+	case *model.Neg:
+		if n, ok := s.negs[id]; ok {
+			*v = n
+			return nil
+		}
+	case *roll:
+		if r, ok := s.rolls[id]; ok {
+			*v = r
+			return nil
+		}
+	}
+	return store.SentinelErr(store.NotFoundErr, fmt.Sprintf("no resource with id %s", id), nil)
+}
+
+// Returns the retrievers of the Negs and rolls
+func (s *retriever) retrievers() Retrievers {
+	return Retrievers{"/neg": s.Retrieve, "/roll": s.Retrieve}
+}
+
+func TestLinksOf(t *testing.T) {
+	assert.Equal(t, Links{
+		RelSelf:       Link{"/neg/moo"},
+		RelCollection: Link{"/neg"},
+	}, LinksOf(&model.Neg{Id: "moo"}, "/neg/moo"))
+
+	assert.Equal(t, Links{
+		RelSelf:       Link{"/roll/r1"},
+		RelCollection: Link{"/roll"},
+		"negs":        []Link{{"/neg/n1"}, {"/neg/n%2F2"}},
+		"previous":    []Link{},
+	}, LinksOf(&roll{Id: "r1", Negs: []string{"n1", "n/2"}}, "/roll/r1"))
+}
+
+func TestResource_MarshalJSON(t *testing.T) {
+	n := &model.Neg{Id: "moo", Film: "Tri-X"}
+	data, err := json.Marshal(Resource{Value: struct{ Film string }{"Tri-X"}, Of: n, Self: "/neg/moo"})
+	require.Nil(t, err)
+	assert.Equal(t, `{"Film":"Tri-X","_links":{"collection":{"href":"/neg"},"self":{"href":"/neg/moo"}}}`, string(data))
+
+	data, err = json.Marshal(Resource{Value: struct{}{}, Of: n, Self: "/neg/moo", Embedded: map[string][]Resource{
+		"negs": {{Value: struct{}{}, Of: n, Self: "/neg/moo"}},
+	}})
+	require.Nil(t, err)
+	assert.Equal(t, `{"_links":{"collection":{"href":"/neg"},"self":{"href":"/neg/moo"}},`+
+		`"_embedded":{"negs":[{"_links":{"collection":{"href":"/neg"},"self":{"href":"/neg/moo"}}}]}}`, string(data))
+
+	_, err = json.Marshal(Resource{Value: []string{"moo"}, Of: n, Self: "/neg/moo"})
+	assert.NotNil(t, err)
+}
+
+func TestExpand(t *testing.T) {
+	s := &retriever{
+		negs:  map[string]model.Neg{"n1": {Id: "n1", Film: "Tri-X"}},
+		rolls: map[string]roll{"r0": {Id: "r0", Negs: []string{"n0"}, Previous: "r1"}},
+	}
+	r := &roll{Id: "r1", Negs: []string{"n1", "n1", "missing"}, Previous: "r0"}
+
+	embedded, err := Expand(r, "/roll/r1", []string{"negs", "previous"}, s.retrievers())
+	require.Nil(t, err)
+	// duplicates are retrieved once, and missing resources are not inlined
+	assert.Equal(t, 3, s.retrieved)
+	require.Len(t, embedded["negs"], 1)
+	assert.Equal(t, "/neg/n1", embedded["negs"][0].Self)
+	assert.Equal(t, "Tri-X", embedded["negs"][0].Value.(*model.Neg).Film)
+
+	// inlined resources link to their related resources, but do not inline them
+	require.Len(t, embedded["previous"], 1)
+	previous := embedded["previous"][0]
+	assert.Nil(t, previous.Embedded)
+	assert.Equal(t, []Link{{"/roll/r1"}}, LinksOf(previous.Of, previous.Self)["previous"])
+}
+
+func TestExpand_Cycle(t *testing.T) {
+	s := &retriever{}
+	r := &roll{Id: "r1", Previous: "r1"}
+
+	embedded, err := Expand(r, "/roll/r1", []string{"previous"}, s.retrievers())
+	require.Nil(t, err)
+	assert.Empty(t, embedded)
+	assert.Equal(t, 0, s.retrieved)
+}
+
+func TestExpand_FanOut(t *testing.T) {
+	s := &retriever{}
+	r := &roll{Id: "r1"}
+	for i := 0; i <= MaxExpanded; i++ {
+		r.Negs = append(r.Negs, fmt.Sprintf("n%d", i))
+	}
+
+	_, err := Expand(r, "/roll/r1", []string{"negs"}, s.retrievers())
+	var ferr *FanOutError
+	require.True(t, errors.As(err, &ferr))
+	assert.Equal(t, MaxExpanded+1, ferr.Count)
+	assert.Equal(t, 0, s.retrieved)
+}
+
+func TestExpand_Errors(t *testing.T) {
+	r := &roll{Id: "r1", Negs: []string{"n1"}}
+
+	_, err := Expand(r, "/roll/r1", []string{"scans"}, (&retriever{}).retrievers())
+	var rerr *RelationError
+	require.True(t, errors.As(err, &rerr))
+	assert.Equal(t, "scans", rerr.Name)
+
+	// related resources are retrieved by the retriever of their collection
+	s := &retriever{}
+	_, err = Expand(r, "/roll/r1", []string{"negs"}, Retrievers{"/roll": s.Retrieve})
+	require.True(t, errors.As(err, &rerr))
+	assert.Equal(t, &RelationError{Name: "negs", Collection: "/neg"}, rerr)
+	assert.Equal(t, 0, s.retrieved)
+
+	failure := store.SentinelErr(store.UnavailableErr, "unavailable", nil)
+	_, err = Expand(r, "/roll/r1", []string{"negs"}, Retrievers{
+		"/neg":  func(id string, t interface{}) error { return failure },
+		"/roll": s.Retrieve,
+	})
+	assert.Equal(t, failure, err)
+}
+
+func TestUnknown(t *testing.T) {
+	s := &retriever{}
+	assert.Nil(t, Unknown(&roll{}, []string{"negs", "previous"}, s.retrievers()))
+	assert.Equal(t, &RelationError{Name: "scans"}, Unknown(&roll{}, []string{"negs", "scans"}, s.retrievers()))
+	assert.Equal(t, &RelationError{Name: "negs"}, Unknown(&model.Neg{}, []string{"negs"}, s.retrievers()))
+	assert.Equal(t, &RelationError{Name: "previous", Collection: "/roll"},
+		Unknown(&roll{}, []string{"negs", "previous"}, Retrievers{"/neg": s.Retrieve}))
+}
+
+func TestDigest(t *testing.T) {
+	n := &model.Neg{Id: "moo", Updated: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	embedded := map[string][]Resource{"negs": {{Value: n, Of: n, Self: "/neg/moo"}}}
+	digest := Digest(embedded)
+	assert.Equal(t, digest, Digest(embedded))
+
+	n.Updated = n.Updated.Add(time.Millisecond)
+	assert.NotEqual(t, digest, Digest(embedded))
+}
+
+func TestStrip(t *testing.T) {
+	assert.JSONEq(t, `{"Film":"Tri-X"}`,
+		string(Strip([]byte(`{"Film":"Tri-X","_links":{"self":{"href":"/neg/moo"}},"_embedded":{}}`))))
+
+	for _, data := range []string{`{"Film":"Tri-X"}`, `["_links"]`, `{"_links":`} {
+		assert.Equal(t, data, string(Strip([]byte(data))))
+	}
+}
@@ -50,14 +50,15 @@ func (bid *BusinessId) Unlock() {
 }
 
 // Obtain a business id for the given identifier string and type.
-func GetId(id string, t interface{}) BusinessId {
+//
+// A pointer is returned because BusinessId is a sync.Locker, and must not be copied.
+func GetId(id string, t interface{}) *BusinessId {
 	tuple := idTypeTuple{id, typeAsString(t)}
-	bid := BusinessId{idTypeTuple: tuple}
-	return bid
+	return &BusinessId{idTypeTuple: tuple}
 }
 
 // Return the (identifier, type) tuple for this BusinessId as a string
-func (bid BusinessId) String() string {
+func (bid *BusinessId) String() string {
 	return fmt.Sprintf("Id: %s Type: %s", bid.idTypeTuple.bid, bid.idTypeTuple.bidType)
 }
 
@@ -90,13 +90,13 @@ func Test_LockTableConcurrency(t *testing.T) {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	// make 10 go routines that are retrieving business ids, communicating them back through the channel
-	f := func(c chan BusinessId) {
+	f := func(c chan *BusinessId) {
 		for i := 0; i < count; i++ {
 			c <- GetId(ids[r.Intn(totalcalls/4)], model.Neg{})
 		}
 	}
 
-	ch := make(chan BusinessId)
+	ch := make(chan *BusinessId)
 
 	for i := 0; i < routines; i++ {
 		go f(ch)
@@ -0,0 +1,254 @@
+// Makes non-idempotent requests (e.g. POST) safe to retry, by honoring the Idempotency-Key request header.
+//
+// The response to the first request bearing a key is recorded, along with a fingerprint of the request.  A subsequent
+// request bearing the same key is not processed; instead, the recorded response is replayed.  If the fingerprint of the
+// subsequent request differs from the first, the key has been reused for a different request, and a 422 results.
+//
+// Recorded responses are retained in memory for a configurable time-to-live, so keys are only honored within an
+// instance of negtracker.
+package idempotency
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/emetsger/negtracker/handler"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// The header carrying the idempotency key of a request
+	KeyHeader = "Idempotency-Key"
+	// The header present on replayed responses
+	ReplayedHeader = "Idempotent-Replayed"
+	// The maximum length of an idempotency key
+	maxKeyLength = 255
+)
+
+// The time-to-live of recorded responses when none is configured
+const DefaultTTL = 24 * time.Hour
+
+// The maximum number of recorded responses when none is configured
+const DefaultMaxEntries = 10000
+
+// A response recorded for an idempotency key
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type entry struct {
+	key         string
+	fingerprint string
+	response    *Response
+	expires     time.Time
+}
+
+// Serializes the requests bearing an idempotency key
+type keyLock struct {
+	sync.Mutex
+	// the number of requests holding, or waiting for, the lock
+	refs int
+}
+
+// Retains the responses recorded for idempotency keys until their time-to-live elapses, or until they are the oldest of
+// more than the maximum number of entries.  Safe for concurrent use.
+type Cache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	// the entries by key, and in the order they were recorded, which is the order they expire in
+	entries map[string]*list.Element
+	order   *list.List
+	// the locks of the keys of requests in flight, guarded by mu.  Keys are chosen by clients, so their locks are kept
+	// apart from the BusinessId lock table, and are discarded once no request holds them.
+	locks map[string]*keyLock
+	// returns the current time; replaced by tests
+	now func() time.Time
+}
+
+// Returns a Cache retaining at most maxEntries responses for the supplied time-to-live.  If the ttl is not positive,
+// DefaultTTL is used; if maxEntries is not positive, DefaultMaxEntries is used.
+func NewCache(ttl time.Duration, maxEntries int) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Cache{ttl: ttl, maxEntries: maxEntries, entries: map[string]*list.Element{}, order: list.New(),
+		locks: map[string]*keyLock{}, now: time.Now}
+}
+
+// Obtains the lock of the key, blocking until any other request bearing the key releases it.  Returns the function
+// releasing the lock.
+func (c *Cache) lock(key string) (unlock func()) {
+	c.mu.Lock()
+	l, ok := c.locks[key]
+	if !ok {
+		l = &keyLock{}
+		c.locks[key] = l
+	}
+	l.refs++
+	c.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(c.locks, key)
+		}
+	}
+}
+
+// Returns the fingerprint and response recorded for the key, if they have not expired.
+func (c *Cache) Lookup(key string) (fingerprint string, r *Response, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict(c.now())
+	elem, ok := c.entries[key]
+	if !ok {
+		return "", nil, false
+	}
+
+	e := elem.Value.(*entry)
+	return e.fingerprint, e.response, true
+}
+
+// Records the fingerprint and response for the key.  Expired entries are evicted, as is the oldest entry if the
+// maximum number of entries is exceeded.
+func (c *Cache) Store(key, fingerprint string, r *Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.evict(now)
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+	}
+	c.entries[key] = c.order.PushBack(&entry{key: key, fingerprint: fingerprint, response: r,
+		expires: now.Add(c.ttl)})
+
+	if c.order.Len() > c.maxEntries {
+		c.remove(c.order.Front())
+	}
+}
+
+// Evicts the entries that have expired by the supplied time.  Entries expire in the order they were recorded, so only
+// the expired entries are visited.  Must be called with mu held.
+func (c *Cache) evict(now time.Time) {
+	for elem := c.order.Front(); elem != nil && now.After(elem.Value.(*entry).expires); elem = c.order.Front() {
+		c.remove(elem)
+	}
+}
+
+// Must be called with mu held
+func (c *Cache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).key)
+}
+
+// Returns an http.Handler that honors the Idempotency-Key header of requests before invoking h.  Requests without the
+// header are passed to h unaltered.
+//
+// Requests bearing the same key are serialized, so a retry that arrives while the original request is being processed
+// waits for, and replays, the original response.  Server errors (5xx) are not recorded, so the request may be retried.
+func Handler(c *Cache, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(KeyHeader)
+		if key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			handler.MalformedRequest(w, r, "Idempotency-Key must be at most "+strconv.Itoa(maxKeyLength)+" characters")
+			return
+		}
+
+		body := &bytes.Buffer{}
+		_, _ = io.Copy(body, r.Body)
+		r.Body = ioutil.NopCloser(body)
+		fingerprint := fingerprint(r, body.Bytes())
+
+		unlock := c.lock(key)
+		defer unlock()
+
+		if recorded, response, ok := c.Lookup(key); ok {
+			if recorded != fingerprint {
+				handler.WriteProblem(w, r, handler.NewProblem(422, "idempotency-key-reused",
+					"Idempotency-Key was used for a different request"))
+				return
+			}
+			replay(w, response)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, body: &bytes.Buffer{}}
+		h.ServeHTTP(rec, r)
+
+		if rec.code == 0 {
+			// nothing was written by h
+			rec.WriteHeader(200)
+		}
+
+		if rec.code < 500 {
+			c.Store(key, fingerprint, &Response{Status: rec.code, Header: rec.header, Body: rec.body.Bytes()})
+		}
+	})
+}
+
+// Returns a digest of the method, path, and body of the request
+func fingerprint(r *http.Request, body []byte) string {
+	digest := sha256.New()
+	_, _ = io.WriteString(digest, r.Method+" "+r.URL.Path+"\n")
+	_, _ = digest.Write(body)
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// Writes the recorded response.  The correlation id of the current request is retained.
+func replay(w http.ResponseWriter, response *Response) {
+	for name, values := range response.Header {
+		if name != handler.RequestIdHeader {
+			w.Header()[name] = values
+		}
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(response.Status)
+	_, _ = w.Write(response.Body)
+}
+
+// An http.ResponseWriter that records the response written through it
+type recorder struct {
+	http.ResponseWriter
+	code   int
+	header http.Header
+	body   *bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.code == 0 {
+		rec.code = status
+		rec.header = rec.ResponseWriter.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.WriteHeader(200)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
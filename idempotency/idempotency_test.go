@@ -0,0 +1,149 @@
+package idempotency
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Returns a handler that counts its invocations, and responds with the supplied status and the request body
+func counting(count *int, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*count++
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Location", "/neg/moo")
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	})
+}
+
+func post(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/neg", bytes.NewBufferString(body))
+	if key != "" {
+		r.Header.Set(KeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandler_Replay(t *testing.T) {
+	count := 0
+	h := Handler(NewCache(time.Minute, 0), counting(&count, 201))
+
+	first := post(h, "TestHandler_Replay", `{"Film": "Tri-X"}`)
+	assert.Equal(t, 201, first.Code)
+	assert.Equal(t, "", first.Header().Get(ReplayedHeader))
+
+	retry := post(h, "TestHandler_Replay", `{"Film": "Tri-X"}`)
+	assert.Equal(t, 201, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(ReplayedHeader))
+	assert.Equal(t, "/neg/moo", retry.Header().Get("Location"))
+	assert.Equal(t, `{"Film": "Tri-X"}`, retry.Body.String())
+	assert.Equal(t, 1, count)
+
+	// requests without a key are never replayed
+	post(h, "", `{"Film": "Tri-X"}`)
+	post(h, "", `{"Film": "Tri-X"}`)
+	assert.Equal(t, 3, count)
+}
+
+func TestHandler_Reused(t *testing.T) {
+	count := 0
+	h := Handler(NewCache(time.Minute, 0), counting(&count, 201))
+
+	post(h, "TestHandler_Reused", `{"Film": "Tri-X"}`)
+	reused := post(h, "TestHandler_Reused", `{"Film": "HP5"}`)
+	assert.Equal(t, 422, reused.Code)
+	assert.Equal(t, 1, count)
+}
+
+func TestHandler_ServerErrorNotRecorded(t *testing.T) {
+	count := 0
+	h := Handler(NewCache(time.Minute, 0), counting(&count, 503))
+
+	post(h, "TestHandler_ServerErrorNotRecorded", `{"Film": "Tri-X"}`)
+	retry := post(h, "TestHandler_ServerErrorNotRecorded", `{"Film": "Tri-X"}`)
+	assert.Equal(t, 503, retry.Code)
+	assert.Equal(t, "", retry.Header().Get(ReplayedHeader))
+	assert.Equal(t, 2, count)
+}
+
+func TestCache_Expiry(t *testing.T) {
+	now := time.Now()
+	c := NewCache(time.Minute, 0)
+	c.now = func() time.Time { return now }
+
+	c.Store("moo", "fingerprint", &Response{Status: 201})
+	_, r, ok := c.Lookup("moo")
+	assert.True(t, ok)
+	assert.Equal(t, 201, r.Status)
+
+	now = now.Add(2 * time.Minute)
+	_, _, ok = c.Lookup("moo")
+	assert.False(t, ok)
+	assert.Empty(t, c.entries)
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	now := time.Now()
+	c := NewCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	for _, key := range []string{"moo", "oink", "moo", "quack"} {
+		c.Store(key, "fingerprint", &Response{Status: 201})
+		now = now.Add(time.Second)
+	}
+
+	// the oldest entry is evicted, and recording a key anew makes it the newest
+	_, _, ok := c.Lookup("oink")
+	assert.False(t, ok)
+	for _, key := range []string{"moo", "quack"} {
+		_, _, ok = c.Lookup(key)
+		assert.True(t, ok, key)
+	}
+	assert.Equal(t, 2, c.order.Len())
+}
+
+// requests bearing distinct keys proceed concurrently, however many are in flight, and their locks are discarded
+func TestHandler_ManyKeysInFlight(t *testing.T) {
+	const inFlight = 250
+	started := make(chan struct{}, inFlight)
+	release := make(chan struct{})
+	c := NewCache(time.Minute, 0)
+	h := Handler(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(201)
+	}))
+
+	done := make(chan struct{}, inFlight)
+	for i := 0; i < inFlight; i++ {
+		go func(i int) {
+			post(h, "TestHandler_ManyKeysInFlight-"+strconv.Itoa(i), `{"Film": "Tri-X"}`)
+			done <- struct{}{}
+		}(i)
+	}
+
+	for i := 0; i < inFlight; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d requests are in flight", i, inFlight)
+		}
+	}
+
+	close(release)
+	for i := 0; i < inFlight; i++ {
+		<-done
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	assert.Empty(t, c.locks)
+}
@@ -0,0 +1,156 @@
+// Supports proactive content negotiation, per RFC 7231 §5.3: selecting the representation of a resource that is most
+// preferred by the Accept header of a request.
+package media
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A media range of an Accept header, e.g. "text/*;q=0.5"
+type Range struct {
+	// The type of the range, e.g. "text", or "*"
+	Type string
+	// The subtype of the range, e.g. "plain", or "*"
+	Subtype string
+	// The parameters of the range, excluding the quality value.  Parameter names are lower-cased.
+	Params map[string]string
+	// The quality value of the range, between 0 and 1 inclusive.  A quality value of 0 means "not acceptable".
+	Q float64
+}
+
+// Returns true if the range matches the supplied media type, e.g. "text/*" matches "text/plain".  Parameters of the
+// range must be present, with the same value, on the media type.
+func (rng Range) Matches(mediaType string) bool {
+	t, sub, params := split(mediaType)
+
+	if rng.Type != "*" && rng.Type != t {
+		return false
+	}
+
+	if rng.Subtype != "*" && rng.Subtype != sub {
+		return false
+	}
+
+	for name, value := range rng.Params {
+		if params[name] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the precedence of the range, per RFC 7231 §5.3.2: more specific ranges override less specific ranges.
+func (rng Range) precedence() int {
+	switch {
+	case rng.Type == "*":
+		return 0
+	case rng.Subtype == "*":
+		return 1
+	default:
+		return 2 + len(rng.Params)
+	}
+}
+
+// Parses the value of an Accept header into its media ranges, in the order they appear.  Malformed ranges are skipped.
+func ParseAccept(header string) []Range {
+	var ranges []Range
+
+	for _, member := range strings.Split(header, ",") {
+		if strings.TrimSpace(member) == "" {
+			continue
+		}
+
+		t, sub, params := split(member)
+		if t == "" || sub == "" || (t == "*" && sub != "*") {
+			continue
+		}
+
+		rng := Range{Type: t, Subtype: sub, Params: params, Q: 1}
+		if q, ok := params["q"]; ok {
+			delete(params, "q")
+			value, err := strconv.ParseFloat(q, 64)
+			if err != nil || value < 0 || value > 1 {
+				continue
+			}
+			rng.Q = value
+		}
+
+		ranges = append(ranges, rng)
+	}
+
+	return ranges
+}
+
+// Returns the quality value the Accept header assigns to the supplied media type: the quality value of the most specific
+// range that matches it, or 0 if no range matches.  An empty header accepts every media type with a quality value of 1.
+func Quality(header, mediaType string) float64 {
+	if strings.TrimSpace(header) == "" {
+		return 1
+	}
+
+	return quality(ParseAccept(header), mediaType)
+}
+
+func quality(ranges []Range, mediaType string) float64 {
+	q, precedence := 0.0, -1
+	for _, rng := range ranges {
+		if p := rng.precedence(); p > precedence && rng.Matches(mediaType) {
+			q, precedence = rng.Q, p
+		}
+	}
+	return q
+}
+
+// Selects the offered media type most preferred by the Accept header.  Ties are broken by the order of the offers, so
+// offers should be supplied from the most to the least preferred by the server.  An empty header selects the first
+// offer.  Returns the empty string if none of the offers is acceptable.
+func Negotiate(header string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	ranges := ParseAccept(header)
+	qualities := make([]float64, len(offers))
+	indexes := make([]int, len(offers))
+	for i := range offers {
+		qualities[i] = quality(ranges, offers[i])
+		indexes[i] = i
+	}
+
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return qualities[indexes[i]] > qualities[indexes[j]]
+	})
+
+	if best := indexes[0]; qualities[best] > 0 {
+		return offers[best]
+	}
+
+	return ""
+}
+
+// Splits a media type or media range into its lower-cased type and subtype, and its parameters.
+func split(mediaType string) (t, sub string, params map[string]string) {
+	params = map[string]string{}
+	parts := strings.Split(mediaType, ";")
+
+	full := strings.ToLower(strings.TrimSpace(parts[0]))
+	if i := strings.Index(full, "/"); i > 0 && i < len(full)-1 {
+		t, sub = full[:i], full[i+1:]
+	}
+
+	for _, param := range parts[1:] {
+		if i := strings.Index(param, "="); i > 0 {
+			name := strings.ToLower(strings.TrimSpace(param[:i]))
+			params[name] = strings.Trim(strings.TrimSpace(param[i+1:]), "\"")
+		}
+	}
+
+	return t, sub, params
+}
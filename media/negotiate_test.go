@@ -0,0 +1,44 @@
+package media
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	ranges := ParseAccept("text/*;q=0.3, text/html;level=1, */*;q=0.5, bogus, text/plain;q=2")
+
+	assert.Equal(t, []Range{
+		{Type: "text", Subtype: "*", Params: map[string]string{}, Q: 0.3},
+		{Type: "text", Subtype: "html", Params: map[string]string{"level": "1"}, Q: 1},
+		{Type: "*", Subtype: "*", Params: map[string]string{}, Q: 0.5},
+	}, ranges)
+}
+
+func TestQuality(t *testing.T) {
+	// RFC 7231 §5.3.2
+	header := "text/*;q=0.3, text/html;q=0.7, text/html;level=1, text/html;level=2;q=0.4, */*;q=0.5"
+
+	assert.Equal(t, 1.0, Quality(header, "text/html;level=1"))
+	assert.Equal(t, 0.7, Quality(header, "text/html"))
+	assert.Equal(t, 0.3, Quality(header, "text/plain"))
+	assert.Equal(t, 0.5, Quality(header, "image/jpeg"))
+	assert.Equal(t, 0.4, Quality(header, "text/html;level=2"))
+	assert.Equal(t, 0.7, Quality(header, "text/html;level=3"))
+
+	assert.Equal(t, 1.0, Quality("", "image/jpeg"))
+	assert.Equal(t, 0.0, Quality("text/plain", "image/jpeg"))
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "text/plain"}
+
+	assert.Equal(t, "application/json", Negotiate("", offers...))
+	assert.Equal(t, "application/json", Negotiate("*/*", offers...))
+	assert.Equal(t, "text/plain", Negotiate("text/plain", offers...))
+	assert.Equal(t, "text/plain", Negotiate("application/json;q=0.5, text/*", offers...))
+	assert.Equal(t, "application/json", Negotiate("TEXT/PLAIN;q=0.9, Application/JSON", offers...))
+	assert.Equal(t, "", Negotiate("image/png", offers...))
+	assert.Equal(t, "", Negotiate("application/json;q=0, text/plain;q=0", offers...))
+	assert.Equal(t, "", Negotiate("*/*"))
+}
@@ -30,6 +30,29 @@ type WebResource interface {
 	SetUpdated(t time.Time)
 }
 
+// A relationship between a business object and other business objects, e.g. the roll a negative was exposed on, or
+// the scans made of it
+type Relation struct {
+	// The name of the relation, e.g. "roll".  Links to the related resources carry the name as their relation, and
+	// related resources are inlined by naming the relation in the `expand` query parameter.
+	Name string
+	// The URI path of the collection the related resources belong to, e.g. "/roll"
+	Collection string
+	// The business identifiers of the related resources, which may be empty
+	Ids []string
+	// Returns a new, zero business object of the type of the related resources, into which a related resource may be
+	// retrieved
+	New func() WebResource
+}
+
+// Business objects that relate to other business objects implement this interface, so that their representations may
+// link to, or inline, the related resources.
+type Related interface {
+	// Obtain the relations of the business object.  Every relation of its type is returned, including those without
+	// related resources, in a stable order.
+	GetRelations() []Relation
+}
+
 // Encapsulates an ETag and whether it is a weak or strong validator.  The zero value of an ETag is the same as that
 // of the type `string`: a zero-length empty string.
 type Etag string
@@ -42,6 +65,34 @@ func (e Etag) strong() bool {
 	return !strings.HasPrefix(string(e), "W/")
 }
 
+// Returns true if both ETags are strong validators with identical opaque tags.  This is the strong comparison function
+// of RFC 7232 §2.3.2, used when evaluating If-Match preconditions.
+func (e Etag) StrongMatch(other Etag) bool {
+	return e.strong() && other.strong() && e == other
+}
+
+// Returns true if the opaque tags of both ETags are identical, regardless of either being weak.  This is the weak
+// comparison function of RFC 7232 §2.3.2, used when evaluating If-None-Match preconditions.
+func (e Etag) WeakMatch(other Etag) bool {
+	return e.opaque() == other.opaque()
+}
+
+// Returns a weak ETag for a variant of the representation identified by the ETag, e.g. a representation of the same
+// state in another media type.  The opaque tag of the variant is derived from the opaque tag of the ETag and the name
+// of the variant, so variants with different names do not match each other, or the ETag they are derived from.
+func (e Etag) Variant(name string) Etag {
+	opaque := e.opaque()
+	return Etag("W/" + opaque[:len(opaque)-1] + "-" + name + "\"")
+}
+
+// Returns the opaque tag of the ETag, i.e. the quoted string without any weak indicator.
+func (e Etag) opaque() string {
+	if e.strong() {
+		return string(e)
+	}
+	return string(e)[2:]
+}
+
 func (e *Neg) GetId() string {
 	return e.Id
 }
@@ -70,17 +121,18 @@ func (e *Neg) GetEtag() Etag {
 	return Etag(etag.NewEncoder().AddString(e.Id).AddTime(e.Created).AddTime(e.Updated).Encode(true))
 }
 
+// A photographic negative.  Constraints on the state of a Neg are declared by `validate` tags, see package validate.
 type Neg struct {
 	Id          string
 	Created     time.Time
 	Updated     time.Time
-	Film        string
-	EI          int
+	Film        string `validate:"required"`
+	EI          int    `validate:"min=0,max=25600"`
 	Developer   string
 	FrameNumber string
 	Tags        []string
 	Description string
-	Format      string
+	Format      string `validate:"oneof=35mm|120|220|4x5|5x7|8x10"`
 }
 
 func (n *Neg) Store(s store.Api) (id string, err error) {
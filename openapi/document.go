@@ -0,0 +1,595 @@
+package openapi
+
+// The OpenAPI 3 document describing the negtracker API.  Keep it in step with the handlers: the Validate middleware
+// reports requests and responses that do not conform to it.
+const document = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "negtracker",
+    "description": "Tracks photographic negatives.  Errors are described by RFC 7807 problem details.",
+    "version": "1"
+  },
+  "paths": {
+    "/Ping": {
+      "get": {
+        "operationId": "ping",
+        "summary": "Determine whether the server is running",
+        "responses": {
+          "200": {
+            "description": "The server is running",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          }
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "operationId": "getOpenApi",
+        "summary": "Obtain this document",
+        "responses": {
+          "200": {
+            "description": "This document",
+            "content": {"application/json": {"schema": {"type": "object"}}}
+          }
+        }
+      }
+    },
+    "/schema/{type}": {
+      "get": {
+        "operationId": "getSchema",
+        "summary": "Obtain the JSON Schema of the JSON representation of a type of resource, e.g. neg",
+        "parameters": [
+          {"name": "type", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "A JSON Schema (2020-12)",
+            "content": {"application/schema+json": {"schema": {"type": "object"}}}
+          },
+          "404": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/neg": {
+      "get": {
+        "operationId": "listNegs",
+        "summary": "List a page of Negs",
+        "parameters": [
+          {"name": "film", "in": "query", "schema": {"type": "string"}},
+          {"name": "developer", "in": "query", "schema": {"type": "string"}},
+          {"name": "format", "in": "query", "schema": {"type": "string"}},
+          {"name": "tag", "in": "query", "description": "May be repeated; Negs must have every tag",
+            "schema": {"type": "array", "items": {"type": "string"}}, "explode": true},
+          {"name": "ei_min", "in": "query", "schema": {"type": "integer"}},
+          {"name": "ei_max", "in": "query", "schema": {"type": "integer"}},
+          {"name": "created_min", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "created_max", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "updated_min", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "updated_max", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "sort", "in": "query", "description": "A field to sort by, prefixed with - to sort descending",
+            "schema": {"type": "string"}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 500, "default": 50}},
+          {"name": "cursor", "in": "query", "description": "Obtained from the Link header of the previous page",
+            "schema": {"type": "string"}},
+          {"name": "fields", "in": "query", "description": "Comma-separated names of the fields to include, e.g. Film,EI,Tags",
+            "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "A page of Negs",
+            "headers": {
+              "Link": {"description": "The following page, with the relation next", "schema": {"type": "string"}}
+            },
+            "content": {
+              "application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/SparseNeg"}}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/Problem"},
+          "500": {"$ref": "#/components/responses/Problem"},
+          "503": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "post": {
+        "operationId": "createNeg",
+        "summary": "Create a Neg",
+        "parameters": [
+          {"name": "Idempotency-Key", "in": "header", "description": "Retries bearing the same key replay the original response",
+            "schema": {"type": "string", "maxLength": 255}},
+          {"name": "Prefer", "in": "header", "description": "return=representation or return=minimal",
+            "schema": {"type": "string"}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Neg"}}}
+        },
+        "responses": {
+          "201": {
+            "description": "The Neg was created.  The body depends on the return preference of the request.",
+            "headers": {
+              "Location": {"schema": {"type": "string"}},
+              "ETag": {"schema": {"type": "string"}},
+              "Last-Modified": {"schema": {"type": "string"}},
+              "Preference-Applied": {"schema": {"type": "string"}},
+              "Vary": {"schema": {"type": "string"}}
+            },
+            "content": {
+              "text/plain": {"schema": {"type": "string", "description": "The id of the Neg"}},
+              "application/json": {"schema": {"$ref": "#/components/schemas/Neg"}},
+              "application/ld+json": {"schema": {"$ref": "#/components/schemas/Photograph"}},
+              "application/yaml": {"schema": {"type": "string"}},
+              "text/csv": {"schema": {"type": "string"}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/Problem"},
+          "406": {"$ref": "#/components/responses/Problem"},
+          "409": {"$ref": "#/components/responses/Problem"},
+          "422": {"$ref": "#/components/responses/Problem"},
+          "500": {"$ref": "#/components/responses/Problem"},
+          "503": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "options": {
+        "operationId": "describeNegs",
+        "summary": "Describe the methods and request bodies supported by the collection",
+        "responses": {
+          "204": {
+            "description": "The supported methods and request bodies",
+            "headers": {
+              "Allow": {"schema": {"type": "string"}},
+              "Accept-Post": {"schema": {"type": "string"}}
+            }
+          }
+        }
+      }
+    },
+    "/neg/_bulk": {
+      "post": {
+        "operationId": "importNegs",
+        "summary": "Create a Neg from each line of the request",
+        "requestBody": {
+          "required": true,
+          "content": {"application/x-ndjson": {"schema": {"$ref": "#/components/schemas/Neg"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "The outcome of each line, in the order of the request",
+            "content": {"application/x-ndjson": {"schema": {"$ref": "#/components/schemas/BulkResult"}}}
+          },
+          "400": {"$ref": "#/components/responses/Problem"},
+          "413": {"$ref": "#/components/responses/Problem"},
+          "415": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/neg/_mget": {
+      "post": {
+        "operationId": "mgetNegs",
+        "summary": "Retrieve the Negs with each of the listed ids",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/MgetRequest"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "The Negs that were found, in the order their ids were listed, and the ids of those that were not",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/MgetResult"}}}
+          },
+          "400": {"$ref": "#/components/responses/Problem"},
+          "406": {"$ref": "#/components/responses/Problem"},
+          "422": {"$ref": "#/components/responses/Problem"},
+          "500": {"$ref": "#/components/responses/Problem"},
+          "503": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/neg/_export": {
+      "get": {
+        "operationId": "exportNegs",
+        "summary": "Export every Neg selected by the listing parameters",
+        "parameters": [
+          {"name": "film", "in": "query", "schema": {"type": "string"}},
+          {"name": "developer", "in": "query", "schema": {"type": "string"}},
+          {"name": "format", "in": "query", "schema": {"type": "string"}},
+          {"name": "tag", "in": "query", "schema": {"type": "array", "items": {"type": "string"}}, "explode": true},
+          {"name": "ei_min", "in": "query", "schema": {"type": "integer"}},
+          {"name": "ei_max", "in": "query", "schema": {"type": "integer"}},
+          {"name": "created_min", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "created_max", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "updated_min", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "updated_max", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "sort", "in": "query", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The selected Negs",
+            "content": {
+              "application/x-ndjson": {"schema": {"$ref": "#/components/schemas/Neg"}},
+              "text/csv": {"schema": {"type": "string"}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/Problem"},
+          "406": {"$ref": "#/components/responses/Problem"},
+          "500": {"$ref": "#/components/responses/Problem"},
+          "503": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/neg/{id}": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "get": {
+        "operationId": "getNeg",
+        "summary": "Retrieve a Neg",
+        "parameters": [
+          {"name": "If-None-Match", "in": "header", "schema": {"type": "string"}},
+          {"name": "If-Modified-Since", "in": "header", "schema": {"type": "string"}},
+          {"name": "fields", "in": "query", "description": "Comma-separated names of the fields to include, e.g. Film,EI,Tags",
+            "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/SparseNeg"},
+          "304": {"description": "The representation has not been modified"},
+          "400": {"$ref": "#/components/responses/Problem"},
+          "404": {"$ref": "#/components/responses/Problem"},
+          "406": {"$ref": "#/components/responses/Problem"},
+          "410": {"$ref": "#/components/responses/Problem"},
+          "500": {"$ref": "#/components/responses/Problem"},
+          "503": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "put": {
+        "operationId": "replaceNeg",
+        "summary": "Replace the state of a Neg, or create the Neg if it does not exist",
+        "parameters": [
+          {"name": "If-Match", "in": "header", "schema": {"type": "string"}},
+          {"name": "If-None-Match", "in": "header", "schema": {"type": "string"}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Neg"}}}
+        },
+        "responses": {
+          "200": {"$ref": "#/components/responses/Neg"},
+          "201": {
+            "description": "The Neg was created, in the representation selected by the Accept header",
+            "headers": {
+              "Location": {"schema": {"type": "string"}},
+              "ETag": {"schema": {"type": "string"}},
+              "Last-Modified": {"schema": {"type": "string"}},
+              "Vary": {"schema": {"type": "string"}}
+            },
+            "content": {
+              "application/json": {"schema": {"$ref": "#/components/schemas/Neg"}},
+              "application/ld+json": {"schema": {"$ref": "#/components/schemas/Photograph"}},
+              "application/yaml": {"schema": {"type": "string"}},
+              "text/csv": {"schema": {"type": "string"}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/Problem"},
+          "406": {"$ref": "#/components/responses/Problem"},
+          "409": {"$ref": "#/components/responses/Problem"},
+          "410": {"$ref": "#/components/responses/Problem"},
+          "412": {"$ref": "#/components/responses/Problem"},
+          "422": {"$ref": "#/components/responses/Problem"},
+          "428": {"$ref": "#/components/responses/Problem"},
+          "500": {"$ref": "#/components/responses/Problem"},
+          "503": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "patch": {
+        "operationId": "patchNeg",
+        "summary": "Modify a Neg with a JSON Merge Patch or JSON Patch document",
+        "parameters": [
+          {"name": "If-Match", "in": "header", "schema": {"type": "string"}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/merge-patch+json": {"schema": {"type": "object"}},
+            "application/json-patch+json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/PatchOperation"}}}
+          }
+        },
+        "responses": {
+          "200": {"$ref": "#/components/responses/Neg"},
+          "400": {"$ref": "#/components/responses/Problem"},
+          "404": {"$ref": "#/components/responses/Problem"},
+          "406": {"$ref": "#/components/responses/Problem"},
+          "409": {"$ref": "#/components/responses/Problem"},
+          "410": {"$ref": "#/components/responses/Problem"},
+          "412": {"$ref": "#/components/responses/Problem"},
+          "415": {"$ref": "#/components/responses/Problem"},
+          "422": {"$ref": "#/components/responses/Problem"},
+          "500": {"$ref": "#/components/responses/Problem"},
+          "503": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteNeg",
+        "summary": "Delete a Neg, leaving a tombstone",
+        "responses": {
+          "204": {"description": "The Neg was deleted"},
+          "404": {"$ref": "#/components/responses/Problem"},
+          "410": {"$ref": "#/components/responses/Problem"},
+          "500": {"$ref": "#/components/responses/Problem"},
+          "503": {"$ref": "#/components/responses/Problem"}
+        }
+      },
+      "options": {
+        "operationId": "describeNeg",
+        "summary": "Describe the methods and patch documents supported by a Neg",
+        "responses": {
+          "204": {
+            "description": "The supported methods and patch documents",
+            "headers": {
+              "Allow": {"schema": {"type": "string"}},
+              "Accept-Patch": {"schema": {"type": "string"}}
+            }
+          }
+        }
+      }
+    },
+    "/admin/purge": {
+      "post": {
+        "operationId": "purgeTombstones",
+        "summary": "Physically remove the tombstones of deleted Negs",
+        "security": [{"bearer": []}],
+        "parameters": [
+          {"name": "age", "in": "query", "description": "Tombstones older than this duration are purged, e.g. 72h",
+            "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The tombstones were purged",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PurgeResult"}}}
+          },
+          "400": {"$ref": "#/components/responses/Problem"},
+          "401": {"$ref": "#/components/responses/Problem"},
+          "403": {"$ref": "#/components/responses/Problem"},
+          "500": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    },
+    "/admin/backup": {
+      "get": {
+        "operationId": "backupStore",
+        "summary": "Copy the storage layer while it remains in use; only available when it is kept in a local file",
+        "security": [{"bearer": []}],
+        "responses": {
+          "200": {
+            "description": "A consistent copy of the storage layer, in its own format",
+            "headers": {
+              "Content-Disposition": {"schema": {"type": "string"}}
+            },
+            "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}
+          },
+          "401": {"$ref": "#/components/responses/Problem"},
+          "403": {"$ref": "#/components/responses/Problem"},
+          "404": {"$ref": "#/components/responses/Problem"},
+          "500": {"$ref": "#/components/responses/Problem"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer"}
+    },
+    "responses": {
+      "Neg": {
+        "description": "A Neg, in the representation selected by the Accept header",
+        "headers": {
+          "ETag": {"schema": {"type": "string"}},
+          "Last-Modified": {"schema": {"type": "string"}},
+          "Vary": {"schema": {"type": "string"}}
+        },
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/Neg"}},
+          "application/ld+json": {"schema": {"$ref": "#/components/schemas/Photograph"}},
+          "application/yaml": {"schema": {"type": "string"}},
+          "text/csv": {"schema": {"type": "string"}}
+        }
+      },
+      "SparseNeg": {
+        "description": "A Neg, in the representation selected by the Accept header, restricted to the fields named by the fields parameter",
+        "headers": {
+          "ETag": {"schema": {"type": "string"}},
+          "Last-Modified": {"schema": {"type": "string"}},
+          "Vary": {"schema": {"type": "string"}}
+        },
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/SparseNeg"}},
+          "application/ld+json": {"schema": {"$ref": "#/components/schemas/Photograph"}},
+          "application/yaml": {"schema": {"type": "string"}},
+          "text/csv": {"schema": {"type": "string"}}
+        }
+      },
+      "Problem": {
+        "description": "A problem, in the representation selected by the Accept header",
+        "content": {
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}},
+          "application/json": {"schema": {"$ref": "#/components/schemas/Problem"}},
+          "text/plain": {"schema": {"type": "string"}}
+        }
+      }
+    },
+    "schemas": {
+      "Neg": {
+        "type": "object",
+        "description": "A photographic negative",
+        "required": ["Film"],
+        "additionalProperties": false,
+        "properties": {
+          "Id": {"type": "string"},
+          "Created": {"type": "string", "format": "date-time"},
+          "Updated": {"type": "string", "format": "date-time"},
+          "Film": {"type": "string", "minLength": 1},
+          "EI": {"type": "integer", "minimum": 0, "maximum": 25600},
+          "Developer": {"type": "string"},
+          "FrameNumber": {"type": "string"},
+          "Tags": {"type": "array", "nullable": true, "items": {"type": "string"}},
+          "Description": {"type": "string"},
+          "Format": {"type": "string", "enum": ["", "35mm", "120", "220", "4x5", "5x7", "8x10"]},
+          "_links": {"$ref": "#/components/schemas/Links"},
+          "_embedded": {"$ref": "#/components/schemas/Embedded"}
+        }
+      },
+      "SparseNeg": {
+        "type": "object",
+        "description": "A photographic negative, with only the fields named by the fields parameter, if present",
+        "additionalProperties": false,
+        "properties": {
+          "Id": {"type": "string"},
+          "Created": {"type": "string", "format": "date-time"},
+          "Updated": {"type": "string", "format": "date-time"},
+          "Film": {"type": "string"},
+          "EI": {"type": "integer", "minimum": 0, "maximum": 25600},
+          "Developer": {"type": "string"},
+          "FrameNumber": {"type": "string"},
+          "Tags": {"type": "array", "nullable": true, "items": {"type": "string"}},
+          "Description": {"type": "string"},
+          "Format": {"type": "string", "enum": ["", "35mm", "120", "220", "4x5", "5x7", "8x10"]},
+          "_links": {"$ref": "#/components/schemas/Links"}
+        }
+      },
+      "Link": {
+        "type": "object",
+        "description": "A link to a resource",
+        "required": ["href"],
+        "additionalProperties": false,
+        "properties": {"href": {"type": "string"}}
+      },
+      "Links": {
+        "type": "object",
+        "description": "The links of a representation, keyed by relation: to itself, to its collection, and to each of its related resources.  Links are disregarded in request bodies.",
+        "required": ["self"],
+        "additionalProperties": {"type": "array", "items": {"$ref": "#/components/schemas/Link"}},
+        "properties": {
+          "self": {"$ref": "#/components/schemas/Link"},
+          "collection": {"$ref": "#/components/schemas/Link"}
+        }
+      },
+      "Embedded": {
+        "type": "object",
+        "description": "Related resources inlined in a representation, keyed by relation.  Negs relate to no other resources, and are represented without any.  Disregarded in request bodies.",
+        "additionalProperties": {"type": "array", "items": {"type": "object"}}
+      },
+      "Photograph": {
+        "type": "object",
+        "description": "A Neg described using schema.org Photograph terms",
+        "required": ["@context", "@type", "@id", "identifier"],
+        "properties": {
+          "@context": {"type": "string"},
+          "@type": {"type": "string", "enum": ["Photograph"]},
+          "@id": {"type": "string"},
+          "identifier": {"type": "string"},
+          "dateCreated": {"type": "string", "format": "date-time"},
+          "dateModified": {"type": "string", "format": "date-time"},
+          "material": {"type": "string"},
+          "position": {"type": "string"},
+          "description": {"type": "string"},
+          "keywords": {"type": "array", "items": {"type": "string"}},
+          "additionalProperty": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["@type", "name", "value"],
+              "properties": {"@type": {"type": "string"}, "name": {"type": "string"}, "value": {}}
+            }
+          }
+        }
+      },
+      "BulkResult": {
+        "type": "object",
+        "description": "The outcome of importing a line",
+        "required": ["line", "status"],
+        "additionalProperties": false,
+        "properties": {
+          "line": {"type": "integer", "minimum": 1},
+          "id": {"type": "string"},
+          "status": {"type": "integer"},
+          "error": {"type": "string"},
+          "violations": {"$ref": "#/components/schemas/Violations"}
+        }
+      },
+      "MgetRequest": {
+        "type": "object",
+        "description": "The ids of the Negs to retrieve",
+        "required": ["ids"],
+        "additionalProperties": false,
+        "properties": {
+          "ids": {"type": "array", "items": {"type": "string"}}
+        }
+      },
+      "MgetResult": {
+        "type": "object",
+        "description": "The outcome of retrieving the Negs with each of the listed ids",
+        "required": ["docs", "missing"],
+        "additionalProperties": false,
+        "properties": {
+          "docs": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["id", "etag", "doc"],
+              "additionalProperties": false,
+              "properties": {
+                "id": {"type": "string"},
+                "etag": {"type": "string", "description": "The ETag a GET of the Neg would carry"},
+                "doc": {"$ref": "#/components/schemas/Neg"}
+              }
+            }
+          },
+          "missing": {"type": "array", "items": {"type": "string"}, "description": "The listed ids of Negs that do not exist, or have been deleted"}
+        }
+      },
+      "PatchOperation": {
+        "type": "object",
+        "description": "A JSON Patch operation, per RFC 6902",
+        "required": ["op", "path"],
+        "properties": {
+          "op": {"type": "string", "enum": ["add", "remove", "replace", "move", "copy", "test"]},
+          "path": {"type": "string"},
+          "from": {"type": "string"},
+          "value": {}
+        }
+      },
+      "PurgeResult": {
+        "type": "object",
+        "required": ["Purged", "DeletedBefore"],
+        "properties": {
+          "Purged": {"type": "integer", "minimum": 0},
+          "DeletedBefore": {"type": "string", "format": "date-time"}
+        }
+      },
+      "Violations": {
+        "type": "object",
+        "description": "The reasons each offending field was rejected, keyed by field name",
+        "additionalProperties": {"type": "array", "items": {"type": "string"}}
+      },
+      "Problem": {
+        "type": "object",
+        "description": "Problem details, per RFC 7807",
+        "required": ["type", "title", "status"],
+        "properties": {
+          "type": {"type": "string"},
+          "title": {"type": "string"},
+          "status": {"type": "integer"},
+          "detail": {"type": "string"},
+          "instance": {"type": "string"},
+          "requestId": {"type": "string"},
+          "invalid-params": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["name", "reason"],
+              "properties": {"name": {"type": "string"}, "reason": {"type": "string"}}
+            }
+          },
+          "violations": {"$ref": "#/components/schemas/Violations"},
+          "available": {"type": "array", "items": {"type": "string"}}
+        }
+      }
+    }
+  }
+}`
@@ -0,0 +1,142 @@
+// Describes the negtracker API with an OpenAPI 3 document, which is served to clients, and against which requests and
+// responses may be validated.
+//
+// Validation is intended for tests: the Validate middleware reports each request and response that does not conform to
+// the document, so that drift between the handlers and the document is noticed.  Only the subset of OpenAPI used by the
+// document is understood.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/emetsger/negtracker/urlutil/strip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The media type the document is served as
+const MediaType = "application/json"
+
+// A parsed OpenAPI document
+type Document struct {
+	// the document, as decoded by encoding/json
+	root map[string]interface{}
+	// the document, as served to clients
+	raw []byte
+}
+
+// An operation of the document, and the values of the path parameters of the request it was selected by
+type operation struct {
+	// the path item of the operation, which may declare parameters shared by its operations
+	pathItem map[string]interface{}
+	// the operation object
+	op map[string]interface{}
+	// values of the path parameters, keyed by name
+	params map[string]string
+}
+
+// Returns the OpenAPI document describing the negtracker API.  Panics if the document is malformed.
+func Load() *Document {
+	d, err := Parse([]byte(document))
+	if err != nil {
+		panic(err.Error())
+	}
+	return d
+}
+
+// Parses an OpenAPI document in JSON.
+func Parse(data []byte) (*Document, error) {
+	root := map[string]interface{}{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("openapi: malformed document: %w", err)
+	}
+
+	if _, ok := root["paths"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("openapi: document has no paths")
+	}
+
+	return &Document{root: root, raw: data}, nil
+}
+
+// Returns an http.HandlerFunc serving the document
+func (d *Document) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MediaType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(d.raw)))
+		w.WriteHeader(200)
+		_, _ = w.Write(d.raw)
+	}
+}
+
+// Selects the operation of the document matching the method and path of a request.  Literal path segments take
+// precedence over templated segments, e.g. "/neg/_bulk" is selected over "/neg/{id}".  HEAD requests select the GET
+// operation when HEAD is not described.  The returned boolean is false if no operation matches.
+func (d *Document) operation(method, path string) (operation, bool) {
+	segments := split(path)
+
+	var selected operation
+	best := -1
+	for template, item := range d.root["paths"].(map[string]interface{}) {
+		params, literals, ok := match(split(template), segments)
+		if !ok || literals <= best {
+			continue
+		}
+
+		pathItem, _ := item.(map[string]interface{})
+		op, ok := pathItem[strings.ToLower(method)].(map[string]interface{})
+		if !ok && method == http.MethodHead {
+			op, ok = pathItem["get"].(map[string]interface{})
+		}
+		if !ok {
+			continue
+		}
+
+		selected, best = operation{pathItem: pathItem, op: op, params: params}, literals
+	}
+
+	return selected, best > -1
+}
+
+// Returns the parameters of the operation, including those declared by its path item
+func (o operation) parameters() []map[string]interface{} {
+	var params []map[string]interface{}
+	for _, declared := range []interface{}{o.pathItem["parameters"], o.op["parameters"]} {
+		list, _ := declared.([]interface{})
+		for i := range list {
+			if p, ok := list[i].(map[string]interface{}); ok {
+				params = append(params, p)
+			}
+		}
+	}
+	return params
+}
+
+// Matches the segments of a path template against the segments of a path, returning the values of the templated
+// segments, and the number of literal segments.
+func match(template, segments []string) (params map[string]string, literals int, ok bool) {
+	if len(template) != len(segments) {
+		return nil, 0, false
+	}
+
+	params = map[string]string{}
+	for i := range template {
+		if strings.HasPrefix(template[i], "{") && strings.HasSuffix(template[i], "}") {
+			params[template[i][1:len(template[i])-1]] = segments[i]
+		} else if template[i] == segments[i] {
+			literals++
+		} else {
+			return nil, 0, false
+		}
+	}
+
+	return params, literals, true
+}
+
+func split(path string) []string {
+	path = strings.Trim(strip.TrailingSlashes(path), "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,205 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Visits every $ref of the document, which panics if the reference cannot be resolved
+func visitRefs(d *Document, v interface{}) int {
+	count := 0
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if _, ok := value["$ref"]; ok {
+			d.resolve(value)
+			count++
+		}
+		for _, member := range value {
+			count += visitRefs(d, member)
+		}
+	case []interface{}:
+		for _, item := range value {
+			count += visitRefs(d, item)
+		}
+	}
+	return count
+}
+
+func TestLoad(t *testing.T) {
+	d := Load()
+	assert.Equal(t, "3.0.3", d.root["openapi"])
+	assert.True(t, visitRefs(d, d.root) > 0)
+
+	w := httptest.NewRecorder()
+	d.Handler()(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, MediaType, w.Header().Get("Content-Type"))
+	assert.True(t, json.Valid(w.Body.Bytes()))
+}
+
+func TestDocument_Operation(t *testing.T) {
+	d := Load()
+
+	for _, tc := range []struct {
+		method, path, operationId string
+	}{
+		{"GET", "/neg", "listNegs"},
+		{"GET", "/neg/", "listNegs"},
+		{"POST", "/neg", "createNeg"},
+		{"POST", "/neg/_bulk", "importNegs"},
+		{"GET", "/neg/_export", "exportNegs"},
+		{"POST", "/neg/_mget", "mgetNegs"},
+		{"GET", "/neg/moo", "getNeg"},
+		{"HEAD", "/neg/moo", "getNeg"},
+		{"PATCH", "/neg/moo/", "patchNeg"},
+		{"POST", "/admin/purge", "purgeTombstones"},
+		{"GET", "/admin/backup", "backupStore"},
+		{"GET", "/schema/neg", "getSchema"},
+	} {
+		o, ok := d.operation(tc.method, tc.path)
+		require.True(t, ok, "%s %s", tc.method, tc.path)
+		assert.Equal(t, tc.operationId, o.op["operationId"], "%s %s", tc.method, tc.path)
+	}
+
+	o, _ := d.operation("GET", "/neg/moo")
+	assert.Equal(t, map[string]string{"id": "moo"}, o.params)
+
+	_, ok := d.operation("TRACE", "/neg")
+	assert.False(t, ok)
+	_, ok = d.operation("GET", "/neg/moo/cow")
+	assert.False(t, ok)
+}
+
+func TestDocument_ValidateValue(t *testing.T) {
+	d := Load()
+	neg := map[string]interface{}{"$ref": "#/components/schemas/Neg"}
+
+	decode := func(s string) interface{} {
+		var v interface{}
+		require.Nil(t, json.Unmarshal([]byte(s), &v))
+		return v
+	}
+
+	assert.Empty(t, d.validateValue(neg, decode(`{"Film": "Tri-X", "EI": 400, "Tags": null, "Format": "120",
+		"Created": "2020-01-02T03:04:05.006Z"}`), ""))
+
+	assert.Equal(t, []string{
+		"/Film: is required",
+		"/Created: must be an RFC 3339 date-time",
+		"/EI: must be at least 0",
+		"/Format: must be one of [ 35mm 120 220 4x5 5x7 8x10]",
+		"/Speed: is not a known property",
+		"/Tags/1: must be of type string",
+	}, d.validateValue(neg, decode(`{"Created": "yesterday", "EI": -1, "Format": "126", "Speed": 1,
+		"Tags": ["a", 1]}`), ""))
+
+	assert.Equal(t, []string{"body: must be of type object"}, d.validateValue(neg, decode(`[]`), ""))
+	assert.Equal(t, []string{"/EI: must be of type integer"}, d.validateValue(neg, decode(`{"Film": "x", "EI": 1.5}`), ""))
+}
+
+// The Neg schema of the document describes the same properties as the schema generated from model.Neg
+func TestDocument_NegSchema(t *testing.T) {
+	d := Load()
+	documented := d.resolve(map[string]interface{}{"$ref": "#/components/schemas/Neg"})
+	generated := schema.Generate(&model.Neg{})
+
+	var documentedNames, generatedNames []string
+	for name := range documented["properties"].(map[string]interface{}) {
+		documentedNames = append(documentedNames, name)
+	}
+	for name := range generated["properties"].(map[string]interface{}) {
+		generatedNames = append(generatedNames, name)
+	}
+	assert.ElementsMatch(t, generatedNames, documentedNames)
+	assert.ElementsMatch(t, generated["required"], documented["required"])
+}
+
+func TestDocument_Validate(t *testing.T) {
+	d := Load()
+
+	for _, tc := range []struct {
+		name     string
+		req      *http.Request
+		h        http.HandlerFunc
+		expected []string
+	}{
+		{
+			name: "conforming",
+			req:  request("POST", "/neg", "application/json", `{"Film": "Tri-X"}`),
+			h:    respond(201, "text/plain", "moo"),
+		},
+		{
+			name:     "unknown operation",
+			req:      request("TRACE", "/neg", "", ""),
+			h:        respond(405, "", ""),
+			expected: []string{"openapi: request to TRACE /neg does not conform: no operation is described"},
+		},
+		{
+			name: "invalid request",
+			req:  request("GET", "/neg?limit=1000&ei_min=x", "", ""),
+			h:    respond(400, "application/problem+json", `{"type": "/problems/x", "title": "x", "status": 400}`),
+			expected: []string{"openapi: request to GET /neg does not conform: query parameter 'ei_min': " +
+				"must be of type integer; query parameter 'limit': must be at most 500"},
+		},
+		{
+			name:     "undescribed status",
+			req:      request("DELETE", "/neg/moo", "", ""),
+			h:        respond(200, "", ""),
+			expected: []string{"openapi: 200 response to DELETE /neg/moo does not conform: status 200 is not described"},
+		},
+		{
+			name:     "undescribed media type",
+			req:      request("GET", "/neg/moo", "", ""),
+			h:        respond(200, "application/xml", "<neg/>"),
+			expected: []string{"openapi: 200 response to GET /neg/moo does not conform: Content-Type 'application/xml'"},
+		},
+		{
+			name: "invalid response",
+			req:  request("GET", "/neg/moo", "", ""),
+			h:    respond(404, "application/problem+json", `{"title": "Not Found", "status": "404"}`),
+			expected: []string{"openapi: 404 response to GET /neg/moo does not conform: /type: is required; " +
+				"/status: must be of type integer"},
+		},
+		{
+			name:     "invalid line",
+			req:      request("POST", "/neg/_bulk", "application/x-ndjson", "{\"Film\": \"Tri-X\"}\n\n{\"EI\": 1}"),
+			h:        respond(200, "application/x-ndjson", "{\"line\": 1, \"status\": 201}\n"),
+			expected: []string{"openapi: request to POST /neg/_bulk does not conform: line 3: /Film: is required"},
+		},
+	} {
+		var reported []string
+		w := httptest.NewRecorder()
+		d.Validate(tc.h, func(v Violation) { reported = append(reported, v.Error()) }).ServeHTTP(w, tc.req)
+
+		require.Len(t, reported, len(tc.expected), "%s: %v", tc.name, reported)
+		for i := range tc.expected {
+			assert.True(t, strings.HasPrefix(reported[i], tc.expected[i]), "%s: %s", tc.name, reported[i])
+		}
+	}
+}
+
+func request(method, path, contentType, body string) *http.Request {
+	r := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+func respond(status int, contentType, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}
+}
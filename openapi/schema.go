@@ -0,0 +1,180 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Validates the JSON value v, as decoded by encoding/json, against a schema object of the document.  Returns a reason
+// for each way in which v does not conform, prefixed by the location of the offending value, e.g. "/EI: must be at
+// least 0".
+//
+// The subset of the OpenAPI 3.0 schema object used by the document is supported: $ref, type, nullable, enum, format
+// (date-time), minimum, maximum, minLength, maxLength, required, properties, additionalProperties, and items.
+func (d *Document) validateValue(schema map[string]interface{}, v interface{}, at string) []string {
+	schema = d.resolve(schema)
+	if len(schema) == 0 {
+		// the empty schema permits any value
+		return nil
+	}
+
+	if v == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable || schema["type"] == nil {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: must not be null", location(at))}
+	}
+
+	if t, ok := schema["type"].(string); ok && !isType(v, t) {
+		return []string{fmt.Sprintf("%s: must be of type %s", location(at), t)}
+	}
+
+	var reasons []string
+	fail := func(format string, args ...interface{}) {
+		reasons = append(reasons, location(at)+": "+fmt.Sprintf(format, args...))
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !contains(enum, v) {
+		fail("must be one of %v", enum)
+	}
+
+	switch value := v.(type) {
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && value < min {
+			fail("must be at least %v", min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && value > max {
+			fail("must be at most %v", max)
+		}
+	case string:
+		length := float64(len([]rune(value)))
+		if min, ok := schema["minLength"].(float64); ok && length < min {
+			fail("must be at least %v characters long", min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && length > max {
+			fail("must be at most %v characters long", max)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				fail("must be an RFC 3339 date-time")
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i := range value {
+				reasons = append(reasons, d.validateValue(items, value[i], fmt.Sprintf("%s/%d", at, i))...)
+			}
+		}
+	case map[string]interface{}:
+		reasons = append(reasons, d.validateObject(schema, value, at)...)
+	}
+
+	return reasons
+}
+
+func (d *Document) validateObject(schema map[string]interface{}, obj map[string]interface{}, at string) []string {
+	var reasons []string
+
+	required, _ := schema["required"].([]interface{})
+	for _, name := range required {
+		if _, ok := obj[name.(string)]; !ok {
+			reasons = append(reasons, fmt.Sprintf("%s: is required", location(at+"/"+name.(string))))
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	// visit members in a stable order, so the reasons are deterministic
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			reasons = append(reasons, d.validateValue(property, obj[name], at+"/"+name)...)
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				reasons = append(reasons, fmt.Sprintf("%s: is not a known property", location(at+"/"+name)))
+			}
+		case map[string]interface{}:
+			reasons = append(reasons, d.validateValue(additional, obj[name], at+"/"+name)...)
+		}
+	}
+
+	return reasons
+}
+
+// Returns the schema referenced by the $ref of the supplied schema, or the supplied schema if it has no $ref.  Only
+// references to components of the document are supported; a reference that cannot be resolved panics, as the document
+// is malformed.
+func (d *Document) resolve(schema map[string]interface{}) map[string]interface{} {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return schema
+	}
+
+	var target interface{} = d.root
+	for _, name := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		obj, _ := target.(map[string]interface{})
+		if target, ok = obj[name]; !ok {
+			panic(fmt.Sprintf("openapi: unresolvable reference '%s'", ref))
+		}
+	}
+
+	resolved, ok := target.(map[string]interface{})
+	if !ok {
+		panic(fmt.Sprintf("openapi: reference '%s' does not identify an object", ref))
+	}
+	return d.resolve(resolved)
+}
+
+// Returns true if the JSON value v is of the supplied schema type
+func isType(v interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	}
+	return false
+}
+
+func contains(values []interface{}, v interface{}) bool {
+	for i := range values {
+		if values[i] == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the location of a value for use in a reason: its JSON Pointer, or "body" for the root value
+func location(at string) string {
+	if at == "" {
+		return "body"
+	}
+	return at
+}
@@ -0,0 +1,232 @@
+package openapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// A failure of a request, or of the response to it, to conform to the document
+type Violation struct {
+	// The method of the request
+	Method string
+	// The path of the request
+	Path string
+	// The status of the response, or zero if the request does not conform
+	Status int
+	// Describes each way in which the request or response does not conform
+	Reasons []string
+}
+
+func (v Violation) Error() string {
+	subject := "request"
+	if v.Status != 0 {
+		subject = fmt.Sprintf("%d response", v.Status)
+	}
+	return fmt.Sprintf("openapi: %s to %s %s does not conform: %s", subject, v.Method, v.Path,
+		strings.Join(v.Reasons, "; "))
+}
+
+// Returns an http.Handler that validates each request, and the response written by h, against the document.  Each
+// request or response that does not conform is reported; requests are passed to h regardless, and responses are not
+// altered.  Responses to requests that do not select an operation of the document are not validated.
+//
+// Response bodies are retained in memory until they have been validated, so Validate is not suited to production use.
+func (d *Document) Validate(h http.Handler, report func(v Violation)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &bytes.Buffer{}
+		_, _ = io.Copy(body, r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body.Bytes()))
+
+		o, ok := d.operation(r.Method, r.URL.Path)
+		if !ok {
+			report(Violation{Method: r.Method, Path: r.URL.Path,
+				Reasons: []string{"no operation is described for the method and path"}})
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if reasons := d.validateRequest(o, r, body.Bytes()); len(reasons) > 0 {
+			report(Violation{Method: r.Method, Path: r.URL.Path, Reasons: reasons})
+		}
+
+		rec := &recorder{ResponseWriter: w, body: &bytes.Buffer{}}
+		h.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = 200
+		}
+
+		if reasons := d.validateResponse(o, r, rec); len(reasons) > 0 {
+			report(Violation{Method: r.Method, Path: r.URL.Path, Status: rec.status, Reasons: reasons})
+		}
+	})
+}
+
+// Validates the parameters and body of a request against the operation it selected
+func (d *Document) validateRequest(o operation, r *http.Request, body []byte) []string {
+	var reasons []string
+
+	for _, p := range o.parameters() {
+		name, _ := p["name"].(string)
+		required, _ := p["required"].(bool)
+		schema, _ := p["schema"].(map[string]interface{})
+
+		var values []string
+		switch p["in"] {
+		case "path":
+			values = []string{o.params[name]}
+		case "query":
+			values = r.URL.Query()[name]
+		case "header":
+			values = r.Header[http.CanonicalHeaderKey(name)]
+		}
+
+		if len(values) == 0 || (len(values) == 1 && values[0] == "") {
+			if required {
+				reasons = append(reasons, fmt.Sprintf("%s parameter '%s' is required", p["in"], name))
+			}
+			continue
+		}
+
+		for _, value := range values {
+			for _, reason := range d.validateValue(schema, parameterValue(d.resolve(schema), value), "") {
+				reasons = append(reasons, fmt.Sprintf("%s parameter '%s'%s", p["in"], name,
+					strings.TrimPrefix(reason, "body")))
+			}
+		}
+	}
+
+	requestBody, ok := o.op["requestBody"].(map[string]interface{})
+	if !ok {
+		return reasons
+	}
+
+	if len(body) == 0 {
+		if required, _ := requestBody["required"].(bool); required {
+			reasons = append(reasons, "request body is required")
+		}
+		return reasons
+	}
+
+	content, _ := requestBody["content"].(map[string]interface{})
+	return append(reasons, d.validateContent(content, r.Header.Get("Content-Type"), body)...)
+}
+
+// Validates the status and body of a response against the operation selected by its request
+func (d *Document) validateResponse(o operation, r *http.Request, rec *recorder) []string {
+	responses, _ := o.op["responses"].(map[string]interface{})
+	response, ok := responses[strconv.Itoa(rec.status)].(map[string]interface{})
+	if !ok {
+		if response, ok = responses["default"].(map[string]interface{}); !ok {
+			return []string{fmt.Sprintf("status %d is not described", rec.status)}
+		}
+	}
+	response = d.resolve(response)
+
+	content, _ := response["content"].(map[string]interface{})
+	if len(content) == 0 || r.Method == http.MethodHead || rec.body.Len() == 0 {
+		if len(content) == 0 && rec.body.Len() > 0 {
+			return []string{"response body is not described"}
+		}
+		return nil
+	}
+
+	return d.validateContent(content, rec.Header().Get("Content-Type"), rec.body.Bytes())
+}
+
+// Validates a request or response body against the media type objects of the content it is described by.  JSON bodies
+// are validated against the schema of their media type; bodies of other media types are not inspected.
+func (d *Document) validateContent(content map[string]interface{}, contentType string, body []byte) []string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return []string{fmt.Sprintf("Content-Type '%s' is malformed", contentType)}
+	}
+
+	described, ok := content[mediaType].(map[string]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("Content-Type '%s' is not described", mediaType)}
+	}
+
+	schema, _ := described["schema"].(map[string]interface{})
+
+	switch {
+	case mediaType == "application/x-ndjson":
+		var reasons []string
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(nil, len(body)+1)
+		for n := 1; scanner.Scan(); n++ {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				for _, reason := range d.validateJson(schema, line) {
+					reasons = append(reasons, fmt.Sprintf("line %d: %s", n, reason))
+				}
+			}
+		}
+		return reasons
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return d.validateJson(schema, body)
+	default:
+		return nil
+	}
+}
+
+func (d *Document) validateJson(schema map[string]interface{}, data []byte) []string {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []string{"body is not well-formed JSON"}
+	}
+	return d.validateValue(schema, v, "")
+}
+
+// Converts the string value of a parameter to the JSON value its schema describes, so it may be validated as such.
+// Values that cannot be converted are returned as strings, and fail validation.
+func parameterValue(schema map[string]interface{}, value string) interface{} {
+	switch schema["type"] {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "array":
+		// each occurrence of an exploded parameter is an item of the array
+		return []interface{}{value}
+	}
+	return value
+}
+
+// An http.ResponseWriter that passes the response through, retaining its status and a copy of its body
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   *bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = 200
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *recorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
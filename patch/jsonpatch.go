@@ -0,0 +1,442 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	opAdd     = "add"
+	opRemove  = "remove"
+	opReplace = "replace"
+	opMove    = "move"
+	opCopy    = "copy"
+	opTest    = "test"
+)
+
+// The JSON Patch document is not well-formed, e.g. it is not a JSON array, or an operation is missing a required member
+var MalformedErr = errors.New("patch: malformed JSON Patch document")
+
+// A `test` operation of the JSON Patch document failed
+var TestFailedErr = errors.New("patch: test operation failed")
+
+// An operation of the JSON Patch document could not be applied to the target document, e.g. its path does not exist
+var UnprocessableErr = errors.New("patch: operation could not be applied")
+
+// A single operation of a JSON Patch (RFC 6902) document
+type Operation struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+// Describes the failure to apply a JSON Patch document.  Error is marshaled to JSON so that clients may determine the
+// offending operation.
+//
+// Use errors.Is with MalformedErr, TestFailedErr, or UnprocessableErr to determine the kind of failure.
+type Error struct {
+	// The zero-based index of the offending operation, or -1 if the patch document as a whole is malformed
+	Index int `json:"index"`
+	// The `op` member of the offending operation, if known
+	Op string `json:"op,omitempty"`
+	// The `path` member of the offending operation, if known
+	Path string `json:"path,omitempty"`
+	// A human-readable explanation of the failure
+	Reason string `json:"reason"`
+	kind   error
+}
+
+func (e *Error) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%s: %s", e.kind.Error(), e.Reason)
+	}
+	return fmt.Sprintf("%s: operation %d (%s %s): %s", e.kind.Error(), e.Index, e.Op, e.Path, e.Reason)
+}
+
+func (e *Error) Is(target error) bool {
+	return e.kind == target
+}
+
+// Applies a JSON Patch (RFC 6902) to the supplied JSON document, returning the patched document.
+//
+// Patches are applied atomically: if any operation fails, an *Error is returned and no patched document is produced.
+// The supplied document is never modified.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var ops []Operation
+	var target interface{}
+	var err error
+
+	if ops, err = ParseOperations(patch); err != nil {
+		return nil, err
+	}
+
+	if target, err = decode(doc); err != nil {
+		return nil, fmt.Errorf("patch: malformed document: %w", err)
+	}
+
+	for i := range ops {
+		if target, err = ops[i].apply(target); err != nil {
+			var perr *Error
+			if errors.As(err, &perr) {
+				perr.Index = i
+				perr.Op = ops[i].Op
+				perr.Path = ops[i].Path
+			}
+			return nil, err
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+// Parses and validates a JSON Patch document, returning its operations.  An *Error wrapping MalformedErr is returned
+// if the document is not well-formed.
+func ParseOperations(patch []byte) ([]Operation, error) {
+	var raw []map[string]json.RawMessage
+
+	if err := json.Unmarshal(patch, &raw); err != nil {
+		return nil, &Error{Index: -1, Reason: "patch must be a JSON array of operations: " + err.Error(),
+			kind: MalformedErr}
+	}
+
+	ops := make([]Operation, len(raw))
+	for i := range raw {
+		malformed := func(reason string) error {
+			return &Error{Index: i, Op: ops[i].Op, Path: ops[i].Path, Reason: reason, kind: MalformedErr}
+		}
+
+		if err := member(raw[i], "op", &ops[i].Op); err != nil {
+			return nil, malformed(err.Error())
+		}
+
+		if err := member(raw[i], "path", &ops[i].Path); err != nil {
+			return nil, malformed(err.Error())
+		}
+
+		if _, err := parsePointer(ops[i].Path); err != nil {
+			return nil, malformed(err.Error())
+		}
+
+		switch ops[i].Op {
+		case opAdd, opReplace, opTest:
+			if err := member(raw[i], "value", &ops[i].Value); err != nil {
+				return nil, malformed(err.Error())
+			}
+		case opMove, opCopy:
+			if err := member(raw[i], "from", &ops[i].From); err != nil {
+				return nil, malformed(err.Error())
+			}
+			if _, err := parsePointer(ops[i].From); err != nil {
+				return nil, malformed(err.Error())
+			}
+		case opRemove:
+		default:
+			return nil, malformed(fmt.Sprintf("unknown op '%s'", ops[i].Op))
+		}
+	}
+
+	return ops, nil
+}
+
+// Decodes the required, named member of a raw operation into v.  String members must be JSON strings, and `value`
+// members are decoded preserving the representation of numbers.
+func member(raw map[string]json.RawMessage, name string, v interface{}) error {
+	m, ok := raw[name]
+	if !ok {
+		return fmt.Errorf("missing required member '%s'", name)
+	}
+
+	if value, ok := v.(*interface{}); ok {
+		var err error
+		*value, err = decode(m)
+		return err
+	}
+
+	if err := json.Unmarshal(m, v); err != nil {
+		return fmt.Errorf("member '%s' is invalid: %s", name, err.Error())
+	}
+
+	return nil
+}
+
+func (o Operation) apply(doc interface{}) (interface{}, error) {
+	path, _ := parsePointer(o.Path)
+
+	switch o.Op {
+	case opAdd:
+		return add(doc, path, o.Value)
+	case opRemove:
+		return remove(doc, path)
+	case opReplace:
+		if len(path) == 0 {
+			return o.Value, nil
+		}
+		if doc, err := remove(doc, path); err != nil {
+			return nil, err
+		} else {
+			return add(doc, path, o.Value)
+		}
+	case opMove:
+		from, _ := parsePointer(o.From)
+		if len(path) > len(from) && isPrefix(from, path) {
+			return nil, unprocessable("'from' must not be a prefix of 'path'")
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = remove(doc, from); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case opCopy:
+		from, _ := parsePointer(o.From)
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(value))
+	case opTest:
+		value, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(value, o.Value) {
+			return nil, &Error{Reason: "value does not match", kind: TestFailedErr}
+		}
+		return doc, nil
+	}
+
+	panic(fmt.Sprintf("patch: unhandled op '%s'", o.Op))
+}
+
+// Adds value to the location in node referenced by the path tokens, returning the modified node.
+func add(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	if len(path) > 1 {
+		return descend(node, path, func(child interface{}) (interface{}, error) {
+			return add(child, path[1:], value)
+		})
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		n[path[0]] = value
+		return n, nil
+	case []interface{}:
+		if path[0] == "-" {
+			return append(n, value), nil
+		}
+		i, err := index(path[0], len(n)+1)
+		if err != nil {
+			return nil, err
+		}
+		n = append(n, nil)
+		copy(n[i+1:], n[i:])
+		n[i] = value
+		return n, nil
+	}
+
+	return nil, unprocessable(fmt.Sprintf("cannot add member '%s' to a scalar value", path[0]))
+}
+
+// Removes the value at the location in node referenced by the path tokens, returning the modified node.
+func remove(node interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, unprocessable("cannot remove the root of the document")
+	}
+
+	if len(path) > 1 {
+		return descend(node, path, func(child interface{}) (interface{}, error) {
+			return remove(child, path[1:])
+		})
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if _, ok := n[path[0]]; !ok {
+			return nil, unprocessable(fmt.Sprintf("member '%s' does not exist", path[0]))
+		}
+		delete(n, path[0])
+		return n, nil
+	case []interface{}:
+		i, err := index(path[0], len(n))
+		if err != nil {
+			return nil, err
+		}
+		return append(n[:i], n[i+1:]...), nil
+	}
+
+	return nil, unprocessable(fmt.Sprintf("member '%s' does not exist", path[0]))
+}
+
+// Returns the value at the location in node referenced by the path tokens.
+func get(node interface{}, path []string) (interface{}, error) {
+	for i := range path {
+		child, err := childOf(node, path[i])
+		if err != nil {
+			return nil, err
+		}
+		node = child
+	}
+
+	return node, nil
+}
+
+// Applies f to the child of node referenced by the first path token, replacing the child with the result of f.
+func descend(node interface{}, path []string, f func(child interface{}) (interface{}, error)) (interface{}, error) {
+	child, err := childOf(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if child, err = f(child); err != nil {
+		return nil, err
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		n[path[0]] = child
+	case []interface{}:
+		i, _ := index(path[0], len(n))
+		n[i] = child
+	}
+
+	return node, nil
+}
+
+func childOf(node interface{}, token string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if child, ok := n[token]; ok {
+			return child, nil
+		}
+	case []interface{}:
+		i, err := index(token, len(n))
+		if err != nil {
+			return nil, err
+		}
+		return n[i], nil
+	}
+
+	return nil, unprocessable(fmt.Sprintf("member '%s' does not exist", token))
+}
+
+// Parses an array index token, which must be less than max.
+func index(token string, max int) (int, error) {
+	// leading zeros are not permitted by RFC 6901 §4
+	if len(token) > 1 && token[0] == '0' {
+		return 0, unprocessable(fmt.Sprintf("invalid array index '%s'", token))
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, unprocessable(fmt.Sprintf("invalid array index '%s'", token))
+	}
+
+	if i >= max {
+		return 0, unprocessable(fmt.Sprintf("array index '%s' is out of bounds", token))
+	}
+
+	return i, nil
+}
+
+// Parses a JSON Pointer (RFC 6901) into its unescaped reference tokens.  The empty pointer references the whole
+// document, and results in zero tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON Pointer '%s', must begin with '/'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(tokens[i], "~1", "/", -1), "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(value))
+		for k := range value {
+			c[k] = deepCopy(value[k])
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(value))
+		for i := range value {
+			c[i] = deepCopy(value[i])
+		}
+		return c
+	}
+
+	return v
+}
+
+// Compares two decoded JSON values per RFC 6902 §4.6: numbers are equal if their values are numerically equal.
+func equal(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		if an == bn {
+			return true
+		}
+		af, aerr := an.Float64()
+		bf, berr := bn.Float64()
+		return aerr == nil && berr == nil && af == bf
+	}
+
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k := range av {
+			if other, ok := bv[k]; !ok || !equal(av[k], other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func unprocessable(reason string) error {
+	return &Error{Reason: reason, kind: UnprocessableErr}
+}
@@ -0,0 +1,96 @@
+package patch
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// Test cases from RFC 6902 Appendix A
+func Test_ApplyRfcExamples(t *testing.T) {
+	cases := []struct {
+		doc, patch, expected string
+	}{
+		// A.1 - A.10
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			`{"foo":"bar","child":{"grandchild":{}}}`},
+		// A.11 ignoring unrecognized elements
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`},
+		// A.14 ~ escape ordering
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+		// A.16 adding an array value
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			`{"foo":["bar",["abc","def"]]}`},
+		// copy, and replacing the whole document
+		{`{"foo":["bar"]}`, `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"add","path":"/baz/-","value":"qux"}]`,
+			`{"foo":["bar"],"baz":["bar","qux"]}`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"","value":{"baz":"qux"}}]`, `{"baz":"qux"}`},
+		// numeric equality
+		{`{"EI":400}`, `[{"op":"test","path":"/EI","value":400.0}]`, `{"EI":400}`},
+	}
+
+	for i := range cases {
+		result, err := Apply([]byte(cases[i].doc), []byte(cases[i].patch))
+		require.Nil(t, err, "case %d", i)
+		assert.JSONEq(t, cases[i].expected, string(result), "case %d", i)
+	}
+}
+
+func Test_ApplyFailures(t *testing.T) {
+	cases := []struct {
+		doc, patch string
+		kind       error
+		index      int
+	}{
+		// A.8 testing a value, error
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, TestFailedErr, 0},
+		// A.12 adding to a nonexistent target
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, UnprocessableErr, 0},
+		// A.15 comparing strings and numbers
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, TestFailedErr, 0},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/1"}]`, UnprocessableErr, 0},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/01","value":"baz"}]`, UnprocessableErr, 0},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, UnprocessableErr, 0},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/foo"},{"op":"replace","path":"/foo","value":1}]`,
+			UnprocessableErr, 1},
+		// A.13 invalid JSON Patch document
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","op":"remove"}]`, UnprocessableErr, 0},
+		{`{"foo":"bar"}`, `{"op":"add","path":"/baz","value":"qux"}`, MalformedErr, -1},
+		{`{"foo":"bar"}`, `[{"op":"frob","path":"/baz"}]`, MalformedErr, 0},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`, MalformedErr, 0},
+		{`{"foo":"bar"}`, `[{"op":"test","path":"/foo","value":"bar"},{"op":"copy","path":"/baz"}]`, MalformedErr, 1},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"baz","value":1}]`, MalformedErr, 0},
+	}
+
+	for i := range cases {
+		_, err := Apply([]byte(cases[i].doc), []byte(cases[i].patch))
+		require.NotNil(t, err, "case %d", i)
+		assert.True(t, errors.Is(err, cases[i].kind), "case %d: %v", i, err)
+
+		var perr *Error
+		require.True(t, errors.As(err, &perr), "case %d", i)
+		assert.Equal(t, cases[i].index, perr.Index, "case %d", i)
+	}
+}
+
+// the original document must not be modified when a patch fails part way through
+func Test_ApplyAtomic(t *testing.T) {
+	doc := []byte(`{"foo":["bar","baz"]}`)
+	_, err := Apply(doc, []byte(`[{"op":"remove","path":"/foo/0"},{"op":"test","path":"/foo/0","value":"bar"}]`))
+	require.True(t, errors.Is(err, TestFailedErr))
+	assert.JSONEq(t, `{"foo":["bar","baz"]}`, string(doc))
+}
@@ -0,0 +1,67 @@
+// Provides for the application of patch documents to the JSON representations of business objects.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Applies a JSON Merge Patch (RFC 7396) to the supplied JSON document, returning the patched document.
+//
+// Members of the patch with a null value are removed from the document, object members are merged recursively, and any
+// other value in the patch replaces the value in the document.  An error is returned if either the document or the
+// patch is not well-formed JSON.
+func Merge(doc, patch []byte) ([]byte, error) {
+	var target, mergePatch interface{}
+	var err error
+
+	if target, err = decode(doc); err != nil {
+		return nil, fmt.Errorf("patch: malformed document: %w", err)
+	}
+
+	if mergePatch, err = decode(patch); err != nil {
+		return nil, fmt.Errorf("patch: malformed merge patch: %w", err)
+	}
+
+	return json.Marshal(merge(target, mergePatch))
+}
+
+// Implements the MergePatch(Target, Patch) function of RFC 7396 §2
+func merge(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{}, len(patchObj))
+	}
+
+	for name, value := range patchObj {
+		if value == nil {
+			delete(targetObj, name)
+		} else {
+			targetObj[name] = merge(targetObj[name], value)
+		}
+	}
+
+	return targetObj
+}
+
+// Decodes a JSON document, preserving the representation of numbers, and insuring there is no trailing content.
+func decode(b []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected content following JSON value at offset %d", dec.InputOffset())
+	}
+
+	return v, nil
+}
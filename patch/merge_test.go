@@ -0,0 +1,55 @@
+package patch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// Test cases from RFC 7396 Appendix A
+func Test_MergeRfcExamples(t *testing.T) {
+	cases := []struct {
+		doc, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for i := range cases {
+		result, err := Merge([]byte(cases[i].doc), []byte(cases[i].patch))
+		require.Nil(t, err)
+		assert.JSONEq(t, cases[i].expected, string(result), "case %d", i)
+	}
+}
+
+func Test_MergePreservesNumbers(t *testing.T) {
+	result, err := Merge([]byte(`{"EI":400}`), []byte(`{"Film":"Tri-X"}`))
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"EI":400,"Film":"Tri-X"}`, string(result))
+}
+
+func Test_MergeMalformedPatch(t *testing.T) {
+	_, err := Merge([]byte(`{"a":"b"}`), []byte(`{"a":`))
+	assert.NotNil(t, err)
+
+	_, err = Merge([]byte(`{"a":"b"}`), []byte(`{"a":"c"} {"b":"d"}`))
+	assert.NotNil(t, err)
+}
+
+func Test_MergeMalformedDocument(t *testing.T) {
+	_, err := Merge([]byte(`moo`), []byte(`{"a":"c"}`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,247 @@
+// Dispatches HTTP requests to handlers by the path and method of the request.
+//
+// Routes are registered with a path template, e.g. "/neg/{id}/scans", composed of literal segments and named parameter
+// segments enclosed in braces.  Each segment of a request path is percent-decoded before it is matched, so a parameter
+// may contain characters that are reserved in a path, e.g. an encoded slash.  Trailing slashes are insignificant:
+// "/neg/" and "/neg" select the same route.
+//
+// When more than one template matches a path, the template whose leftmost differing segment is a literal wins, so
+// "/neg/_bulk" is preferred over "/neg/{id}".
+package router
+
+import (
+	"context"
+	"fmt"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/urlutil/strip"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Dispatches requests to the handlers registered for their path and method.
+//
+// A request whose path matches no template results in a 404.  A request whose path matches a template, but whose method
+// has no handler, results in a 405 listing the methods of the template in the Allow header.  HEAD requests are
+// dispatched to the GET handler of a template unless a HEAD handler is registered, and OPTIONS requests are answered
+// with the Allow header unless an OPTIONS handler is registered.
+type Router struct {
+	// The routes shared by a router and the routers returned by Sub
+	routes *[]*route
+	// Prepended to the templates registered with the router
+	prefix string
+}
+
+// A path template, and the handlers registered for it
+type route struct {
+	template string
+	segments []segment
+	// handlers keyed by method
+	handlers map[string]http.Handler
+	// methods in the order they were registered
+	methods []string
+}
+
+// A segment of a path template: either a literal, or a named parameter
+type segment struct {
+	value string
+	param bool
+}
+
+// Key of the path parameters stored in the context of a request
+type paramsKey struct{}
+
+// Returns a new Router with no routes
+func New() *Router {
+	return &Router{routes: &[]*route{}}
+}
+
+// Returns a Router that registers routes beneath the supplied prefix, sharing the routes of this Router.  Used to
+// register a resource and its sub-resources, e.g.:
+//   negs := rt.Sub("/neg")
+//   negs.HandleFunc(http.MethodGet, "/{id}", get)
+//   negs.HandleFunc(http.MethodGet, "/{id}/scans", listScans)
+func (rt *Router) Sub(prefix string) *Router {
+	return &Router{routes: rt.routes, prefix: rt.prefix + strip.TrailingSlashes(prefix)}
+}
+
+// Registers the handler for requests with the supplied method whose path matches the template.  Panics if the template
+// is malformed, or if a handler is already registered for the method and template.
+func (rt *Router) Handle(method, template string, h http.Handler) {
+	template = normalize(rt.prefix + template)
+	segments := parseTemplate(template)
+
+	var target *route
+	for _, candidate := range *rt.routes {
+		if candidate.template == template {
+			target = candidate
+			break
+		}
+	}
+
+	if target == nil {
+		target = &route{template: template, segments: segments, handlers: map[string]http.Handler{}}
+		*rt.routes = append(*rt.routes, target)
+	}
+
+	if _, exists := target.handlers[method]; exists {
+		panic(fmt.Sprintf("router: handler for %s %s already registered", method, template))
+	}
+
+	target.handlers[method] = h
+	target.methods = append(target.methods, method)
+}
+
+// Registers the handler function for requests with the supplied method whose path matches the template
+func (rt *Router) HandleFunc(method, template string, h http.HandlerFunc) {
+	rt.Handle(method, template, h)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments, err := splitPath(r.URL.EscapedPath())
+	if err != nil {
+		handler.MalformedRequest(w, r, fmt.Sprintf("Malformed request path: %s", err.Error()))
+		return
+	}
+
+	var best *route
+	var params map[string]string
+	for _, candidate := range *rt.routes {
+		if p, ok := candidate.match(segments); ok && (best == nil || candidate.precedes(best)) {
+			best, params = candidate, p
+		}
+	}
+
+	if best == nil {
+		handler.NotFound(w, r)
+		return
+	}
+
+	h, ok := best.handlers[r.Method]
+	if !ok && r.Method == http.MethodHead {
+		// the server discards the body written in response to a HEAD, leaving the headers of the GET response
+		h, ok = best.handlers[http.MethodGet]
+	}
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Allow", strings.Join(best.allow(), ", "))
+		if !ok {
+			h, ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(204) }), true
+		}
+	}
+
+	if !ok {
+		handler.MethodNotAllowed(w, r, best.allow()...)
+		return
+	}
+
+	h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), paramsKey{}, params)))
+}
+
+// Returns the value of the named path parameter of a request dispatched by a Router, or the empty string if the
+// template of the route has no such parameter.  The value is percent-decoded.
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+// Returns the methods supported by the route, in the order they were registered.  HEAD is implied by GET, and OPTIONS
+// is always supported.
+func (rt *route) allow() []string {
+	var allowed []string
+	for _, method := range rt.methods {
+		allowed = append(allowed, method)
+		if _, head := rt.handlers[http.MethodHead]; method == http.MethodGet && !head {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+
+	if _, options := rt.handlers[http.MethodOptions]; !options {
+		allowed = append(allowed, http.MethodOptions)
+	}
+
+	return allowed
+}
+
+// Matches the decoded segments of a request path against the template of the route, returning the values of its
+// parameters.
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, s := range rt.segments {
+		switch {
+		case s.param && segments[i] != "":
+			params[s.value] = segments[i]
+		case s.param || s.value != segments[i]:
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// Returns true if the route takes precedence over another route matching the same path: the leftmost segment at which
+// the templates differ in kind is a literal in this route.
+func (rt *route) precedes(other *route) bool {
+	for i := range rt.segments {
+		if rt.segments[i].param != other.segments[i].param {
+			return !rt.segments[i].param
+		}
+	}
+	return false
+}
+
+// Normalizes a path or path template: trailing slashes are removed, and the empty path is the root.
+func normalize(path string) string {
+	if path = strip.TrailingSlashes(path); path == "" {
+		return "/"
+	}
+	return path
+}
+
+// Parses a normalized path template into its segments.  Panics if the template is malformed.
+func parseTemplate(template string) []segment {
+	if !strings.HasPrefix(template, "/") {
+		panic(fmt.Sprintf("router: template must begin with a slash (was: %s)", template))
+	}
+
+	var segments []segment
+	if template == "/" {
+		return segments
+	}
+
+	for _, s := range strings.Split(template[1:], "/") {
+		switch {
+		case strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") && len(s) > 2:
+			segments = append(segments, segment{value: s[1 : len(s)-1], param: true})
+		case s == "" || strings.ContainsAny(s, "{}"):
+			panic(fmt.Sprintf("router: malformed segment '%s' of template %s", s, template))
+		default:
+			segments = append(segments, segment{value: s})
+		}
+	}
+
+	return segments
+}
+
+// Splits an escaped request path into its percent-decoded segments, after normalizing it.
+func splitPath(escaped string) ([]string, error) {
+	escaped = normalize(escaped)
+	if escaped == "/" {
+		return nil, nil
+	}
+
+	segments := strings.Split(strings.TrimPrefix(escaped, "/"), "/")
+	for i := range segments {
+		decoded, err := url.PathUnescape(segments[i])
+		if err != nil {
+			return nil, err
+		}
+		segments[i] = decoded
+	}
+
+	return segments, nil
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Returns a handler that records the route it was dispatched to, and the value of the named parameter
+func recorder(name string, dispatched *string, param string, value *string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*dispatched = name
+		if param != "" {
+			*value = Param(r, param)
+		}
+	}
+}
+
+func TestRouter_Dispatch(t *testing.T) {
+	var dispatched, id string
+	rt := New()
+	negs := rt.Sub("/neg/")
+	negs.HandleFunc(http.MethodGet, "/", recorder("list", &dispatched, "", nil))
+	negs.HandleFunc(http.MethodGet, "/{id}", recorder("get", &dispatched, "id", &id))
+	negs.HandleFunc(http.MethodPut, "/{id}", recorder("put", &dispatched, "id", &id))
+	negs.HandleFunc(http.MethodGet, "/_export", recorder("export", &dispatched, "", nil))
+	negs.HandleFunc(http.MethodGet, "/{id}/scans", recorder("scans", &dispatched, "id", &id))
+
+	for _, tc := range []struct {
+		method, target, route, id string
+	}{
+		{"GET", "/neg", "list", ""},
+		{"GET", "/neg/", "list", ""},
+		{"GET", "/neg?film=Tri-X", "list", ""},
+		{"GET", "/neg/abc", "get", "abc"},
+		{"GET", "/neg/abc/", "get", "abc"},
+		{"GET", "/neg/abc?x=1", "get", "abc"},
+		{"HEAD", "/neg/abc", "get", "abc"},
+		{"PUT", "/neg/abc", "put", "abc"},
+		{"GET", "/neg/a%2Fb%20c", "get", "a/b c"},
+		{"GET", "/neg/_export", "export", ""},
+		{"GET", "/neg/abc/scans", "scans", "abc"},
+	} {
+		dispatched, id = "", ""
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+		assert.Equal(t, 200, w.Code, "%s %s", tc.method, tc.target)
+		assert.Equal(t, tc.route, dispatched, "%s %s", tc.method, tc.target)
+		assert.Equal(t, tc.id, id, "%s %s", tc.method, tc.target)
+	}
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	rt := New()
+	rt.HandleFunc(http.MethodGet, "/neg/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, target := range []string{"/", "/moo", "/neg/abc/def", "/neg//abc"} {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, 404, w.Code, target)
+	}
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	rt := New()
+	rt.HandleFunc(http.MethodGet, "/neg", func(w http.ResponseWriter, r *http.Request) {})
+	rt.HandleFunc(http.MethodPost, "/neg", func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("DELETE", "/neg", nil))
+	assert.Equal(t, 405, w.Code)
+	assert.Equal(t, "GET, HEAD, POST, OPTIONS", w.Header().Get("Allow"))
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/neg/", nil))
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, "GET, HEAD, POST, OPTIONS", w.Header().Get("Allow"))
+}
+
+func TestRouter_Options(t *testing.T) {
+	rt := New()
+	rt.HandleFunc(http.MethodGet, "/neg", func(w http.ResponseWriter, r *http.Request) {})
+	rt.HandleFunc(http.MethodOptions, "/neg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Post", "application/json")
+		w.WriteHeader(204)
+	})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/neg", nil))
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+	assert.Equal(t, "application/json", w.Header().Get("Accept-Post"))
+}
+
+func TestRouter_MalformedTemplate(t *testing.T) {
+	rt := New()
+	assert.Panics(t, func() { rt.HandleFunc(http.MethodGet, "neg", nil) })
+	assert.Panics(t, func() { rt.HandleFunc(http.MethodGet, "/neg//{id}", nil) })
+	assert.Panics(t, func() { rt.HandleFunc(http.MethodGet, "/neg/{}", nil) })
+	assert.Panics(t, func() { rt.HandleFunc(http.MethodGet, "/neg/{id", nil) })
+
+	rt.HandleFunc(http.MethodGet, "/neg", nil)
+	assert.Panics(t, func() { rt.HandleFunc(http.MethodGet, "/neg/", nil) })
+}
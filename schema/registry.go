@@ -0,0 +1,76 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/router"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// The media type schemas are served as
+const MediaType = "application/schema+json"
+
+var (
+	mu sync.RWMutex
+	// the model struct of each registered WebResource, keyed by the name it is registered under
+	registry = map[string]reflect.Type{}
+)
+
+func init() {
+	Register("neg", &model.Neg{})
+}
+
+// Registers the model struct of a WebResource under the supplied name, e.g. "neg", so that its schema is served at
+// /schema/{name}.  The WebResource must be a pointer to a model struct.  Panics if the name is already registered, or
+// if a schema cannot be generated for the model struct.
+func Register(name string, r model.WebResource) {
+	t := structType(r)
+	Generate(r)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("schema: '%s' is already registered", name))
+	}
+	registry[name] = t
+}
+
+// Returns the JSON Schema of the WebResource registered under the supplied name.  The returned boolean is false if no
+// WebResource is registered under the name.
+func Lookup(name string) (Schema, bool) {
+	mu.RLock()
+	t, ok := registry[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return Generate(reflect.New(t).Interface()), true
+}
+
+// Returns an http.HandlerFunc serving the JSON Schema of the WebResource named by the `type` path parameter, e.g.
+// /schema/{type}.  Responds 404 if no WebResource is registered under the name.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := Lookup(router.Param(r, "type"))
+		if !ok {
+			handler.NotFound(w, r)
+			return
+		}
+
+		body, err := json.Marshal(s)
+		if err != nil {
+			handler.ServerError(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", MediaType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(200)
+		_, _ = w.Write(body)
+	}
+}
@@ -0,0 +1,291 @@
+// Generates JSON Schema describing the representations of model structs, so that clients and the persistence layer may
+// validate business objects without reference to the Go types.
+//
+// Two dialects are generated from the same model struct:
+//   Generate: JSON Schema (2020-12) of the JSON representation, as served to clients
+//   Bson: a MongoDB $jsonSchema of the BSON representation, as persisted by package store/mongo
+//
+// The constraints declared by the `validate` tags of fields (see package validate) are expressed as keywords of the
+// schema, so that the schema rejects the states that validate.Struct rejects.
+package schema
+
+import (
+	"fmt"
+	"github.com/emetsger/negtracker/hypermedia"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/validate"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The JSON Schema dialect of schemas returned by Generate
+const Dialect = "https://json-schema.org/draft/2020-12/schema"
+
+// A JSON Schema, encoded by encoding/json or the bson package as a schema object
+type Schema map[string]interface{}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	webResourceType = reflect.TypeOf((*model.WebResource)(nil)).Elem()
+)
+
+// Describes how a dialect of JSON Schema names the types of values, and the members of objects
+type dialect struct {
+	// the keyword declaring the type of a value, e.g. "type" or "bsonType"
+	typeKeyword string
+	// the names of the types of strings, integers, numbers, booleans, arrays, and objects
+	str, integer, number, boolean, array, object interface{}
+	// returns the schema of a time.Time
+	time func() Schema
+	// returns the name of a field in the representation of its struct
+	name func(field reflect.StructField) string
+}
+
+var jsonDialect = dialect{
+	typeKeyword: "type",
+	str:         "string",
+	integer:     "integer",
+	number:      "number",
+	boolean:     "boolean",
+	array:       "array",
+	object:      "object",
+	time:        func() Schema { return Schema{"type": "string", "format": "date-time"} },
+	name:        jsonName,
+}
+
+var bsonDialect = dialect{
+	typeKeyword: "bsonType",
+	str:         "string",
+	integer:     []interface{}{"int", "long"},
+	number:      "double",
+	boolean:     "bool",
+	array:       "array",
+	object:      "object",
+	time:        func() Schema { return Schema{"bsonType": "date"} },
+	name:        bsonName,
+}
+
+// Generates the JSON Schema of the JSON representation of the supplied model struct, or pointer to a model struct.  The
+// representation of a model.WebResource carries hypermedia controls, which are described as optional properties.
+// Panics if v is not a struct, if a field is of an unsupported type, or if a `validate` tag is malformed.
+func Generate(v interface{}) Schema {
+	t := structType(v)
+	s := jsonDialect.structSchema(t)
+	s["$schema"] = Dialect
+	s["title"] = t.Name()
+	if reflect.PtrTo(t).Implements(webResourceType) {
+		addControls(s["properties"].(map[string]interface{}))
+	}
+	return s
+}
+
+// Adds the `_links` and `_embedded` members of package hypermedia to the properties of the schema of a WebResource
+func addControls(properties map[string]interface{}) {
+	link := Schema{
+		"type":                 "object",
+		"required":             []interface{}{"href"},
+		"properties":           map[string]interface{}{"href": Schema{"type": "string"}},
+		"additionalProperties": false,
+	}
+
+	// the self and collection relations link to a single resource, any other to an array of resources
+	properties[hypermedia.LinksMember] = Schema{
+		"type":                 "object",
+		"required":             []interface{}{hypermedia.RelSelf},
+		"properties":           map[string]interface{}{hypermedia.RelSelf: link, hypermedia.RelCollection: link},
+		"additionalProperties": Schema{"type": "array", "items": link},
+	}
+	properties[hypermedia.EmbeddedMember] = Schema{
+		"type":                 "object",
+		"additionalProperties": Schema{"type": "array", "items": Schema{"type": "object"}},
+	}
+}
+
+// Generates a MongoDB $jsonSchema of the BSON representation of the supplied model struct, or pointer to a model
+// struct.  Fields added to documents by the persistence layer, e.g. "_id", are not described, and must be added to the
+// properties of the schema by the caller.  Panics as Generate does.
+func Bson(v interface{}) Schema {
+	s := bsonDialect.structSchema(structType(v))
+	s["title"] = structType(v).Name()
+	return s
+}
+
+func structType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("schema: can only generate schemas of structs, not %T", v))
+	}
+	return t
+}
+
+// Returns the schema of a value of type t
+func (d dialect) schemaOf(t reflect.Type, field reflect.StructField) Schema {
+	if t == timeType {
+		return d.time()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := d.schemaOf(t.Elem(), field)
+		s[d.typeKeyword] = nullable(s[d.typeKeyword])
+		return s
+	case reflect.String:
+		return Schema{d.typeKeyword: d.str}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Schema{d.typeKeyword: d.integer}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{d.typeKeyword: d.integer, "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Schema{d.typeKeyword: d.number}
+	case reflect.Bool:
+		return Schema{d.typeKeyword: d.boolean}
+	case reflect.Slice:
+		// nil slices are represented as null
+		return Schema{d.typeKeyword: nullable(d.array), "items": d.schemaOf(t.Elem(), field)}
+	case reflect.Array:
+		return Schema{d.typeKeyword: d.array, "items": d.schemaOf(t.Elem(), field)}
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String {
+			return Schema{d.typeKeyword: nullable(d.object), "additionalProperties": d.schemaOf(t.Elem(), field)}
+		}
+	case reflect.Struct:
+		return d.structSchema(t)
+	}
+
+	panic(fmt.Sprintf("schema: field %s is of unsupported type %s", field.Name, t))
+}
+
+// Returns the schema of a struct: an object with a property for each exported field, and no others
+func (d dialect) structSchema(t reflect.Type) Schema {
+	properties := map[string]interface{}{}
+	required := []interface{}{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := d.name(field)
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+
+		constraints := validate.Constraints(field)
+		isRequired := false
+		for _, c := range constraints {
+			isRequired = isRequired || c.Name == "required"
+		}
+
+		property := d.schemaOf(field.Type, field)
+		for _, c := range constraints {
+			constrain(property, c, field, isRequired)
+		}
+		if isRequired {
+			required = append(required, name)
+		}
+		properties[name] = property
+	}
+
+	s := Schema{d.typeKeyword: d.object, "properties": properties, "additionalProperties": false}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// Adds the keywords expressing a constraint to the schema of the constrained field.  Required fields must also be
+// present in the representation of their struct, which is expressed by the schema of the struct.
+func constrain(s Schema, c validate.Constraint, field reflect.StructField, required bool) {
+	kind := field.Type.Kind()
+
+	switch c.Name {
+	case "required":
+		// the zero value, and blank strings, are not permitted
+		switch kind {
+		case reflect.String:
+			s["pattern"] = `\S`
+		case reflect.Slice:
+			s["minItems"] = 1
+		case reflect.Map:
+			s["minProperties"] = 1
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+			reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+			s["not"] = Schema{"enum": []interface{}{0}}
+		}
+	case "min", "max":
+		bound, err := strconv.ParseFloat(c.Arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("schema: malformed constraint '%s' on field %s", c, field.Name))
+		}
+		if keyword, ok := rangeKeyword(c.Name, kind); ok {
+			if keyword == "minimum" || keyword == "maximum" {
+				s[keyword] = bound
+			} else {
+				s[keyword] = int(bound)
+			}
+			return
+		}
+		panic(fmt.Sprintf("schema: range constraint on unsupported field %s of kind %s", field.Name, kind))
+	case "oneof":
+		if kind != reflect.String {
+			panic(fmt.Sprintf("schema: oneof constraint on non-string field %s", field.Name))
+		}
+		var enum []interface{}
+		if !required {
+			// the empty string is permitted unless the field is required
+			enum = append(enum, "")
+		}
+		for _, value := range strings.Split(c.Arg, "|") {
+			enum = append(enum, value)
+		}
+		s["enum"] = enum
+	default:
+		panic(fmt.Sprintf("schema: unknown constraint '%s' on field %s", c, field.Name))
+	}
+}
+
+// Returns the keyword expressing a min or max constraint on a field of the supplied kind
+func rangeKeyword(constraint string, kind reflect.Kind) (string, bool) {
+	var suffix string
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return map[string]string{"min": "minimum", "max": "maximum"}[constraint], true
+	case reflect.String:
+		suffix = "Length"
+	case reflect.Slice, reflect.Array:
+		suffix = "Items"
+	case reflect.Map:
+		suffix = "Properties"
+	default:
+		return "", false
+	}
+	return constraint + suffix, true
+}
+
+// Returns the type of a value that may also be null
+func nullable(t interface{}) interface{} {
+	if types, ok := t.([]interface{}); ok {
+		return append(append([]interface{}{}, types...), "null")
+	}
+	return []interface{}{t, "null"}
+}
+
+// Returns the name of the field in the JSON representation of its struct
+func jsonName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// Returns the name of the field in the BSON representation of its struct, which the bson package derives by lower
+// casing the name of the field
+func bsonName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("bson"), ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(field.Name)
+}
@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"encoding/json"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Round-trips a schema through encoding/json, so it may be compared with a literal
+func encoded(t *testing.T, s Schema) map[string]interface{} {
+	data, err := json.Marshal(s)
+	require.Nil(t, err)
+	result := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal(data, &result))
+	return result
+}
+
+func decoded(t *testing.T, s string) map[string]interface{} {
+	result := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal([]byte(s), &result))
+	return result
+}
+
+func TestGenerate(t *testing.T) {
+	assert.Equal(t, decoded(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "Neg",
+		"type": "object",
+		"additionalProperties": false,
+		"required": ["Film"],
+		"properties": {
+			"Id": {"type": "string"},
+			"Created": {"type": "string", "format": "date-time"},
+			"Updated": {"type": "string", "format": "date-time"},
+			"Film": {"type": "string", "pattern": "\\S"},
+			"EI": {"type": "integer", "minimum": 0, "maximum": 25600},
+			"Developer": {"type": "string"},
+			"FrameNumber": {"type": "string"},
+			"Tags": {"type": ["array", "null"], "items": {"type": "string"}},
+			"Description": {"type": "string"},
+			"Format": {"type": "string", "enum": ["", "35mm", "120", "220", "4x5", "5x7", "8x10"]},
+			"_links": {
+				"type": "object",
+				"required": ["self"],
+				"properties": {
+					"self": {"type": "object", "required": ["href"], "properties": {"href": {"type": "string"}}, "additionalProperties": false},
+					"collection": {"type": "object", "required": ["href"], "properties": {"href": {"type": "string"}}, "additionalProperties": false}
+				},
+				"additionalProperties": {"type": "array", "items":
+					{"type": "object", "required": ["href"], "properties": {"href": {"type": "string"}}, "additionalProperties": false}}
+			},
+			"_embedded": {"type": "object", "additionalProperties": {"type": "array", "items": {"type": "object"}}}
+		}
+	}`), encoded(t, Generate(&model.Neg{})))
+}
+
+func TestBson(t *testing.T) {
+	assert.Equal(t, decoded(t, `{
+		"title": "Neg",
+		"bsonType": "object",
+		"additionalProperties": false,
+		"required": ["film"],
+		"properties": {
+			"id": {"bsonType": "string"},
+			"created": {"bsonType": "date"},
+			"updated": {"bsonType": "date"},
+			"film": {"bsonType": "string", "pattern": "\\S"},
+			"ei": {"bsonType": ["int", "long"], "minimum": 0, "maximum": 25600},
+			"developer": {"bsonType": "string"},
+			"framenumber": {"bsonType": "string"},
+			"tags": {"bsonType": ["array", "null"], "items": {"bsonType": "string"}},
+			"description": {"bsonType": "string"},
+			"format": {"bsonType": "string", "enum": ["", "35mm", "120", "220", "4x5", "5x7", "8x10"]}
+		}
+	}`), encoded(t, Bson(model.Neg{})))
+}
+
+func TestGenerate_Types(t *testing.T) {
+	type lens struct {
+		Focal uint `validate:"min=8"`
+	}
+	type camera struct {
+		Name     string `json:"name" validate:"required,max=40"`
+		Mount    string `validate:"required,oneof=M|LTM"`
+		Frames   int    `validate:"required"`
+		Weight   *float64
+		Lenses   []lens `validate:"max=3"`
+		Settings map[string]bool
+		Bought   *time.Time
+		Ignored  string `json:"-"`
+		internal string
+	}
+
+	assert.Equal(t, decoded(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "camera",
+		"type": "object",
+		"additionalProperties": false,
+		"required": ["name", "Mount", "Frames"],
+		"properties": {
+			"name": {"type": "string", "pattern": "\\S", "maxLength": 40},
+			"Mount": {"type": "string", "pattern": "\\S", "enum": ["M", "LTM"]},
+			"Frames": {"type": "integer", "not": {"enum": [0]}},
+			"Weight": {"type": ["number", "null"]},
+			"Lenses": {"type": ["array", "null"], "maxItems": 3, "items": {
+				"type": "object",
+				"additionalProperties": false,
+				"properties": {"Focal": {"type": "integer", "minimum": 8}}
+			}},
+			"Settings": {"type": ["object", "null"], "additionalProperties": {"type": "boolean"}},
+			"Bought": {"type": ["string", "null"], "format": "date-time"}
+		}
+	}`), encoded(t, Generate(camera{})))
+}
+
+func TestGenerate_Panics(t *testing.T) {
+	assert.Panics(t, func() { Generate("moo") })
+	assert.Panics(t, func() { Generate(nil) })
+	assert.Panics(t, func() {
+		Generate(struct{ C chan int }{})
+	})
+	assert.Panics(t, func() {
+		Generate(struct {
+			Film string `validate:"moo"`
+		}{})
+	})
+	assert.Panics(t, func() {
+		Generate(struct {
+			EI int `validate:"oneof=100|200"`
+		}{})
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	s, ok := Lookup("neg")
+	require.True(t, ok)
+	assert.Equal(t, Generate(&model.Neg{}), s)
+
+	_, ok = Lookup("moo")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { Register("neg", &model.Neg{}) })
+}
+
+func TestHandler(t *testing.T) {
+	rt := router.New()
+	rt.HandleFunc(http.MethodGet, "/schema/{type}", Handler())
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/schema/neg", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, MediaType, w.Header().Get("Content-Type"))
+	assert.Equal(t, encoded(t, Generate(&model.Neg{})), decoded(t, w.Body.String()))
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/schema/moo", nil))
+	assert.Equal(t, 404, w.Code)
+}
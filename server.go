@@ -3,8 +3,16 @@ package main
 import (
 	"context"
 	"fmt"
+	"github.com/emetsger/negtracker/handler"
+	"github.com/emetsger/negtracker/handler/admin"
 	"github.com/emetsger/negtracker/handler/neg"
+	"github.com/emetsger/negtracker/idempotency"
+	"github.com/emetsger/negtracker/openapi"
+	"github.com/emetsger/negtracker/router"
+	"github.com/emetsger/negtracker/schema"
 	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/bolt"
+	"github.com/emetsger/negtracker/store/memory"
 	"github.com/emetsger/negtracker/store/mongo"
 	"github.com/emetsger/negtracker/urlutil/strip"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -36,29 +44,72 @@ type Configuration struct {
 type State int
 
 var mongoConfig = &mongo.MongoConfig{
-	DbUri:         getEnvOrDefault(store.EnvDbUri, "mongodb://localhost:27017"),
-	DbName:        getEnvOrDefault(store.EnvDbName, "negtracker"),
-	NegCollection: getEnvOrDefault(store.EnvDbNegCollection, "neg"),
-	Opts:          options.Client().SetAppName("negtracker").SetServerSelectionTimeout(5 * time.Second),
+	DbUri:            getEnvOrDefault(store.EnvDbUri, "mongodb://localhost:27017"),
+	DbName:           getEnvOrDefault(store.EnvDbName, "negtracker"),
+	NegCollection:    getEnvOrDefault(store.EnvDbNegCollection, "neg"),
+	SchemaValidation: getEnvOrDefault(store.EnvDbSchemaValidation, mongo.ValidationStrict),
+	Opts:             options.Client().SetAppName("negtracker").SetServerSelectionTimeout(5 * time.Second),
 }
 
-var mongoStore = &mongo.MongoStore{}
+// The file holding the storage layer when DB_TYPE is bolt.  The file is held exclusively by the server; other processes
+// opening it, e.g. a second server, wait for it to be released until the timeout.
+var boltConfig = &bolt.BoltConfig{
+	Path:    getEnvOrDefault(store.EnvDbPath, "negtracker.db"),
+	Timeout: 5 * time.Second,
+}
+
+// The storage layer, configured by main
+var db store.Api
+
+// The OpenAPI document describing the API
+var apiDoc = openapi.Load()
+
+// Reports requests and responses that do not conform to the OpenAPI document, when OPENAPI_VALIDATE is true
+var reportViolation = func(v openapi.Violation) {
+	log.Print(v.Error())
+}
 
 func main() {
 	state = STARTING
 	pong := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(200)
 		_, _ = w.Write([]byte("Pong!"))
 	}
 
-	mongoStore.Configure(mongoConfig)
+	// the storage layer is backed up by the running server, so it is not configured here
+	if len(os.Args) > 1 && os.Args[1] == "backup" {
+		if err := runBackup(os.Args[2:], http.DefaultClient, os.Stdout); err != nil {
+			log.Fatal("Backup failed: ", err)
+		}
+		return
+	}
+
+	db = configureStore(getEnvOrDefault(store.EnvDbType, dbTypeMongo))
+
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:], db, os.Stdout); err != nil {
+			log.Fatal("Export failed: ", err)
+		}
+		return
+	}
+
+	rt := router.New()
+	rt.HandleFunc(http.MethodGet, "/Ping", pong)
+	neg.Routes(rt.Sub("/neg"), db, negConfig())
+	rt.HandleFunc(http.MethodPost, "/admin/purge", admin.NewPurgeHandler(db, purgeConfig()))
+	if b, ok := db.(store.Backupable); ok {
+		rt.HandleFunc(http.MethodGet, "/admin/backup", admin.NewBackupHandler(b, backupConfig()))
+	}
+	rt.HandleFunc(http.MethodGet, "/openapi.json", apiDoc.Handler())
+	rt.HandleFunc(http.MethodGet, "/schema/{type}", schema.Handler())
 
-	http.HandleFunc("/Ping", pong)
-	negHandler := neg.NewHandler(mongoStore)
-	http.HandleFunc("/neg", negHandler)
-	http.HandleFunc("/neg/", negHandler)
+	var h http.Handler = rt
+	if validate, _ := strconv.ParseBool(getEnvOrDefault("OPENAPI_VALIDATE", "false")); validate {
+		h = apiDoc.Validate(rt, func(v openapi.Violation) { reportViolation(v) })
+	}
 
-	s = &http.Server{}
+	s = &http.Server{Handler: handler.RequestId(h)}
 	config = configure(s)
 	start(s, config)
 }
@@ -92,12 +143,85 @@ func start(s *http.Server, c *Configuration) {
 func stop(s *http.Server) {
 	state = STOPPING
 	log.Print("Stopping HTTP server")
-	ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
 	err := s.Shutdown(ctx)
 	log.Printf("%s, %v", "HTTP server shutdown", err)
 	state = STOPPED
 }
 
+// Implementations of the storage layer, selected by DB_TYPE
+const (
+	// MongoDB, configured by the DB_* env vars
+	dbTypeMongo = "mongo"
+	// Memory, which retains nothing across restarts; for development, and for tests that cannot rely on MongoDB
+	dbTypeMemory = "memory"
+	// A local file, configured by DB_PATH; for single-user installs
+	dbTypeBolt = "bolt"
+)
+
+// Returns the configured storage layer of the supplied type.  MongoDB is used unless otherwise configured.
+func configureStore(dbType string) store.Api {
+	switch dbType {
+	case dbTypeMongo:
+		s := &mongo.MongoStore{}
+		s.Configure(mongoConfig)
+		return s
+	case dbTypeMemory:
+		s := &memory.MemoryStore{}
+		s.Configure(nil)
+		return s
+	case dbTypeBolt:
+		s := &bolt.BoltStore{}
+		s.Configure(boltConfig)
+		return s
+	}
+
+	panic(fmt.Sprintf("Invalid %s, must be one of %s, %s, or %s (was: %s)", store.EnvDbType, dbTypeMongo,
+		dbTypeMemory, dbTypeBolt, dbType))
+}
+
+// Responses to POST requests bearing an Idempotency-Key are retained for 24 hours, and at most 10000 of them, unless
+// otherwise configured
+func negConfig() *neg.Config {
+	ttl, err := time.ParseDuration(getEnvOrDefault("IDEMPOTENCY_TTL", "24h"))
+	if err != nil {
+		panic("Invalid IDEMPOTENCY_TTL, " + err.Error())
+	}
+
+	maxEntries, err := strconv.Atoi(getEnvOrDefault("IDEMPOTENCY_MAX_ENTRIES",
+		strconv.Itoa(idempotency.DefaultMaxEntries)))
+	if err != nil || maxEntries < 1 {
+		panic(fmt.Sprintf("Invalid IDEMPOTENCY_MAX_ENTRIES, must be a positive integer (was: %s)",
+			os.Getenv("IDEMPOTENCY_MAX_ENTRIES")))
+	}
+
+	return &neg.Config{
+		CacheControl:          getEnvOrDefault("CACHE_CONTROL", neg.DefaultCacheControl),
+		IdempotencyTTL:        ttl,
+		IdempotencyMaxEntries: maxEntries,
+	}
+}
+
+// Tombstones are purged 30 days after deletion unless otherwise configured
+func purgeConfig() *admin.PurgeConfig {
+	age, err := time.ParseDuration(getEnvOrDefault("PURGE_TOMBSTONE_AGE", "720h"))
+	if err != nil {
+		panic("Invalid PURGE_TOMBSTONE_AGE, " + err.Error())
+	}
+
+	return &admin.PurgeConfig{
+		Token: getEnvOrDefault("ADMIN_TOKEN", ""),
+		Age:   age,
+	}
+}
+
+func backupConfig() *admin.BackupConfig {
+	return &admin.BackupConfig{
+		Token: getEnvOrDefault("ADMIN_TOKEN", ""),
+	}
+}
+
 func getEnvOrDefault(envVar, defaultValue string) string {
 	if value, exists := os.LookupEnv(envVar); exists == false {
 		return defaultValue
@@ -1,5 +1,3 @@
-// +build integration
-
 package main
 
 import (
@@ -8,15 +6,22 @@ import (
 	"fmt"
 	"github.com/emetsger/negtracker/id"
 	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/openapi"
+	"github.com/emetsger/negtracker/schema"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/bolt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -34,6 +39,8 @@ func (v *verifier) verifyFunc(f func(t *testing.T, res *http.Response)) *verifie
 
 var MyVerifier *verifier
 
+const adminToken = "server_test"
+
 var sampleNeg = model.Neg{
 	Id:          "negId",
 	Film:        "FP4",
@@ -50,6 +57,38 @@ func TestMain(m *testing.M) {
 		stop(s)
 	}()
 
+	// business objects are kept in memory unless a database is configured, e.g. DB_TYPE=mongo
+	if _, exists := os.LookupEnv(store.EnvDbType); !exists {
+		_ = os.Setenv(store.EnvDbType, dbTypeMemory)
+	}
+
+	// a local file is created for the tests unless one is configured
+	var dbDir string
+	if _, exists := os.LookupEnv(store.EnvDbPath); !exists && os.Getenv(store.EnvDbType) == dbTypeBolt {
+		var err error
+		if dbDir, err = ioutil.TempDir("", "server_test"); err != nil {
+			panic(err)
+		}
+		boltConfig.Path = filepath.Join(dbDir, "negtracker.db")
+	}
+
+	if _, exists := os.LookupEnv("ADMIN_TOKEN"); !exists {
+		_ = os.Setenv("ADMIN_TOKEN", adminToken)
+	}
+
+	// responses that do not conform to the OpenAPI document fail the tests; requests that do not conform are expected,
+	// as some tests send invalid requests on purpose
+	_ = os.Setenv("OPENAPI_VALIDATE", "true")
+	var responseViolations []openapi.Violation
+	var violationsMu sync.Mutex
+	reportViolation = func(v openapi.Violation) {
+		if v.Status != 0 {
+			violationsMu.Lock()
+			responseViolations = append(responseViolations, v)
+			violationsMu.Unlock()
+		}
+	}
+
 	go main()
 
 	// wait for the server to get into the running state
@@ -70,7 +109,20 @@ func TestMain(m *testing.M) {
 	}
 
 	// call flag.Parse() here if TestMain uses flags
-	os.Exit(m.Run())
+	result := m.Run()
+
+	violationsMu.Lock()
+	for _, v := range responseViolations {
+		log.Print(v.Error())
+		result = 1
+	}
+	violationsMu.Unlock()
+
+	if dbDir != "" {
+		_ = os.RemoveAll(dbDir)
+	}
+
+	os.Exit(result)
 }
 
 func Test_ServerMain(t *testing.T) {
@@ -86,6 +138,37 @@ func Test_ServerMain(t *testing.T) {
 	}).attempt(req, t)
 }
 
+func Test_ServerOpenApi(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/openapi.json", config.ListenUrl()), nil)
+
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		doc := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), &doc))
+		assert.Contains(t, doc["paths"], "/neg/{id}")
+	}).attempt(req, t)
+}
+
+func Test_ServerSchema(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/schema/neg", config.ListenUrl()), nil)
+
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, schema.MediaType, res.Header.Get("Content-Type"))
+		s := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), &s))
+		assert.Equal(t, "Neg", s["title"])
+		assert.Contains(t, s["properties"], "Film")
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/schema/moo", config.ListenUrl()), nil)
+
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 404, res.StatusCode)
+	}).attempt(req, t)
+}
+
 // test creating a Neg
 func Test_ServerNegPost(t *testing.T) {
 	neg := sampleNeg
@@ -110,6 +193,334 @@ func Test_ServerNegPost(t *testing.T) {
 	}).attempt(req, t)
 }
 
+// test the Location and validators of a created Neg, and the return preferences of the client
+func Test_ServerNegPostPrefer(t *testing.T) {
+	neg := sampleNeg
+	neg.Id = id.Mint()
+	body, _ := json.Marshal(neg)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "return=representation")
+
+	var etag string
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+		assert.Equal(t, fmt.Sprintf("/neg/%s", neg.Id), res.Header.Get("Location"))
+		assert.Equal(t, "return=representation", res.Header.Get("Preference-Applied"))
+		assert.Equal(t, "Accept, Prefer", res.Header.Get("Vary"))
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		created := &model.Neg{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), created))
+		assert.Equal(t, neg.Id, created.Id)
+		assert.Equal(t, created.GetEtag(), model.Etag(res.Header.Get("ETag")))
+		etag = res.Header.Get("ETag")
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, etag, res.Header.Get("ETag"))
+	}).attempt(req, t)
+
+	neg.Id = id.Mint()
+	body, _ = json.Marshal(neg)
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "return=minimal")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+		assert.Equal(t, fmt.Sprintf("/neg/%s", neg.Id), res.Header.Get("Location"))
+		assert.NotEmpty(t, res.Header.Get("ETag"))
+		assert.Equal(t, "return=minimal", res.Header.Get("Preference-Applied"))
+		assert.Equal(t, "Accept, Prefer", res.Header.Get("Vary"))
+		assert.Empty(t, asByte(res.Body))
+	}).attempt(req, t)
+}
+
+// test creating and replacing Negs that violate the constraints of the model
+func Test_ServerNegInvalid(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()),
+		bytes.NewBufferString(`{"EI": -100, "Format": "126", "Speed": 100}`))
+	req.Header.Set("Content-Type", "application/json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 422, res.StatusCode)
+		problem := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), &problem))
+		assert.Equal(t, map[string]interface{}{"Speed": []interface{}{"is not a known field"}}, problem["violations"])
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()),
+		bytes.NewBufferString(`{"EI": -100, "Format": "126"}`))
+	req.Header.Set("Content-Type", "application/json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 422, res.StatusCode)
+		problem := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), &problem))
+		violations := problem["violations"].(map[string]interface{})
+		assert.Equal(t, []interface{}{"is required"}, violations["Film"])
+		assert.Equal(t, []interface{}{"must be at least 0"}, violations["EI"])
+		assert.Contains(t, violations, "Format")
+	}).attempt(req, t)
+
+	neg := sampleNeg
+	neg.Id = id.Mint()
+	body, _ := json.Marshal(neg)
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBufferString(`{"Film": "", "EI": 100}`))
+	req.Header.Set("If-Match", "*")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 422, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBufferString(`{"EI": 1000000}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 422, res.StatusCode)
+	}).attempt(req, t)
+}
+
+// test retrying the creation of a Neg with an Idempotency-Key
+func Test_ServerNegPostIdempotent(t *testing.T) {
+	key := id.Mint()
+	var created string
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()),
+			bytes.NewBufferString(`{"Film": "Tri-X", "EI": 1600}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+			require.Equal(t, 201, res.StatusCode)
+			negId := asString(res.Body)
+			if created == "" {
+				created = negId
+				assert.Equal(t, "", res.Header.Get("Idempotent-Replayed"))
+			} else {
+				assert.Equal(t, created, negId)
+				assert.Equal(t, "true", res.Header.Get("Idempotent-Replayed"))
+			}
+		}).attempt(req, t)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()),
+		bytes.NewBufferString(`{"Film": "Tri-X", "EI": 3200}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 422, res.StatusCode)
+	}).attempt(req, t)
+}
+
+func Test_ServerNegBulk(t *testing.T) {
+	dupe := id.Mint()
+	body := strings.Join([]string{
+		fmt.Sprintf(`{"Id": "%s", "Film": "Tri-X"}`, dupe),
+		fmt.Sprintf(`{"Id": "%s", "Film": "HP5"}`, dupe),
+		`{"Film": "FP4", "EI": 125}`,
+		`{"EI": 125}`,
+	}, "\n")
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg/_bulk", config.ListenUrl()),
+		bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+
+		var statuses []int
+		dec := json.NewDecoder(res.Body)
+		for dec.More() {
+			result := struct {
+				Line   int
+				Id     string
+				Status int
+			}{}
+			require.Nil(t, dec.Decode(&result))
+			assert.Equal(t, len(statuses)+1, result.Line)
+			statuses = append(statuses, result.Status)
+		}
+		assert.Equal(t, []int{201, 409, 201, 422}, statuses)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), dupe), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		neg := model.Neg{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), &neg))
+		assert.Equal(t, "Tri-X", neg.Film)
+	}).attempt(req, t)
+}
+
+func Test_ServerNegExport(t *testing.T) {
+	film := id.Mint()
+	for _, ei := range []int{100, 200} {
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()),
+			bytes.NewBufferString(fmt.Sprintf(`{"Film": "%s", "EI": %d}`, film, ei)))
+		req.Header.Set("Content-Type", "application/json")
+		MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+			require.Equal(t, 201, res.StatusCode)
+		}).attempt(req, t)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("%s/neg/_export?film=%s&sort=EI", config.ListenUrl(), film), nil)
+	req.Header.Set("Accept", "text/csv")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "text/csv", res.Header.Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(asString(res.Body)), "\n")
+		require.Len(t, lines, 3)
+		assert.True(t, strings.HasPrefix(lines[0], "Id,Created,Updated,Film,EI"))
+		assert.Contains(t, lines[1], film+",100")
+		assert.Contains(t, lines[2], film+",200")
+	}).attempt(req, t)
+}
+
+func Test_ServerNegRepresentations(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()),
+		bytes.NewBufferString(`{"Film": "Tri-X", "EI": 400}`))
+	req.Header.Set("Content-Type", "application/json")
+	var location, etag string
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+		location, etag = res.Header.Get("Location"), res.Header.Get("ETag")
+	}).attempt(req, t)
+
+	for accept, mediaType := range map[string]string{
+		"application/ld+json":              "application/ld+json",
+		"application/yaml":                 "application/yaml",
+		"text/csv;q=0.9, application/json": "application/json",
+		"text/csv":                         "text/csv",
+	} {
+		req, _ = http.NewRequest(http.MethodGet, config.ListenUrl()+location, nil)
+		req.Header.Set("Accept", accept)
+		MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+			assert.Equal(t, 200, res.StatusCode)
+			assert.Equal(t, mediaType, res.Header.Get("Content-Type"))
+			assert.Equal(t, "Accept", res.Header.Get("Vary"))
+			if mediaType == "application/json" {
+				assert.Equal(t, etag, res.Header.Get("ETag"))
+			} else {
+				assert.True(t, strings.HasPrefix(res.Header.Get("ETag"), "W/"))
+			}
+		}).attempt(req, t)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, config.ListenUrl()+location, nil)
+	req.Header.Set("Accept", "image/png")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 406, res.StatusCode)
+	}).attempt(req, t)
+}
+
+func Test_ServerNegFields(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()),
+		bytes.NewBufferString(`{"Film": "Tri-X", "EI": 400, "Tags": ["contact"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	var location, etag string
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+		location, etag = res.Header.Get("Location"), res.Header.Get("ETag")
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, config.ListenUrl()+location+"?fields=Film,EI,Tags", nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, `{"Film":"Tri-X","EI":400,"Tags":["contact"],"_links":{"collection":{"href":"/neg"},`+
+			`"self":{"href":"`+location+`"}}}`, string(asByte(res.Body)))
+		assert.True(t, strings.HasPrefix(res.Header.Get("ETag"), "W/"))
+		assert.NotEqual(t, "W/"+etag, res.Header.Get("ETag"))
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, config.ListenUrl()+"/neg?fields=Film,Speed", nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 400, res.StatusCode)
+	}).attempt(req, t)
+}
+
+// test following the links of a Neg, and replacing it with its representation as retrieved
+func Test_ServerNegLinks(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()),
+		bytes.NewBufferString(`{"Film": "HP5"}`))
+	req.Header.Set("Content-Type", "application/json")
+	var location string
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+		location = res.Header.Get("Location")
+	}).attempt(req, t)
+
+	var body []byte
+	var etag string
+	req, _ = http.NewRequest(http.MethodGet, config.ListenUrl()+location, nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		body, etag = asByte(res.Body), res.Header.Get("ETag")
+		links := struct {
+			Links map[string]struct{ Href string } `json:"_links"`
+		}{}
+		require.Nil(t, json.Unmarshal(body, &links))
+		assert.Equal(t, location, links.Links["self"].Href)
+		assert.Equal(t, "/neg", links.Links["collection"].Href)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodPut, config.ListenUrl()+location, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", etag)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 200, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, config.ListenUrl()+location+"?expand=roll", nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 400, res.StatusCode)
+	}).attempt(req, t)
+}
+
+// test retrieving several Negs with a single request
+func Test_ServerNegMget(t *testing.T) {
+	var ids, etags []string
+	for _, film := range []string{"Tri-X", "HP5"} {
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()),
+			bytes.NewBufferString(fmt.Sprintf(`{"Film": "%s"}`, film)))
+		req.Header.Set("Content-Type", "application/json")
+		MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+			require.Equal(t, 201, res.StatusCode)
+			ids, etags = append(ids, asString(res.Body)), append(etags, res.Header.Get("ETag"))
+		}).attempt(req, t)
+	}
+
+	missing := id.Mint()
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg/_mget", config.ListenUrl()),
+		bytes.NewBufferString(fmt.Sprintf(`{"ids": ["%s", "%s", "%s"]}`, ids[1], missing, ids[0])))
+	req.Header.Set("Content-Type", "application/json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		result := struct {
+			Docs []struct {
+				Id   string
+				Etag string
+				Doc  model.Neg
+			}
+			Missing []string
+		}{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), &result))
+		assert.Equal(t, []string{missing}, result.Missing)
+		require.Len(t, result.Docs, 2)
+		assert.Equal(t, ids[1], result.Docs[0].Id)
+		assert.Equal(t, etags[1], result.Docs[0].Etag)
+		assert.Equal(t, "HP5", result.Docs[0].Doc.Film)
+		assert.Equal(t, ids[0], result.Docs[1].Id)
+		assert.Equal(t, etags[0], result.Docs[1].Etag)
+	}).attempt(req, t)
+}
+
 // test creating a neg with an absent ID field, should be populated
 func Test_ServerNegPostNoId(t *testing.T) {
 	body := bytes.NewBufferString(`{"Film": "Moo"}`)
@@ -255,18 +666,554 @@ func Test_ServerNegGet(t *testing.T) {
 	}).attempt(req, t)
 }
 
-func Test_ServerNegNotImpl(t *testing.T) {
+// test replacing a Neg, guarded by If-Match
+func Test_ServerNegPut(t *testing.T) {
+	neg := sampleNeg
+	neg.Id = id.Mint()
+	body, err := json.Marshal(neg)
+	require.Nil(t, err)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+	}).attempt(req, t)
+
+	var etag string
+	var created *model.Neg
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		etag = res.Header.Get("ETag")
+		created = &model.Neg{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), created))
+	}).attempt(req, t)
+
+	replacement := *created
+	replacement.Developer = "Rodinal 1+50"
+	replacement.Tags = []string{"druid hill", "spring"}
+	body, err = json.Marshal(replacement)
+	require.Nil(t, err)
+
+	// missing If-Match
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBuffer(body))
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 428, res.StatusCode)
+	}).attempt(req, t)
+
+	// stale If-Match
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"stale"`)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 412, res.StatusCode)
+	}).attempt(req, t)
+
+	// current If-Match
+	var updatedEtag string
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBuffer(body))
+	req.Header.Set("If-Match", etag)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		updatedEtag = res.Header.Get("ETag")
+		assert.NotEqual(t, etag, updatedEtag)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, updatedEtag, res.Header.Get("ETag"))
+		updated := &model.Neg{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), updated))
+		assert.Equal(t, "Rodinal 1+50", updated.Developer)
+		assert.Equal(t, []string{"druid hill", "spring"}, updated.Tags)
+		assert.Equal(t, created.Created, updated.Created)
+		assert.True(t, updated.Updated.After(created.Updated))
+	}).attempt(req, t)
+}
+
+// test creating a Neg by replacing the state of a Neg that does not exist
+func Test_ServerNegPutCreate(t *testing.T) {
+	neg := sampleNeg
+	neg.Id = id.Mint()
+	uri := fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id)
+	body, err := json.Marshal(neg)
+	require.Nil(t, err)
+
+	var etag string
+	req, _ := http.NewRequest(http.MethodPut, uri, bytes.NewBuffer(body))
+	req.Header.Set("If-None-Match", "*")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+		assert.Equal(t, "/neg/"+neg.Id, res.Header.Get("Location"))
+		etag = res.Header.Get("ETag")
+		created := &model.Neg{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), created))
+		assert.Equal(t, neg.Id, created.Id)
+		assert.Equal(t, sampleNeg.Film, created.Film)
+	}).attempt(req, t)
+
+	// the Neg exists, and is not replaced
+	req, _ = http.NewRequest(http.MethodPut, uri, bytes.NewBufferString(`{"Film": "Moo"}`))
+	req.Header.Set("If-None-Match", "*")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 412, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, uri, nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, etag, res.Header.Get("ETag"))
+	}).attempt(req, t)
+}
+
+// test modifying a Neg with a JSON merge patch
+func Test_ServerNegPatch(t *testing.T) {
+	neg := sampleNeg
+	neg.Id = id.Mint()
+	body, err := json.Marshal(neg)
+	require.Nil(t, err)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+	}).attempt(req, t)
+
+	// unsupported patch media type
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBufferString(`{"Developer": "D-76"}`))
+	req.Header.Set("Content-Type", "application/json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 415, res.StatusCode)
+		assert.True(t, strings.Contains(res.Header.Get("Accept-Patch"), "application/merge-patch+json"))
+	}).attempt(req, t)
+
+	var etag string
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBufferString(`{"Developer": "D-76", "Tags": ["druid hill", "daffodil", "spring", "scanned"], "Description": null}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		etag = res.Header.Get("ETag")
+		assert.True(t, len(etag) > 0)
+		patched := &model.Neg{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), patched))
+		assert.Equal(t, neg.Id, patched.Id)
+		assert.Equal(t, neg.Film, patched.Film)
+		assert.Equal(t, "D-76", patched.Developer)
+		assert.Equal(t, "", patched.Description)
+		assert.Equal(t, []string{"druid hill", "daffodil", "spring", "scanned"}, patched.Tags)
+	}).attempt(req, t)
+
+	// stale If-Match
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBufferString(`{"EI": 400}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"stale"`)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 412, res.StatusCode)
+	}).attempt(req, t)
+
+	// current If-Match
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBufferString(`{"EI": 400}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", etag)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		assert.NotEqual(t, etag, res.Header.Get("ETag"))
+		patched := &model.Neg{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), patched))
+		assert.Equal(t, 400, patched.EI)
+		assert.Equal(t, "D-76", patched.Developer)
+	}).attempt(req, t)
+}
+
+// test modifying a Neg with a JSON patch
+func Test_ServerNegJsonPatch(t *testing.T) {
+	neg := sampleNeg
+	neg.Id = id.Mint()
+	body, err := json.Marshal(neg)
+	require.Nil(t, err)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+	}).attempt(req, t)
+
+	// remove the "daffodil" tag after testing its position
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBufferString(`[
+			{"op": "test", "path": "/Tags/1", "value": "daffodil"},
+			{"op": "remove", "path": "/Tags/1"},
+			{"op": "add", "path": "/Tags/-", "value": "scanned"}
+		]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		patched := &model.Neg{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), patched))
+		assert.Equal(t, []string{"druid hill", "spring", "scanned"}, patched.Tags)
+	}).attempt(req, t)
+
+	// failed test operation, nothing is applied
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBufferString(`[
+			{"op": "remove", "path": "/Tags/0"},
+			{"op": "test", "path": "/Tags/0", "value": "daffodil"}
+		]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 409, res.StatusCode)
+		failure := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), &failure))
+		assert.Equal(t, float64(1), failure["index"])
+		assert.Equal(t, "test", failure["op"])
+	}).attempt(req, t)
+
+	// malformed patch
+	req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id),
+		bytes.NewBufferString(`[{"op": "frobnicate", "path": "/Tags/0"}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 400, res.StatusCode)
+		assert.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		retrieved := &model.Neg{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), retrieved))
+		assert.Equal(t, []string{"druid hill", "spring", "scanned"}, retrieved.Tags)
+	}).attempt(req, t)
+}
+
+// test deleting a Neg, and purging its tombstone
+func Test_ServerNegDelete(t *testing.T) {
+	neg := sampleNeg
+	neg.Id = id.Mint()
+	body, err := json.Marshal(neg)
+	require.Nil(t, err)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 204, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 410, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 410, res.StatusCode)
+	}).attempt(req, t)
+
+	// purging requires the admin token
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/purge?age=0s", config.ListenUrl()), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 401, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/purge?age=0s", config.ListenUrl()), nil)
+	req.Header.Set("Authorization", "Bearer moo")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 403, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/purge?age=0s", config.ListenUrl()), nil)
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("ADMIN_TOKEN"))
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		result := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), &result))
+		assert.True(t, result["Purged"].(float64) > 0)
+	}).attempt(req, t)
+
+	// purged negs are no longer tombstones
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 404, res.StatusCode)
+	}).attempt(req, t)
+}
+
+// test backing up the storage layer while it is in use
+func Test_ServerBackup(t *testing.T) {
+	backupUrl := fmt.Sprintf("%s/admin/backup", config.ListenUrl())
+	if _, ok := db.(store.Backupable); !ok {
+		req, _ := http.NewRequest(http.MethodGet, backupUrl, nil)
+		req.Header.Set("Authorization", "Bearer "+os.Getenv("ADMIN_TOKEN"))
+		MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+			assert.Equal(t, 404, res.StatusCode)
+		}).attempt(req, t)
+		t.Skipf("%s %s cannot be backed up", store.EnvDbType, os.Getenv(store.EnvDbType))
+	}
+
+	neg := sampleNeg
+	neg.Id = id.Mint()
+	body, err := json.Marshal(neg)
+	require.Nil(t, err)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+	}).attempt(req, t)
+
+	// backing up requires the admin token
+	req, _ = http.NewRequest(http.MethodGet, backupUrl, nil)
+	req.Header.Set("Authorization", "Bearer moo")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 403, res.StatusCode)
+	}).attempt(req, t)
+
+	dir, err := ioutil.TempDir("", "Test_ServerBackup")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	backup := filepath.Join(dir, "backup.db")
+	require.Nil(t, runBackup([]string{"-url", config.ListenUrl(), "-o", backup}, http.DefaultClient, nil))
+
+	// the backup may be configured as a store holding the Neg
+	restored := &bolt.BoltStore{}
+	restored.Configure(&bolt.BoltConfig{Path: backup, Timeout: time.Second})
+	defer func() { _ = restored.Close() }()
+
+	restoredNeg := model.Neg{}
+	require.Nil(t, restored.Retrieve(neg.Id, &restoredNeg))
+	assert.Equal(t, neg.Film, restoredNeg.Film)
+
+	// a failed backup leaves nothing in place of the file
+	err = runBackup([]string{"-url", config.ListenUrl() + "/moo", "-o", filepath.Join(dir, "failed.db")},
+		http.DefaultClient, nil)
+	assert.NotNil(t, err)
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+}
+
+// test conditional retrieval of a Neg
+func Test_ServerNegConditionalGet(t *testing.T) {
+	neg := sampleNeg
+	neg.Id = id.Mint()
+	body, err := json.Marshal(neg)
+	require.Nil(t, err)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+	}).attempt(req, t)
+
+	var etag, lastModified string
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		etag = res.Header.Get("ETag")
+		lastModified = res.Header.Get("Last-Modified")
+		assert.True(t, len(lastModified) > 0)
+		assert.True(t, len(res.Header.Get("Cache-Control")) > 0)
+	}).attempt(req, t)
+
+	// a list of ETags, one of which is a weak version of the current ETag
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	req.Header.Set("If-None-Match", fmt.Sprintf(`"moo", W/%s`, etag))
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 304, res.StatusCode)
+		assert.Equal(t, etag, res.Header.Get("ETag"))
+		assert.Equal(t, lastModified, res.Header.Get("Last-Modified"))
+		assert.Equal(t, 0, len(asByte(res.Body)))
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	req.Header.Set("If-None-Match", `"moo"`)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 200, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id), nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 304, res.StatusCode)
+	}).attempt(req, t)
+}
+
+// test listing Negs, following the links to subsequent pages
+func Test_ServerNegList(t *testing.T) {
+	// a developer unique to this test, so only the negs created here are listed
+	developer := id.Mint()
+	for ei := 100; ei <= 500; ei += 100 {
+		neg := sampleNeg
+		neg.Id = id.Mint()
+		neg.Developer = developer
+		neg.EI = ei
+		body, err := json.Marshal(neg)
+		require.Nil(t, err)
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+		MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+			require.Equal(t, 201, res.StatusCode)
+		}).attempt(req, t)
+	}
+
+	var eis []int
+	next := fmt.Sprintf("/neg?developer=%s&sort=EI&limit=2", url.QueryEscape(developer))
+	for next != "" {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s%s", config.ListenUrl(), next), nil)
+		next = ""
+		MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+			require.Equal(t, 200, res.StatusCode)
+			negs := []model.Neg{}
+			require.Nil(t, json.Unmarshal(asByte(res.Body), &negs))
+			for i := range negs {
+				eis = append(eis, negs[i].EI)
+			}
+			if link := res.Header.Get("Link"); link != "" {
+				require.True(t, strings.HasSuffix(link, `>; rel="next"`))
+				next = link[1:strings.Index(link, ">")]
+			}
+		}).attempt(req, t)
+	}
+
+	assert.Equal(t, []int{100, 200, 300, 400, 500}, eis)
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg?developer=%s&ei_min=200&ei_max=300",
+		config.ListenUrl(), url.QueryEscape(developer)), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		negs := []model.Neg{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), &negs))
+		assert.Len(t, negs, 2)
+		assert.Equal(t, "", res.Header.Get("Link"))
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/neg?sort=moo", config.ListenUrl()), nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 400, res.StatusCode)
+	}).attempt(req, t)
+}
+
+// test requests for a Neg that does not exist
+func Test_ServerNegUnknownId(t *testing.T) {
+	unknown := fmt.Sprintf("%s/neg/%s", config.ListenUrl(), id.Mint())
+
+	req, _ := http.NewRequest(http.MethodGet, unknown, nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 404, res.StatusCode)
+		assert.True(t, len(asByte(res.Body)) > 0)
+	}).attempt(req, t)
+
+	// a precondition on the state of a Neg that does not exist cannot match
+	req, _ = http.NewRequest(http.MethodPut, unknown, bytes.NewBufferString(`{"Film": "Moo"}`))
+	req.Header.Set("If-Match", "*")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 412, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodPatch, unknown, bytes.NewBufferString(`{"Film": "Moo"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 404, res.StatusCode)
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodDelete, unknown, nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 404, res.StatusCode)
+	}).attempt(req, t)
+}
+
+func Test_ServerProblem(t *testing.T) {
+	unknown := fmt.Sprintf("%s/neg/%s", config.ListenUrl(), id.Mint())
+
+	req, _ := http.NewRequest(http.MethodGet, unknown, nil)
+	req.Header.Set("X-Request-Id", "Test_ServerProblem")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 404, res.StatusCode)
+		assert.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+		assert.Equal(t, "Test_ServerProblem", res.Header.Get("X-Request-Id"))
+		problem := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(asByte(res.Body), &problem))
+		assert.Equal(t, "/problems/not-found", problem["type"])
+		assert.Equal(t, float64(404), problem["status"])
+		assert.Equal(t, "Test_ServerProblem", problem["requestId"])
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodGet, unknown, nil)
+	req.Header.Set("Accept", "text/plain")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 404, res.StatusCode)
+		assert.Equal(t, "text/plain", res.Header.Get("Content-Type"))
+		assert.NotEmpty(t, res.Header.Get("X-Request-Id"))
+		assert.Equal(t, "Resource not found", string(asByte(res.Body)))
+	}).attempt(req, t)
+}
+
+func Test_ServerNegMethodNotAllowed(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodTrace,
 		fmt.Sprintf("%s/neg", config.ListenUrl()),
 		nil)
 
 	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
-		assert.Equal(t, 500, res.StatusCode)
+		assert.Equal(t, 405, res.StatusCode)
+		assert.Equal(t, "GET, HEAD, POST, OPTIONS", res.Header.Get("Allow"))
 		b := &bytes.Buffer{}
 		io.Copy(b, res.Body)
-		assert.True(t, strings.Contains(b.String(), "not implemented"))
+		assert.True(t, strings.Contains(b.String(), "not allowed"))
 
 	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg/%s", config.ListenUrl(), id.Mint()),
+		bytes.NewBufferString(`{"Film": "Moo"}`))
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 405, res.StatusCode)
+		assert.Equal(t, "GET, HEAD, PUT, PATCH, DELETE, OPTIONS", res.Header.Get("Allow"))
+	}).attempt(req, t)
+}
+
+func Test_ServerNegHeadAndOptions(t *testing.T) {
+	neg := &model.Neg{Film: "Tri-X", EI: 400}
+	body, _ := json.Marshal(neg)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/neg", config.ListenUrl()), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 201, res.StatusCode)
+		neg.Id = string(asByte(res.Body))
+	}).attempt(req, t)
+
+	negUrl := fmt.Sprintf("%s/neg/%s", config.ListenUrl(), neg.Id)
+	var getHeaders http.Header
+
+	req, _ = http.NewRequest(http.MethodGet, negUrl, nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		require.Equal(t, 200, res.StatusCode)
+		getHeaders = res.Header
+	}).attempt(req, t)
+
+	// query strings and trailing slashes do not affect the id parsed from the request path
+	req, _ = http.NewRequest(http.MethodGet, negUrl+"/?moo=cow", nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, getHeaders.Get("ETag"), res.Header.Get("ETag"))
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodHead, negUrl, nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, getHeaders.Get("ETag"), res.Header.Get("ETag"))
+		assert.Equal(t, getHeaders.Get("Last-Modified"), res.Header.Get("Last-Modified"))
+		assert.Equal(t, getHeaders.Get("Content-Type"), res.Header.Get("Content-Type"))
+		assert.Equal(t, getHeaders.Get("Content-Length"), res.Header.Get("Content-Length"))
+		assert.Empty(t, asByte(res.Body))
+	}).attempt(req, t)
+
+	req, _ = http.NewRequest(http.MethodOptions, negUrl, nil)
+	MyVerifier.verifyFunc(func(t *testing.T, res *http.Response) {
+		assert.Equal(t, 204, res.StatusCode)
+		assert.Equal(t, "GET, HEAD, PUT, PATCH, DELETE, OPTIONS", res.Header.Get("Allow"))
+		assert.True(t, strings.Contains(res.Header.Get("Accept-Patch"), "application/json-patch+json"))
+	}).attempt(req, t)
 }
 
 func (v *verifier) attempt(req *http.Request, t *testing.T) {
@@ -0,0 +1,509 @@
+// An implementation of store.Api that keeps business objects in a single local file using bbolt, an embedded,
+// transactional key/value store.  It suits single-user installs, where running a database server is impractical.
+package bolt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/scan"
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The number of business objects read by each transaction of an Iterator; replaced by tests
+var iteratePageSize = 100
+
+// Buckets of the file.  Business objects are kept in negBucket, keyed by business id, marshaled to BSON exactly as
+// store/mongo would persist them.  The business ids of deleted business objects are kept in deletedBucket, with the
+// time they were deleted; their documents remain in negBucket as tombstones until purged.
+var (
+	negBucket     = []byte("neg")
+	deletedBucket = []byte("deleted")
+)
+
+// Represents the configuration of the bolt store
+type BoltConfig struct {
+	// env var DB_PATH, the file holding the store, which is created if it does not exist
+	Path string
+	// *no* env var, the time to wait for another process holding the file to release it.  If zero, waits indefinitely.
+	Timeout time.Duration
+}
+
+// Holds business objects in a local file.
+//
+// Each operation is a transaction: writes are durable once they return, and reads observe a consistent state.  Writes
+// are serialized, while reads proceed concurrently with each other and with writes.  Queries sorted by business id are
+// evaluated by walking business objects in key order from the cursor of the query, until a page is selected; other
+// queries are evaluated by scanning every business object.  Either way, only a page of business objects is held in
+// memory, though iterating a query that is not sorted by business id holds the key of every business object it
+// selects; see Iterate.
+//
+// The file is held exclusively by the process that configures the store; it may be copied while in use with Backup.
+type BoltStore struct {
+	db *bbolt.DB
+}
+
+func (b *BoltStore) Retrieve(id string, t interface{}) error {
+	return b.RetrieveFields(id, nil, t)
+}
+
+func (b *BoltStore) RetrieveFields(id string, fields []string, t interface{}) error {
+	if _, ok := t.(model.WebResource); !ok {
+		panic(fmt.Sprintf("store/bolt: can only retrieve objects of type model.WebResource, not %T", t))
+	}
+
+	var doc bson.Raw
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(negBucket).Get([]byte(id))
+		if data == nil {
+			return store.SentinelErr(store.NotFoundErr, fmt.Sprintf("key: %s", id), nil)
+		}
+		if tx.Bucket(deletedBucket).Get([]byte(id)) != nil {
+			return store.SentinelErr(store.DeletedErr, fmt.Sprintf("key: %s", id), nil)
+		}
+		// data is only valid for the life of the transaction
+		doc = append(bson.Raw{}, data...)
+		return nil
+	})
+
+	if err != nil {
+		return boltErr(fmt.Sprintf("key: %s", id), err)
+	}
+
+	if err = bson.Unmarshal(scan.Project(doc, fields), t); err != nil {
+		return store.SentinelErr(store.DecodingErr, fmt.Sprintf("type:  %T", t), err)
+	}
+
+	return nil
+}
+
+func (b *BoltStore) RetrieveMany(ids []string, t interface{}) ([]string, error) {
+	var docs []bson.Raw
+	var missing []string
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		negs, deleted := tx.Bucket(negBucket), tx.Bucket(deletedBucket)
+		seen := map[string]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if data := negs.Get([]byte(id)); data != nil && deleted.Get([]byte(id)) == nil {
+				docs = append(docs, append(bson.Raw{}, data...))
+			} else {
+				missing = append(missing, id)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, boltErr("attempt to retrieve documents failed", err)
+	}
+
+	return missing, scan.DecodeAll(docs, t)
+}
+
+func (b *BoltStore) Store(obj interface{}) (string, error) {
+	data, err := bson.Marshal(obj)
+	if err != nil {
+		return "", store.GenericErr("attempt to marshal document failed", err)
+	}
+
+	var pid string
+	err = b.db.Update(func(tx *bbolt.Tx) (err error) {
+		pid, err = insert(tx, data)
+		return err
+	})
+
+	if err != nil {
+		return "", boltErr("attempt to insert document failed", err)
+	}
+
+	return pid, nil
+}
+
+func (b *BoltStore) StoreMany(objs []interface{}) ([]error, error) {
+	errs := make([]error, len(objs))
+
+	// every document is inserted by a single transaction, which is committed regardless of the documents that cannot
+	// be inserted
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		for i := range objs {
+			data, err := bson.Marshal(objs[i])
+			if err != nil {
+				errs[i] = store.GenericErr("attempt to marshal document failed", err)
+				continue
+			}
+			if _, err = insert(tx, data); err != nil {
+				errs[i] = boltErr("attempt to insert document failed", err)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return errs, boltErr("attempt to insert documents failed", err)
+	}
+
+	return errs, nil
+}
+
+func (b *BoltStore) Update(id string, obj interface{}) error {
+	data, err := bson.Marshal(obj)
+	if err != nil {
+		return store.GenericErr(fmt.Sprintf("attempt to marshal document with key %s failed", id), err)
+	}
+
+	err = b.db.Update(func(tx *bbolt.Tx) error {
+		if err := missing(tx, id, fmt.Sprintf("attempt to update document with key %s failed", id)); err != nil {
+			return err
+		}
+		return tx.Bucket(negBucket).Put([]byte(id), data)
+	})
+
+	if err != nil {
+		return boltErr(fmt.Sprintf("attempt to update document with key %s failed", id), err)
+	}
+
+	return nil
+}
+
+func (b *BoltStore) Delete(id string) error {
+	deleted, err := time.Now().UTC().MarshalBinary()
+	if err != nil {
+		return store.GenericErr(fmt.Sprintf("attempt to delete document with key %s failed", id), err)
+	}
+
+	err = b.db.Update(func(tx *bbolt.Tx) error {
+		if err := missing(tx, id, fmt.Sprintf("attempt to delete document with key %s failed", id)); err != nil {
+			return err
+		}
+		return tx.Bucket(deletedBucket).Put([]byte(id), deleted)
+	})
+
+	if err != nil {
+		return boltErr(fmt.Sprintf("attempt to delete document with key %s failed", id), err)
+	}
+
+	return nil
+}
+
+func (b *BoltStore) Purge(deletedBefore time.Time) (int, error) {
+	count := 0
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		negs, deleted := tx.Bucket(negBucket), tx.Bucket(deletedBucket)
+
+		// keys may not be deleted while the bucket is traversed
+		var purged [][]byte
+		err := deleted.ForEach(func(id, value []byte) error {
+			t := time.Time{}
+			if err := t.UnmarshalBinary(value); err != nil {
+				return err
+			}
+			if t.Before(deletedBefore) {
+				purged = append(purged, append([]byte{}, id...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range purged {
+			if err := negs.Delete(id); err != nil {
+				return err
+			}
+			if err := deleted.Delete(id); err != nil {
+				return err
+			}
+		}
+
+		count = len(purged)
+		return nil
+	})
+
+	if err != nil {
+		return 0, boltErr(fmt.Sprintf("attempt to purge documents deleted before %s failed",
+			deletedBefore.Format(time.RFC3339)), err)
+	}
+
+	return count, nil
+}
+
+func (b *BoltStore) List(q store.Query, t interface{}) (string, error) {
+	if q.Limit < 1 {
+		panic(fmt.Sprintf("store/bolt: query limit must be a positive integer (was: %d)", q.Limit))
+	}
+
+	docs, next, err := b.page(q)
+	if err != nil {
+		return "", err
+	}
+
+	return next, scan.DecodeAll(docs, t)
+}
+
+// Opens an Iterator over the business objects selected by the query, which are read a page at a time, each page by
+// its own transaction.  No transaction is held open between pages, so writes proceed while the Iterator is in use;
+// business objects written meanwhile may or may not be visited.
+//
+// Pages of a query sorted by business id are selected as List selects them, walking keys from the cursor of the
+// previous page.  Pages of other queries cannot be resumed without scanning every business object, which would cost
+// a scan per page, so instead a single transaction scans them once when the Iterator is opened, taking a snapshot of
+// the business ids selected, in the order of the sort, and each page reads the business objects of the next ids in
+// the snapshot.  The snapshot holds the business id and sort value of every selected business object, rather than the
+// business objects themselves.  Business objects stored after it is taken are not visited, nor are those deleted
+// before their page is read.
+func (b *BoltStore) Iterate(q store.Query) (store.Iterator, error) {
+	q.Cursor = ""
+	sel, err := scan.Select(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if sel.ById() {
+		return scan.IteratePages(q, iteratePageSize, b.page), nil
+	}
+
+	q.Limit = 0
+	if sel, err = scan.SelectKeys(q); err != nil {
+		return nil, err
+	}
+	err = b.db.View(func(tx *bbolt.Tx) error {
+		offer(tx, sel)
+		return nil
+	})
+	if err != nil {
+		return nil, boltErr("attempt to select documents failed", err)
+	}
+
+	return scan.IteratePages(q, iteratePageSize, b.snapshotPage(sel.Ids())), nil
+}
+
+// Writes a consistent copy of the file holding the store to w, by a read-only transaction, so that the store may be
+// used, including for writes, while the copy is written.  The copy is itself a file that may be configured as a store.
+func (b *BoltStore) Backup(w io.Writer) (int64, error) {
+	var n int64
+	err := b.db.View(func(tx *bbolt.Tx) (err error) {
+		n, err = tx.WriteTo(w)
+		return err
+	})
+
+	if err != nil {
+		return n, boltErr("attempt to back up the store failed", err)
+	}
+
+	return n, nil
+}
+
+// Releases the file holding the store.  The store may not be used once closed.
+func (b *BoltStore) Close() error {
+	if err := b.db.Close(); err != nil {
+		return boltErr("attempt to close the store failed", err)
+	}
+	return nil
+}
+
+// Returns the page of the business objects that have not been deleted selected by the query, and the cursor selecting
+// the following page, per scan.Selection.Page.
+func (b *BoltStore) page(q store.Query) ([]bson.Raw, string, error) {
+	sel, err := scan.Select(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = b.db.View(func(tx *bbolt.Tx) error {
+		offer(tx, sel)
+		return nil
+	})
+
+	if err != nil {
+		return nil, "", boltErr("attempt to select documents failed", err)
+	}
+
+	return sel.Page()
+}
+
+// Returns a function reading a page of the business objects identified by the snapshot of business ids, for
+// scan.IteratePages.  The cursor of a page is the position in the snapshot of the business id following it.  Business
+// objects that have been deleted, or no longer satisfy the criteria of the query, are omitted from their page.
+func (b *BoltStore) snapshotPage(ids []string) func(q store.Query) ([]bson.Raw, string, error) {
+	return func(q store.Query) ([]bson.Raw, string, error) {
+		start := 0
+		if q.Cursor != "" {
+			var err error
+			if start, err = strconv.Atoi(q.Cursor); err != nil || start < 0 || start > len(ids) {
+				return nil, "", store.SentinelErr(store.InvalidQueryErr, "malformed cursor", err)
+			}
+		}
+		end := start + q.Limit
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		q.Cursor, q.Limit = "", 0
+		sel, err := scan.Select(q)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = b.db.View(func(tx *bbolt.Tx) error {
+			negs, deleted := tx.Bucket(negBucket), tx.Bucket(deletedBucket)
+			for _, id := range ids[start:end] {
+				if data := negs.Get([]byte(id)); data != nil && deleted.Get([]byte(id)) == nil {
+					sel.Offer(data)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, "", boltErr("attempt to select documents failed", err)
+		}
+
+		var next string
+		if end < len(ids) {
+			next = strconv.Itoa(end)
+		}
+		return sel.All(), next, nil
+	}
+}
+
+// Offers the business objects that have not been deleted to the Selection, which retains a copy of those it selects.
+// Keys are ordered by business id, so if the Selection is too, business objects are offered in its order, beginning
+// at its cursor, until it is full.
+func offer(tx *bbolt.Tx, sel *scan.Selection) {
+	deleted := tx.Bucket(deletedBucket)
+	c := tx.Bucket(negBucket).Cursor()
+
+	if !sel.ById() {
+		for id, data := c.First(); id != nil; id, data = c.Next() {
+			if deleted.Get(id) == nil {
+				sel.Offer(data)
+			}
+		}
+		return
+	}
+
+	var id, data []byte
+	after := []byte(sel.After())
+	next := c.Next
+	switch {
+	case sel.Descending():
+		next = c.Prev
+		if len(after) == 0 {
+			id, data = c.Last()
+		} else if id, _ = c.Seek(after); id == nil {
+			// every key precedes the cursor
+			id, data = c.Last()
+		} else {
+			id, data = c.Prev()
+		}
+	case len(after) == 0:
+		id, data = c.First()
+	default:
+		if id, data = c.Seek(after); bytes.Equal(id, after) {
+			id, data = c.Next()
+		}
+	}
+
+	for ; id != nil && !sel.Full(); id, data = next() {
+		if deleted.Get(id) == nil {
+			sel.Offer(data)
+		}
+	}
+}
+
+// Inserts the marshaled document, keyed by its business id, returning its persistence id.  Documents must have a
+// business id.
+func insert(tx *bbolt.Tx, data bson.Raw) (string, error) {
+	id, _ := data.Lookup(scan.IdField).StringValueOK()
+	negs := tx.Bucket(negBucket)
+
+	// deleted documents remain until purged, so their business ids are not reused
+	if negs.Get([]byte(id)) != nil {
+		return "", store.SentinelErr(store.DuplicateKeyErr, "attempt to insert document failed",
+			fmt.Errorf("key: %s", id))
+	}
+
+	seq, err := negs.NextSequence()
+	if err != nil {
+		return "", err
+	}
+
+	if err = negs.Put([]byte(id), data); err != nil {
+		return "", err
+	}
+
+	return strconv.FormatUint(seq, 10), nil
+}
+
+// Returns the error to use when a write operation on the document identified by id cannot proceed, or nil if it can,
+// per scan.Missing
+func missing(tx *bbolt.Tx, id, msg string) error {
+	exists := tx.Bucket(negBucket).Get([]byte(id)) != nil
+	return scan.Missing(id, exists, tx.Bucket(deletedBucket).Get([]byte(id)) != nil, msg)
+}
+
+func (b *BoltStore) Configure(c interface{}) {
+	config := verifyConfig(c)
+
+	var err error
+	if b.db, err = bbolt.Open(config.Path, 0600, &bbolt.Options{Timeout: config.Timeout}); err != nil {
+		panic(fmt.Sprintf("store/bolt: error opening %s, %s", config.Path, err.Error()))
+	}
+
+	err = b.db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{negBucket, deletedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		panic(fmt.Sprintf("store/bolt: error creating buckets in %s, %s", config.Path, err.Error()))
+	}
+}
+
+func verifyConfig(c interface{}) BoltConfig {
+	if c == nil {
+		panic("store/bolt: config must not be nil")
+	}
+
+	config, ok := c.(*BoltConfig)
+	if !ok {
+		panic(fmt.Sprintf("store/bolt: config must be a *BoltConfig (was: %v, %T)", c, c))
+	}
+
+	if len(strings.TrimSpace(config.Path)) == 0 {
+		panic("store/bolt: BoltConfig.Path is required")
+	}
+
+	return *config
+}
+
+// Wraps an error returned by bbolt, or by a transaction, in a StorageError of the kind corresponding to its cause.
+// StorageErrors returned by transactions are returned as-is.
+func boltErr(msg string, err error) error {
+	var serr store.StorageError
+	switch {
+	case errors.As(err, &serr):
+		return err
+	case errors.Is(err, bbolt.ErrDatabaseNotOpen), errors.Is(err, bbolt.ErrTimeout):
+		return store.SentinelErr(store.UnavailableErr, msg, err)
+	case errors.Is(err, bbolt.ErrKeyRequired):
+		return store.GenericErr(msg+", a business id is required", err)
+	default:
+		return store.GenericErr(msg, err)
+	}
+}
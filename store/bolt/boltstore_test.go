@@ -0,0 +1,183 @@
+package bolt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+var sampleNeg = model.Neg{
+	Id:        "moo",
+	Created:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	Updated:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	Film:      "FP4",
+	EI:        100,
+	Developer: "Pyrocat HD",
+	Tags:      []string{"druid hill", "spring"},
+	Format:    "120",
+}
+
+// The directory holding the files of the stores under test
+var dir string
+
+func TestMain(m *testing.M) {
+	var err error
+	if dir, err = ioutil.TempDir("", "boltstore"); err != nil {
+		panic(err)
+	}
+
+	result := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(result)
+}
+
+// Returns a store kept in a new file, and the path of the file
+func newStore(t *testing.T) (*BoltStore, string) {
+	f, err := ioutil.TempFile(dir, "*.db")
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	b := &BoltStore{}
+	b.Configure(&BoltConfig{Path: f.Name(), Timeout: time.Second})
+	return b, f.Name()
+}
+
+func TestBoltStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Api {
+		b, _ := newStore(t)
+		return b
+	})
+}
+
+// business objects are durable across restarts
+func TestBoltStore_Durable(t *testing.T) {
+	b, path := newStore(t)
+	_, err := b.Store(sampleNeg)
+	require.Nil(t, err)
+
+	require.Nil(t, b.Close())
+	b.Configure(&BoltConfig{Path: path, Timeout: time.Second})
+	retrieved := model.Neg{}
+	require.Nil(t, b.Retrieve("moo", &retrieved))
+	assert.Equal(t, sampleNeg, retrieved)
+}
+
+// business objects are keyed by their business id, so one may not be stored without it
+func TestBoltStore_StoreWithoutId(t *testing.T) {
+	b, _ := newStore(t)
+	_, err := b.Store(model.Neg{Film: "FP4"})
+	assert.True(t, errors.Is(err, store.GeneralErr))
+}
+
+// the business objects visited by an iterator are read a page at a time as it advances, rather than held in memory
+func TestBoltStore_IterateByPage(t *testing.T) {
+	defer func(size int) { iteratePageSize = size }(iteratePageSize)
+	iteratePageSize = 2
+
+	for _, tc := range []struct {
+		sort     string
+		deleted  string
+		expected []string
+	}{
+		// business objects sorted by other than business id are read from a snapshot of the ids selected when the
+		// iterator is opened, so those stored meanwhile are not visited
+		{sort: "Created", deleted: "b", expected: []string{"e", "d", "c", "a"}},
+		{sort: "", deleted: "d", expected: []string{"a", "b", "c", "e", "z"}},
+	} {
+		b, _ := newStore(t)
+		created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		for i, id := range []string{"e", "d", "c", "b", "a"} {
+			obj := sampleNeg
+			obj.Id, obj.Created = id, created.AddDate(0, 0, i)
+			_, err := b.Store(obj)
+			require.Nil(t, err)
+		}
+
+		it, err := b.Iterate(store.Query{Sort: store.Sort{Field: tc.sort}, Fields: []string{"Film"}})
+		require.Nil(t, err)
+
+		neg := model.Neg{}
+		require.True(t, it.Next(&neg), tc.sort)
+		ids := []string{neg.Id}
+
+		// only the first page has been read, and no transaction is held open: writes proceed, and deletions are observed
+		// by the pages that follow
+		require.Nil(t, b.Delete(tc.deleted))
+		obj := sampleNeg
+		obj.Id, obj.Created = "z", created.AddDate(0, 0, 5)
+		_, err = b.Store(obj)
+		require.Nil(t, err)
+
+		for neg = (model.Neg{}); it.Next(&neg); neg = (model.Neg{}) {
+			ids = append(ids, neg.Id)
+			assert.Equal(t, "FP4", neg.Film)
+			assert.Empty(t, neg.Developer)
+		}
+		require.Nil(t, it.Err())
+		require.Nil(t, it.Close())
+		assert.Equal(t, tc.expected, ids, tc.sort)
+	}
+}
+
+func TestBoltStore_Backup(t *testing.T) {
+	b, _ := newStore(t)
+	_, err := b.Store(sampleNeg)
+	require.Nil(t, err)
+
+	// writes proceed while the backup is written
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			obj := sampleNeg
+			obj.Id = fmt.Sprintf("neg%d", i)
+			_, _ = b.Store(obj)
+		}
+	}()
+
+	buf := &bytes.Buffer{}
+	n, err := b.Backup(buf)
+	require.Nil(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	wg.Wait()
+
+	// the backup may be configured as a store
+	restored, path := newStore(t)
+	require.Nil(t, restored.Close())
+	require.Nil(t, ioutil.WriteFile(path, buf.Bytes(), 0600))
+	restored.Configure(&BoltConfig{Path: path, Timeout: time.Second})
+
+	retrieved := model.Neg{}
+	require.Nil(t, restored.Retrieve("moo", &retrieved))
+	assert.Equal(t, sampleNeg, retrieved)
+}
+
+func TestBoltStore_Closed(t *testing.T) {
+	b, _ := newStore(t)
+	require.Nil(t, b.Close())
+
+	err := b.Retrieve("moo", &model.Neg{})
+	assert.True(t, errors.Is(err, store.UnavailableErr))
+	assert.True(t, store.Temporary(err))
+
+	_, err = b.Backup(&bytes.Buffer{})
+	assert.True(t, errors.Is(err, store.UnavailableErr))
+}
+
+func Test_VerifyConfig(t *testing.T) {
+	assert.Equal(t, BoltConfig{Path: "negtracker.db"}, verifyConfig(&BoltConfig{Path: "negtracker.db"}))
+	assert.Panics(t, func() { verifyConfig(nil) })
+	assert.Panics(t, func() { verifyConfig(BoltConfig{Path: "negtracker.db"}) })
+	assert.Panics(t, func() { verifyConfig(&BoltConfig{Path: " "}) })
+}
@@ -0,0 +1,170 @@
+// An implementation of store.Api that keeps business objects in memory, for development and for tests that cannot rely
+// on a database.  Nothing is retained across restarts.
+package memory
+
+import (
+	"fmt"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/scan"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"time"
+)
+
+// A business object as it is held by the store: marshaled to BSON exactly as store/mongo would persist it, so that
+// business objects are decoded into the target type with the same semantics.
+type document struct {
+	// the persistence id of the document
+	pid  string
+	data bson.Raw
+	// the time the document was deleted, or the zero time if it has not been
+	deleted time.Time
+}
+
+// Holds business objects in a map keyed by their business id.  Queries are evaluated by offering every business object
+// to a scan.Selection under a read lock.
+//
+// A MemoryStore must be configured before use, and is safe for concurrent use thereafter.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	docs map[string]*document
+}
+
+// Readies the store, discarding any business objects it holds.  The store requires no configuration, so c is ignored
+// and may be nil.
+func (m *MemoryStore) Configure(c interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docs = make(map[string]*document)
+}
+
+func (m *MemoryStore) Retrieve(id string, t interface{}) error {
+	return m.RetrieveFields(id, nil, t)
+}
+
+func (m *MemoryStore) RetrieveFields(id string, fields []string, t interface{}) error {
+	if _, ok := t.(model.WebResource); !ok {
+		panic(fmt.Sprintf("store/memory: can only retrieve objects of type model.WebResource, not %T", t))
+	}
+
+	m.mu.RLock()
+	doc, ok := m.docs[id]
+	m.mu.RUnlock()
+
+	if !ok {
+		return store.SentinelErr(store.NotFoundErr, fmt.Sprintf("key: %s", id), nil)
+	}
+
+	// documents are replaced rather than modified, so one may be read outside of the lock
+	if !doc.deleted.IsZero() {
+		return store.SentinelErr(store.DeletedErr, fmt.Sprintf("key: %s", id), nil)
+	}
+
+	if err := bson.Unmarshal(scan.Project(doc.data, fields), t); err != nil {
+		return store.SentinelErr(store.DecodingErr, fmt.Sprintf("type:  %T", t), err)
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) RetrieveMany(ids []string, t interface{}) ([]string, error) {
+	var docs []bson.Raw
+	var missing []string
+	seen := map[string]bool{}
+
+	m.mu.RLock()
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if doc, ok := m.docs[id]; ok && doc.deleted.IsZero() {
+			docs = append(docs, doc.data)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	m.mu.RUnlock()
+
+	return missing, scan.DecodeAll(docs, t)
+}
+
+func (m *MemoryStore) Store(obj interface{}) (string, error) {
+	data, err := bson.Marshal(obj)
+	if err != nil {
+		return "", store.GenericErr("attempt to marshal document failed", err)
+	}
+
+	// documents without a business id share the empty id, as they share a null id under the unique index of mongo
+	id, _ := bson.Raw(data).Lookup(scan.IdField).StringValueOK()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.docs[id]; exists {
+		return "", store.SentinelErr(store.DuplicateKeyErr, "attempt to insert document failed",
+			fmt.Errorf("key: %s", id))
+	}
+
+	pid := primitive.NewObjectID().Hex()
+	m.docs[id] = &document{pid: pid, data: data}
+	return pid, nil
+}
+
+func (m *MemoryStore) StoreMany(objs []interface{}) ([]error, error) {
+	errs := make([]error, len(objs))
+	for i := range objs {
+		_, errs[i] = m.Store(objs[i])
+	}
+	return errs, nil
+}
+
+func (m *MemoryStore) Update(id string, obj interface{}) error {
+	data, err := bson.Marshal(obj)
+	if err != nil {
+		return store.GenericErr(fmt.Sprintf("attempt to marshal document with key %s failed", id), err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	doc, ok := m.docs[id]
+	if err := scan.Missing(id, ok, ok && !doc.deleted.IsZero(),
+		fmt.Sprintf("attempt to update document with key %s failed", id)); err != nil {
+		return err
+	}
+
+	m.docs[id] = &document{pid: doc.pid, data: data}
+	return nil
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	doc, ok := m.docs[id]
+	if err := scan.Missing(id, ok, ok && !doc.deleted.IsZero(),
+		fmt.Sprintf("attempt to delete document with key %s failed", id)); err != nil {
+		return err
+	}
+
+	m.docs[id] = &document{pid: doc.pid, data: doc.data, deleted: time.Now().UTC()}
+	return nil
+}
+
+func (m *MemoryStore) Purge(deletedBefore time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for id, doc := range m.docs {
+		if !doc.deleted.IsZero() && doc.deleted.Before(deletedBefore) {
+			delete(m.docs, id)
+			count++
+		}
+	}
+
+	return count, nil
+}
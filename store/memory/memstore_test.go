@@ -0,0 +1,30 @@
+package memory
+
+import (
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newStore() *MemoryStore {
+	m := &MemoryStore{}
+	m.Configure(nil)
+	return m
+}
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Api { return newStore() })
+}
+
+// configuring a store discards the business objects it holds
+func TestMemoryStore_Configure(t *testing.T) {
+	m := newStore()
+	_, err := m.Store(model.Neg{Id: "moo"})
+	require.Nil(t, err)
+
+	m.Configure(nil)
+	assert.Equal(t, store.CodeNotFound, store.CodeOf(m.Retrieve("moo", &model.Neg{})))
+}
@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/scan"
+)
+
+func (m *MemoryStore) List(q store.Query, t interface{}) (string, error) {
+	sel, err := m.selectDocs(q)
+	if err != nil {
+		return "", err
+	}
+
+	docs, next, err := sel.Page()
+	if err != nil {
+		return "", err
+	}
+
+	return next, scan.DecodeAll(docs, t)
+}
+
+// Opens an Iterator over a snapshot of the business objects selected by the query.  The business objects are already
+// held in memory, so they are selected at once rather than a page at a time.
+func (m *MemoryStore) Iterate(q store.Query) (store.Iterator, error) {
+	q.Cursor, q.Limit = "", 0
+	sel, err := m.selectDocs(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return scan.Iterate(sel.All()), nil
+}
+
+// Returns the Selection of the business objects that have not been deleted by the query
+func (m *MemoryStore) selectDocs(q store.Query) (*scan.Selection, error) {
+	sel, err := scan.Select(q)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, doc := range m.docs {
+		if doc.deleted.IsZero() {
+			sel.Offer(doc.data)
+		}
+	}
+
+	return sel, nil
+}
@@ -0,0 +1,17 @@
+package mongo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_VerifyConfigSchemaValidation(t *testing.T) {
+	config := MongoConfig{DbUri: "mongodb://localhost:27017", DbName: "negtracker", NegCollection: "neg"}
+	assert.Equal(t, ValidationStrict, verifyConfig(&config).SchemaValidation)
+
+	config.SchemaValidation = ValidationModerate
+	assert.Equal(t, ValidationModerate, verifyConfig(&config).SchemaValidation)
+
+	config.SchemaValidation = "lenient"
+	assert.Panics(t, func() { verifyConfig(&config) })
+}
@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/schema"
 	"github.com/emetsger/negtracker/store"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
 	"log"
 	"strings"
+	"time"
 )
 
 // Used to identify the field that mongo will use for storing business ids on persisted documents
@@ -23,10 +27,20 @@ import (
 //   }
 
 const (
-	idField       = "id"
-	errCodeDupKey = 11000
+	idField                 = "id"
+	errCodeDupKey           = 11000
+	errCodeNamespaceMissing = 26
 )
 
+// Used to identify the field that mongo will use for recording the time a document was deleted.  Deleted documents are
+// tombstones: they are retained until purged, but are otherwise invisible to callers.
+//
+// Note: the value of this constant must not collide with a field of the entity structs in the `model` package.
+const deletedField = "_deleted"
+
+// Condition on the deletedField selecting documents that have not been deleted
+var notDeleted = bson.M{"$exists": false}
+
 // Represents the configuration used for the MongoDB driver
 type MongoConfig struct {
 	// env var DB_URI
@@ -35,11 +49,27 @@ type MongoConfig struct {
 	DbName string
 	// env var DB_NEG_COLLECTION
 	NegCollection string
+	// env var DB_SCHEMA_VALIDATION, one of ValidationOff, ValidationModerate, or ValidationStrict.  Defaults to
+	// ValidationStrict if empty.
+	SchemaValidation string
 	//// *no* env var, for unit testing
 	//initOnConnect bool
 	Opts *options.ClientOptions
 }
 
+// Levels at which MongoDB validates the documents of the NegCollection against the schema of model.Neg, which is
+// installed as the $jsonSchema validator of the collection by Configure.  Validation applies to every write, including
+// those made outside of the API.
+const (
+	// Documents are not validated
+	ValidationOff = "off"
+	// Inserts, and updates of documents that are already valid, are validated; existing invalid documents may be
+	// updated regardless
+	ValidationModerate = "moderate"
+	// Inserts and updates are validated
+	ValidationStrict = "strict"
+)
+
 type MongoStore struct {
 	ctx    context.Context
 	client *mongo.Client
@@ -48,6 +78,10 @@ type MongoStore struct {
 }
 
 func (m *MongoStore) Retrieve(id string, t interface{}) error {
+	return m.RetrieveFields(id, nil, t)
+}
+
+func (m *MongoStore) RetrieveFields(id string, fields []string, t interface{}) error {
 	var res *mongo.SingleResult
 
 	// If t is an WebResource, then treat the supplied id as a business identifier,
@@ -62,40 +96,288 @@ func (m *MongoStore) Retrieve(id string, t interface{}) error {
 	if _, ok := t.(model.WebResource); !ok {
 		panic(fmt.Sprintf("store/mongo: can only retrieve objects of type model.WebResource, not %T", t))
 	} else {
-		res = m.negCol.FindOne(m.ctx, bson.M{idField: id})
+		opts := options.FindOne()
+		if p := projection(fields); p != nil {
+			opts.SetProjection(p)
+		}
+		res = m.negCol.FindOne(m.ctx, bson.M{idField: id}, opts)
 	}
 
-	err := res.Decode(t)
+	raw, err := res.DecodeBytes()
 
 	if err != nil {
-		return store.SentinelErr(store.DecodingErr, fmt.Sprintf("type:  %T", t), fmt.Sprintf("%v", err))
+		return driverErr(fmt.Sprintf("key: %s", id), err)
+	}
+
+	if _, lookupErr := raw.LookupErr(deletedField); lookupErr == nil {
+		return store.SentinelErr(store.DeletedErr, fmt.Sprintf("key: %s", id), nil)
+	}
+
+	if err = bson.Unmarshal(raw, t); err != nil {
+		return store.SentinelErr(store.DecodingErr, fmt.Sprintf("type:  %T", t), err)
 	}
 
 	return nil
 }
 
+func (m *MongoStore) RetrieveMany(ids []string, t interface{}) ([]string, error) {
+	if len(ids) == 0 {
+		// $in requires an array, which a nil slice is not encoded as
+		return nil, decodeAll(nil, t)
+	}
+
+	cur, err := m.negCol.Find(m.ctx, bson.M{idField: bson.M{"$in": ids}, deletedField: notDeleted})
+	if err != nil {
+		return nil, driverErr("attempt to retrieve documents failed", err)
+	}
+	defer func() { _ = cur.Close(m.ctx) }()
+
+	found := map[string]bson.Raw{}
+	for cur.Next(m.ctx) {
+		id, _ := cur.Current.Lookup(idField).StringValueOK()
+		// the current document is only valid until the cursor is advanced
+		found[id] = append(bson.Raw{}, cur.Current...)
+	}
+
+	if err = cur.Err(); err != nil {
+		return nil, driverErr("attempt to retrieve documents failed", err)
+	}
+
+	var docs []bson.Raw
+	var missing []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if doc, ok := found[id]; ok {
+			docs = append(docs, doc)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return missing, decodeAll(docs, t)
+}
+
 func (m *MongoStore) Store(obj interface{}) (string, error) {
 	var data []byte
 	var res *mongo.InsertOneResult
-	var id string
 	var err error
 
-	if data, err = bson.Marshal(obj); err == nil {
-		if res, err = m.negCol.InsertOne(m.ctx, data); err == nil {
-			id = res.InsertedID.(primitive.ObjectID).Hex()
+	if data, err = bson.Marshal(obj); err != nil {
+		return "", store.GenericErr("attempt to marshal document failed", err)
+	}
+
+	if res, err = m.negCol.InsertOne(m.ctx, data); err != nil {
+		return "", driverErr("attempt to insert document failed", err)
+	}
+
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (m *MongoStore) StoreMany(objs []interface{}) ([]error, error) {
+	errs := make([]error, len(objs))
+
+	// the documents to insert, and the position of each in objs
+	docs := make([]interface{}, 0, len(objs))
+	positions := make([]int, 0, len(objs))
+	for i := range objs {
+		if data, err := bson.Marshal(objs[i]); err != nil {
+			errs[i] = store.GenericErr("attempt to marshal document failed", err)
+		} else {
+			docs = append(docs, data)
+			positions = append(positions, i)
+		}
+	}
+
+	if len(docs) == 0 {
+		return errs, nil
+	}
+
+	// an unordered insert continues past the documents that cannot be inserted
+	_, err := m.negCol.InsertMany(m.ctx, docs, options.InsertMany().SetOrdered(false))
+
+	bwe := mongo.BulkWriteException{}
+	if errors.As(err, &bwe) && bwe.WriteConcernError == nil {
+		for _, werr := range bwe.WriteErrors {
+			i := positions[werr.Index]
+			if werr.Code == errCodeDupKey {
+				errs[i] = store.SentinelErr(store.DuplicateKeyErr, "attempt to insert document failed", werr.WriteError)
+			} else {
+				errs[i] = store.GenericErr("attempt to insert document failed", werr.WriteError)
+			}
 		}
+		return errs, nil
 	}
 
 	if err != nil {
-		if dupKeyCause(err) {
-			return id, store.SentinelErr(store.DuplicateKeyErr, "underlying error", fmt.Sprintf("%v", err))
-		} else {
-			return id, store.GenericErr(fmt.Sprintf("attempt to insert document with key %s failed", id),
-				fmt.Sprintf("%v", err))
+		return errs, driverErr("attempt to insert documents failed", err)
+	}
+
+	return errs, nil
+}
+
+func (m *MongoStore) Update(id string, obj interface{}) error {
+	var data []byte
+	var res *mongo.UpdateResult
+	var err error
+
+	if data, err = bson.Marshal(obj); err != nil {
+		return store.GenericErr(fmt.Sprintf("attempt to marshal document with key %s failed", id), err)
+	}
+
+	if res, err = m.negCol.ReplaceOne(m.ctx, bson.M{idField: id, deletedField: notDeleted}, data); err != nil {
+		return driverErr(fmt.Sprintf("attempt to update document with key %s failed", id), err)
+	}
+
+	if res.MatchedCount == 0 {
+		return m.missing(id, fmt.Sprintf("attempt to update document with key %s failed", id))
+	}
+
+	return nil
+}
+
+func (m *MongoStore) Delete(id string) error {
+	filter := bson.M{idField: id, deletedField: notDeleted}
+	tombstone := bson.M{"$set": bson.M{deletedField: time.Now().UTC()}}
+
+	res, err := m.negCol.UpdateOne(m.ctx, filter, tombstone)
+
+	if err != nil {
+		return driverErr(fmt.Sprintf("attempt to delete document with key %s failed", id), err)
+	}
+
+	if res.MatchedCount == 0 {
+		return m.missing(id, fmt.Sprintf("attempt to delete document with key %s failed", id))
+	}
+
+	return nil
+}
+
+func (m *MongoStore) Purge(deletedBefore time.Time) (int, error) {
+	res, err := m.negCol.DeleteMany(m.ctx, bson.M{deletedField: bson.M{"$lt": deletedBefore.UTC()}})
+
+	if err != nil {
+		return 0, driverErr(fmt.Sprintf("attempt to purge documents deleted before %s failed",
+			deletedBefore.Format(time.RFC3339)), err)
+	}
+
+	return int(res.DeletedCount), nil
+}
+
+func (m *MongoStore) List(q store.Query, t interface{}) (string, error) {
+	if q.Limit < 1 {
+		panic(fmt.Sprintf("store/mongo: query limit must be a positive integer (was: %d)", q.Limit))
+	}
+
+	filter, sort, err := translate(q)
+	if err != nil {
+		return "", err
+	}
+
+	// select one more document than the limit, in order to determine if there is a following page
+	opts := options.Find().SetSort(sort).SetLimit(int64(q.Limit + 1))
+	if p := projection(q.Fields, q.Sort.Field); p != nil {
+		opts.SetProjection(p)
+	}
+	cur, err := m.negCol.Find(m.ctx, filter, opts)
+	if err != nil {
+		return "", driverErr("attempt to list documents failed", err)
+	}
+	defer func() { _ = cur.Close(m.ctx) }()
+
+	var docs []bson.Raw
+	for cur.Next(m.ctx) {
+		// the current document is only valid until the cursor is advanced
+		docs = append(docs, append(bson.Raw{}, cur.Current...))
+	}
+
+	if err = cur.Err(); err != nil {
+		return "", driverErr("attempt to list documents failed", err)
+	}
+
+	var next string
+	if len(docs) > q.Limit {
+		docs = docs[:q.Limit]
+		if next, err = cursorAt(q, docs[len(docs)-1]); err != nil {
+			return "", store.GenericErr("attempt to encode cursor failed", err)
 		}
 	}
 
-	return id, nil
+	return next, decodeAll(docs, t)
+}
+
+func (m *MongoStore) Iterate(q store.Query) (store.Iterator, error) {
+	q.Cursor = ""
+	filter, sort, err := translate(q)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(sort)
+	if p := projection(q.Fields); p != nil {
+		opts.SetProjection(p)
+	}
+
+	cur, err := m.negCol.Find(m.ctx, filter, opts)
+	if err != nil {
+		return nil, driverErr("attempt to iterate documents failed", err)
+	}
+
+	return &iterator{ctx: m.ctx, cur: cur}, nil
+}
+
+// A store.Iterator over the documents of a mongo cursor
+type iterator struct {
+	ctx context.Context
+	cur *mongo.Cursor
+	err error
+}
+
+func (it *iterator) Next(t interface{}) bool {
+	if _, ok := t.(model.WebResource); !ok {
+		panic(fmt.Sprintf("store/mongo: can only iterate objects of type model.WebResource, not %T", t))
+	}
+
+	if it.err != nil || !it.cur.Next(it.ctx) {
+		return false
+	}
+
+	if err := bson.Unmarshal(it.cur.Current, t); err != nil {
+		it.err = store.SentinelErr(store.DecodingErr, fmt.Sprintf("type:  %T", t), err)
+		return false
+	}
+
+	return true
+}
+
+func (it *iterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	if err := it.cur.Err(); err != nil {
+		return driverErr("attempt to iterate documents failed", err)
+	}
+	return nil
+}
+
+func (it *iterator) Close() error {
+	if err := it.cur.Close(it.ctx); err != nil {
+		return driverErr("attempt to close cursor failed", err)
+	}
+	return nil
+}
+
+// Returns the error to use when a write operation on the document identified by id matched nothing: either the
+// document is a tombstone, or it does not exist.
+func (m *MongoStore) missing(id, msg string) error {
+	if count, err := m.negCol.CountDocuments(m.ctx, bson.M{idField: id}); err == nil && count > 0 {
+		return store.SentinelErr(store.DeletedErr, fmt.Sprintf("key: %s", id), nil)
+	}
+
+	return store.SentinelErr(store.NotFoundErr, msg, nil)
 }
 
 func (m *MongoStore) Configure(c interface{}) {
@@ -127,16 +409,77 @@ func (m *MongoStore) Configure(c interface{}) {
 	m.db = m.client.Database(config.DbName)
 	m.negCol = m.db.Collection(config.NegCollection)
 
+	// install the schema of model.Neg as the validator of the NegCollection, creating the collection if need be
+	if err = m.installValidator(config.NegCollection, config.SchemaValidation); err != nil {
+		panic("Unable to install schema validator on NegCollection, " + err.Error())
+	} else {
+		log.Printf("Installed schema validator on %s, validation level %s", config.NegCollection,
+			config.SchemaValidation)
+	}
+
 	// create unique index on business id for the NegCollection
-	idxKeys := bson.D{{"id", 1}}
+	idxKeys := bson.D{{Key: idField, Value: 1}}
 	idxBool := true
 	idxName := "Negative Business Id"
 	idxOpts := options.IndexOptions{Unique: &idxBool, Name: &idxName}
-	if idxName, idxErr := m.negCol.Indexes().CreateOne(m.ctx, mongo.IndexModel{idxKeys, &idxOpts}); idxErr != nil {
+	if idxName, idxErr := m.negCol.Indexes().CreateOne(m.ctx, mongo.IndexModel{Keys: idxKeys, Options: &idxOpts}); idxErr != nil {
 		panic("Unable to create unique business id index on NegCollection, " + idxErr.Error())
 	} else {
 		log.Printf("Created unique business id index on %s, %s", config.NegCollection, idxName)
 	}
+
+	// create indexes supporting the criteria and sorts of queries; sorts are broken by business id
+	var queryIdxs []mongo.IndexModel
+	for _, field := range []string{"Film", "Developer", "Format", "EI", "Created", "Updated"} {
+		queryIdxs = append(queryIdxs, mongo.IndexModel{
+			Keys:    bson.D{{Key: fieldName(field), Value: 1}, {Key: idField, Value: 1}},
+			Options: options.Index().SetName(fmt.Sprintf("Negative %s", field)),
+		})
+	}
+	queryIdxs = append(queryIdxs, mongo.IndexModel{
+		Keys:    bson.D{{Key: fieldName("Tags"), Value: 1}},
+		Options: options.Index().SetName("Negative Tags"),
+	})
+	if idxNames, idxErr := m.negCol.Indexes().CreateMany(m.ctx, queryIdxs); idxErr != nil {
+		panic("Unable to create query indexes on NegCollection, " + idxErr.Error())
+	} else {
+		log.Printf("Created query indexes on %s, %s", config.NegCollection, strings.Join(idxNames, ", "))
+	}
+
+	// create sparse index on the deletion time, used when purging tombstones
+	delIdxKeys := bson.D{{Key: deletedField, Value: 1}}
+	delIdxOpts := options.Index().SetSparse(true).SetName("Negative Deletion Time")
+	if idxName, idxErr := m.negCol.Indexes().CreateOne(m.ctx, mongo.IndexModel{Keys: delIdxKeys, Options: delIdxOpts}); idxErr != nil {
+		panic("Unable to create deletion time index on NegCollection, " + idxErr.Error())
+	} else {
+		log.Printf("Created deletion time index on %s, %s", config.NegCollection, idxName)
+	}
+}
+
+// Installs the schema of model.Neg as the $jsonSchema validator of the named collection, at the supplied validation
+// level.  The collection is created if it does not exist.
+func (m *MongoStore) installValidator(collection, level string) error {
+	validator := schema.Bson(&model.Neg{})
+
+	// documents also carry the fields maintained by this package
+	properties := validator["properties"].(map[string]interface{})
+	properties["_id"] = bson.M{"bsonType": "objectId"}
+	properties[deletedField] = bson.M{"bsonType": "date"}
+
+	cmd := bson.D{
+		{Key: "collMod", Value: collection},
+		{Key: "validator", Value: bson.M{"$jsonSchema": validator}},
+		{Key: "validationLevel", Value: level},
+	}
+	err := m.db.RunCommand(m.ctx, cmd).Err()
+
+	cmdErr := mongo.CommandError{}
+	if errors.As(err, &cmdErr) && cmdErr.Code == errCodeNamespaceMissing {
+		cmd[0] = bson.E{Key: "create", Value: collection}
+		err = m.db.RunCommand(m.ctx, cmd).Err()
+	}
+
+	return err
 }
 
 func verifyConfig(c interface{}) MongoConfig {
@@ -155,7 +498,17 @@ func verifyConfig(c interface{}) MongoConfig {
 	checkLen("MongoConfig.DbName", config.DbName)
 	checkLen("MongoConfig.NegCollection", config.NegCollection)
 
-	return *config
+	verified := *config
+	switch verified.SchemaValidation {
+	case "":
+		verified.SchemaValidation = ValidationStrict
+	case ValidationOff, ValidationModerate, ValidationStrict:
+	default:
+		panic(fmt.Sprintf("store/mongo: MongoConfig.SchemaValidation must be one of %s, %s, or %s (was: %s)",
+			ValidationOff, ValidationModerate, ValidationStrict, verified.SchemaValidation))
+	}
+
+	return verified
 }
 
 func checkLen(fieldName, fieldValue string) {
@@ -164,6 +517,40 @@ func checkLen(fieldName, fieldValue string) {
 	}
 }
 
+// Wraps an error returned by the mongo driver in a StorageError of the kind corresponding to its cause.
+func driverErr(msg string, err error) error {
+	switch {
+	case dupKeyCause(err):
+		return store.SentinelErr(store.DuplicateKeyErr, msg, err)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return store.SentinelErr(store.NotFoundErr, msg, err)
+	case unavailableCause(err):
+		return store.SentinelErr(store.UnavailableErr, msg, err)
+	default:
+		return store.GenericErr(msg, err)
+	}
+}
+
+// Returns true if the error was caused by the MongoDB server being unreachable or failing transiently, i.e. the
+// operation may succeed if retried.
+func unavailableCause(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, mongo.ErrClientDisconnected) {
+		return true
+	}
+
+	if errors.As(err, &topology.ConnectionError{}) {
+		return true
+	}
+
+	cmdErr := mongo.CommandError{}
+	if errors.As(err, &cmdErr) {
+		return cmdErr.HasErrorLabel(driver.NetworkError) || cmdErr.HasErrorLabel(driver.RetryableWriteError) ||
+			cmdErr.HasErrorLabel(driver.TransientTransactionError)
+	}
+
+	return false
+}
+
 // Returns true if the error is a mongo.WriteException caused by insertion of a duplicate key into a unique index
 func dupKeyCause(err error) bool {
 	wex := mongo.WriteException{}
@@ -0,0 +1,31 @@
+// +build integration
+
+package mongo
+
+import (
+	"fmt"
+	"github.com/emetsger/negtracker/store"
+	"github.com/emetsger/negtracker/store/storetest"
+	"testing"
+)
+
+// each test of the suite uses a collection of its own, which is dropped along with the database by TestMain
+func TestMongoStore_Conformance(t *testing.T) {
+	var stores []*MongoStore
+	defer func() {
+		for _, s := range stores {
+			_ = s.client.Disconnect(s.ctx)
+		}
+	}()
+
+	storetest.Run(t, func(t *testing.T) store.Api {
+		config := *TestConfig
+		config.NegCollection = fmt.Sprintf("%s_conformance_%d", TestConfig.NegCollection, len(stores))
+		// the suite stores business objects that are invalid per their schema
+		config.SchemaValidation = ValidationOff
+		s := &MongoStore{}
+		s.Configure(&config)
+		stores = append(stores, s)
+		return s
+	})
+}
@@ -0,0 +1,85 @@
+// +build integration
+
+package mongo
+
+import (
+	"errors"
+	"github.com/emetsger/negtracker/id"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+func TestMongoStore_Delete(t *testing.T) {
+	obj := sampleNeg
+	obj.Id = id.Mint()
+
+	_, err := underTest.Store(obj)
+	require.Nil(t, err)
+
+	require.Nil(t, underTest.Delete(obj.Id))
+
+	// the tombstone is retained
+	count, err := underTest.negCol.CountDocuments(underTest.ctx, bson.M{idField: obj.Id})
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	neg := model.Neg{}
+	err = underTest.Retrieve(obj.Id, &neg)
+	assert.True(t, errors.Is(err, store.DeletedErr))
+
+	err = underTest.Update(obj.Id, obj)
+	assert.True(t, errors.Is(err, store.DeletedErr))
+
+	err = underTest.Delete(obj.Id)
+	assert.True(t, errors.Is(err, store.DeletedErr))
+
+	// the business id of a tombstone may not be reused
+	_, err = underTest.Store(obj)
+	assert.True(t, errors.Is(err, store.DuplicateKeyErr))
+}
+
+func TestMongoStore_DeleteMissing(t *testing.T) {
+	err := underTest.Delete(id.Mint())
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, store.NotFoundErr))
+	assert.False(t, errors.Is(err, store.DeletedErr))
+}
+
+func TestMongoStore_Purge(t *testing.T) {
+	deleted := sampleNeg
+	deleted.Id = id.Mint()
+	retained := sampleNeg
+	retained.Id = id.Mint()
+
+	for _, obj := range []model.Neg{deleted, retained} {
+		_, err := underTest.Store(obj)
+		require.Nil(t, err)
+	}
+
+	require.Nil(t, underTest.Delete(deleted.Id))
+
+	// nothing was deleted before an hour ago
+	count, err := underTest.Purge(time.Now().Add(-1 * time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = underTest.Purge(time.Now().Add(time.Second))
+	require.Nil(t, err)
+	assert.True(t, count > 0)
+
+	remaining, err := underTest.negCol.CountDocuments(underTest.ctx, bson.M{idField: deleted.Id})
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), remaining)
+
+	neg := model.Neg{}
+	err = underTest.Retrieve(deleted.Id, &neg)
+	assert.True(t, errors.Is(err, store.NotFoundErr))
+
+	require.Nil(t, underTest.Retrieve(retained.Id, &neg))
+	assert.Equal(t, retained, neg)
+}
@@ -0,0 +1,190 @@
+// +build integration
+
+package mongo
+
+import (
+	"errors"
+	"github.com/emetsger/negtracker/id"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMongoStore_ListPages(t *testing.T) {
+	// a film unique to this test, so that only the negs stored here are selected
+	film := id.Mint()
+	for ei := 100; ei <= 700; ei += 100 {
+		obj := sampleNeg
+		obj.Id = id.Mint()
+		obj.Film = film
+		obj.EI = ei
+		_, err := underTest.Store(obj)
+		require.Nil(t, err)
+	}
+
+	q := store.Query{
+		Criteria: []store.Criterion{{Field: "Film", Op: store.Eq, Value: film}},
+		Sort:     store.Sort{Field: "EI", Descending: true},
+		Limit:    3,
+	}
+
+	var eis []int
+	for pages := 0; ; pages++ {
+		require.True(t, pages < 3)
+		negs := []model.Neg{}
+		next, err := underTest.List(q, &negs)
+		require.Nil(t, err)
+		for i := range negs {
+			eis = append(eis, negs[i].EI)
+		}
+		if next == "" {
+			break
+		}
+		q.Cursor = next
+	}
+
+	assert.Equal(t, []int{700, 600, 500, 400, 300, 200, 100}, eis)
+}
+
+func TestMongoStore_ListCriteria(t *testing.T) {
+	film := id.Mint()
+	for ei := 100; ei <= 700; ei += 100 {
+		obj := sampleNeg
+		obj.Id = id.Mint()
+		obj.Film = film
+		obj.EI = ei
+		if ei == 400 {
+			obj.Tags = []string{"pushed"}
+		}
+		_, err := underTest.Store(obj)
+		require.Nil(t, err)
+	}
+
+	negs := []model.Neg{}
+	_, err := underTest.List(store.Query{
+		Criteria: []store.Criterion{
+			{Field: "Film", Op: store.Eq, Value: film},
+			{Field: "EI", Op: store.Gte, Value: 200},
+			{Field: "EI", Op: store.Lte, Value: 500},
+		},
+		Limit: 10,
+	}, &negs)
+	require.Nil(t, err)
+	assert.Len(t, negs, 4)
+
+	negs = []model.Neg{}
+	_, err = underTest.List(store.Query{
+		Criteria: []store.Criterion{
+			{Field: "Film", Op: store.Eq, Value: film},
+			{Field: "Tags", Op: store.Contains, Value: "pushed"},
+		},
+		Limit: 10,
+	}, &negs)
+	require.Nil(t, err)
+	require.Len(t, negs, 1)
+	assert.Equal(t, 400, negs[0].EI)
+}
+
+func TestMongoStore_ListExcludesDeleted(t *testing.T) {
+	film := id.Mint()
+	obj := sampleNeg
+	obj.Id = id.Mint()
+	obj.Film = film
+	_, err := underTest.Store(obj)
+	require.Nil(t, err)
+	require.Nil(t, underTest.Delete(obj.Id))
+
+	negs := []model.Neg{}
+	next, err := underTest.List(store.Query{
+		Criteria: []store.Criterion{{Field: "Film", Op: store.Eq, Value: film}},
+		Limit:    10,
+	}, &negs)
+	require.Nil(t, err)
+	assert.Equal(t, "", next)
+	assert.Empty(t, negs)
+}
+
+func TestMongoStore_ListInvalidCursor(t *testing.T) {
+	negs := []model.Neg{}
+	_, err := underTest.List(store.Query{Limit: 10, Cursor: "moo"}, &negs)
+	assert.True(t, errors.Is(err, store.InvalidQueryErr))
+}
+
+func TestMongoStore_Iterate(t *testing.T) {
+	film := id.Mint()
+	for ei := 100; ei <= 300; ei += 100 {
+		obj := sampleNeg
+		obj.Id = id.Mint()
+		obj.Film = film
+		obj.EI = ei
+		_, err := underTest.Store(obj)
+		require.Nil(t, err)
+	}
+
+	deleted := sampleNeg
+	deleted.Id = id.Mint()
+	deleted.Film = film
+	_, err := underTest.Store(deleted)
+	require.Nil(t, err)
+	require.Nil(t, underTest.Delete(deleted.Id))
+
+	it, err := underTest.Iterate(store.Query{
+		Criteria: []store.Criterion{{Field: "Film", Op: store.Eq, Value: film}},
+		Sort:     store.Sort{Field: "EI", Descending: true},
+		// ignored by Iterate
+		Limit: 1,
+	})
+	require.Nil(t, err)
+	defer func() { _ = it.Close() }()
+
+	var eis []int
+	neg := model.Neg{}
+	for it.Next(&neg) {
+		eis = append(eis, neg.EI)
+		neg = model.Neg{}
+	}
+	assert.Nil(t, it.Err())
+	assert.Equal(t, []int{300, 200, 100}, eis)
+}
+
+func TestMongoStore_ListFields(t *testing.T) {
+	film := id.Mint()
+	for ei := 100; ei <= 300; ei += 100 {
+		obj := sampleNeg
+		obj.Id = id.Mint()
+		obj.Film = film
+		obj.EI = ei
+		_, err := underTest.Store(obj)
+		require.Nil(t, err)
+	}
+
+	q := store.Query{
+		Criteria: []store.Criterion{{Field: "Film", Op: store.Eq, Value: film}},
+		Sort:     store.Sort{Field: "EI"},
+		Limit:    2,
+		Fields:   []string{"Tags"},
+	}
+
+	var eis []int
+	for pages := 0; ; pages++ {
+		require.True(t, pages < 2)
+		negs := []model.Neg{}
+		next, err := underTest.List(q, &negs)
+		require.Nil(t, err)
+		for i := range negs {
+			// the sort field is retrieved, as cursors are derived from it
+			eis = append(eis, negs[i].EI)
+			assert.Equal(t, sampleNeg.Tags, negs[i].Tags)
+			assert.NotEmpty(t, negs[i].Id)
+			assert.Empty(t, negs[i].Film)
+		}
+		if next == "" {
+			break
+		}
+		q.Cursor = next
+	}
+
+	assert.Equal(t, []int{100, 200, 300}, eis)
+}
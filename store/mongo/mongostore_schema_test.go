@@ -0,0 +1,47 @@
+// +build integration
+
+package mongo
+
+import (
+	"github.com/emetsger/negtracker/id"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+	"time"
+)
+
+// The code of the error returned by MongoDB when a document fails validation
+const errCodeDocumentValidation = 121
+
+// Documents written outside of the API are validated against the schema of model.Neg
+func TestMongoStore_SchemaValidation(t *testing.T) {
+	valid := bson.M{idField: id.Mint(), "film": "Tri-X", "ei": 400, "format": "120", "tags": nil,
+		"created": time.Now()}
+	_, err := underTest.negCol.InsertOne(underTest.ctx, valid)
+	require.Nil(t, err)
+
+	for _, doc := range []bson.M{
+		{idField: id.Mint()},
+		{idField: id.Mint(), "film": "  "},
+		{idField: id.Mint(), "film": "Tri-X", "ei": -1},
+		{idField: id.Mint(), "film": "Tri-X", "ei": "400"},
+		{idField: id.Mint(), "film": "Tri-X", "format": "126"},
+		{idField: id.Mint(), "film": "Tri-X", "created": "yesterday"},
+		{idField: id.Mint(), "film": "Tri-X", "speed": 400},
+	} {
+		_, err := underTest.negCol.InsertOne(underTest.ctx, doc)
+		require.NotNil(t, err, "%v", doc)
+		wex, ok := err.(mongo.WriteException)
+		require.True(t, ok, "%v", err)
+		assert.Equal(t, errCodeDocumentValidation, wex.WriteErrors[0].Code)
+	}
+
+	// tombstones remain valid
+	obj := sampleNeg
+	obj.Id = id.Mint()
+	_, err = underTest.Store(obj)
+	require.Nil(t, err)
+	assert.Nil(t, underTest.Delete(obj.Id))
+}
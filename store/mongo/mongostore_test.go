@@ -50,10 +50,10 @@ func TestMongoStore_StoreAndRetrieve(t *testing.T) {
 func TestMongoStore_dupKeyCause(t *testing.T) {
 	var err error
 
-	_, err = underTest.negCol.InsertOne(underTest.ctx, bson.M{idField: "1"})
+	_, err = underTest.negCol.InsertOne(underTest.ctx, bson.M{idField: "1", "film": "Tri-X"})
 	require.Nil(t, err)
 
-	_, err = underTest.negCol.InsertOne(underTest.ctx, bson.M{idField: "1"})
+	_, err = underTest.negCol.InsertOne(underTest.ctx, bson.M{idField: "1", "film": "Tri-X"})
 	require.NotNil(t, err)
 
 	// errors.Is and errors.As are broken for mongo.WriteException, I believe
@@ -82,6 +82,83 @@ func TestMongoStore_DuplicateBusinessIds(t *testing.T) {
 	log.Print(err.Error())
 }
 
+func TestMongoStore_Update(t *testing.T) {
+	obj := sampleNeg
+	obj.Id = id.Mint()
+
+	_, err := underTest.Store(obj)
+	require.Nil(t, err)
+
+	obj.Film = "HP5"
+	obj.Tags = []string{"updated"}
+	require.Nil(t, underTest.Update(obj.Id, obj))
+
+	neg := model.Neg{}
+	require.Nil(t, underTest.Retrieve(obj.Id, &neg))
+	assert.Equal(t, obj, neg)
+}
+
+func TestMongoStore_UpdateMissing(t *testing.T) {
+	obj := sampleNeg
+	obj.Id = id.Mint()
+
+	err := underTest.Update(obj.Id, obj)
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, store.NotFoundErr))
+	log.Print(err.Error())
+}
+
+func TestMongoStore_RetrieveFields(t *testing.T) {
+	obj := sampleNeg
+	obj.Id = id.Mint()
+	_, err := underTest.Store(obj)
+	require.Nil(t, err)
+
+	neg := model.Neg{}
+	require.Nil(t, underTest.RetrieveFields(obj.Id, []string{"Film", "EI"}, &neg))
+	assert.Equal(t, model.Neg{Id: obj.Id, Film: obj.Film, EI: obj.EI}, neg)
+
+	require.Nil(t, underTest.Delete(obj.Id))
+	err = underTest.RetrieveFields(obj.Id, []string{"Film"}, &neg)
+	assert.True(t, errors.Is(err, store.DeletedErr))
+}
+
+func TestMongoStore_RetrieveMany(t *testing.T) {
+	var ids []string
+	for i := 0; i < 3; i++ {
+		obj := sampleNeg
+		obj.Id = id.Mint()
+		_, err := underTest.Store(obj)
+		require.Nil(t, err)
+		ids = append(ids, obj.Id)
+	}
+	require.Nil(t, underTest.Delete(ids[1]))
+	absent := id.Mint()
+
+	negs := []model.Neg{}
+	missing, err := underTest.RetrieveMany([]string{ids[2], absent, ids[0], ids[1], ids[2]}, &negs)
+	require.Nil(t, err)
+	assert.Equal(t, []string{absent, ids[1]}, missing)
+	require.Len(t, negs, 2)
+	assert.Equal(t, ids[2], negs[0].Id)
+	assert.Equal(t, ids[0], negs[1].Id)
+
+	negs = []model.Neg{}
+	missing, err = underTest.RetrieveMany([]string{}, &negs)
+	require.Nil(t, err)
+	assert.Empty(t, missing)
+	assert.Empty(t, negs)
+}
+
+func TestMongoStore_RetrieveNotFound(t *testing.T) {
+	neg := model.Neg{}
+	err := underTest.Retrieve(id.Mint(), &neg)
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, store.NotFoundErr))
+	assert.False(t, errors.Is(err, store.DeletedErr))
+	log.Print(err.Error())
+}
+
 func TestMain(m *testing.M) {
 	// Configure the store
 	underTest.Configure(TestConfig)
@@ -0,0 +1,165 @@
+package mongo
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"strings"
+)
+
+// The position of the last business object in a page of query results.  Encoded as an opaque string and returned to
+// callers of List, who supply it in a subsequent query to select the following page.
+type cursor struct {
+	// The sort field of the query that produced the cursor
+	Field string
+	// The sort direction of the query that produced the cursor
+	Descending bool
+	// The value of the sort field of the last business object in the page, absent when sorting by business id
+	Value *bson.RawValue `bson:",omitempty"`
+	// The business id of the last business object in the page
+	Id string
+}
+
+func (c cursor) encode() (string, error) {
+	data, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(encoded string) (c cursor, err error) {
+	var data []byte
+	if data, err = base64.RawURLEncoding.DecodeString(encoded); err == nil {
+		err = bson.Unmarshal(data, &c)
+	}
+	return c, err
+}
+
+// Maps the name of a field declared by a model struct to the name of the field in a persisted document.  The mongo
+// driver lower-cases struct field names when marshaling.
+func fieldName(field string) string {
+	return strings.ToLower(field)
+}
+
+var operators = map[store.Operator]string{
+	store.Gte: "$gte",
+	store.Lte: "$lte",
+}
+
+// Translates a store.Query into a mongo filter and sort.  The supplied cursor, if present, restricts the filter to
+// documents that follow the cursor position in the sort order.
+func translate(q store.Query) (filter bson.D, sort bson.D, err error) {
+	conditions := bson.A{bson.M{deletedField: notDeleted}}
+
+	for _, c := range q.Criteria {
+		switch c.Op {
+		case store.Eq, store.Contains:
+			// an equality condition on an array field matches arrays containing the value
+			conditions = append(conditions, bson.M{fieldName(c.Field): c.Value})
+		case store.Gte, store.Lte:
+			conditions = append(conditions, bson.M{fieldName(c.Field): bson.M{operators[c.Op]: c.Value}})
+		default:
+			return nil, nil, store.SentinelErr(store.InvalidQueryErr, fmt.Sprintf("unknown operator %d", c.Op), nil)
+		}
+	}
+
+	direction, comparison := 1, "$gt"
+	if q.Sort.Descending {
+		direction, comparison = -1, "$lt"
+	}
+
+	sortField := idField
+	if q.Sort.Field != "" {
+		sortField = fieldName(q.Sort.Field)
+	}
+
+	sort = bson.D{{Key: sortField, Value: direction}}
+	if sortField != idField {
+		sort = append(sort, bson.E{Key: idField, Value: direction})
+	}
+
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, nil, store.SentinelErr(store.InvalidQueryErr, "malformed cursor", err)
+		}
+
+		if c.Field != q.Sort.Field || c.Descending != q.Sort.Descending || (sortField != idField && c.Value == nil) {
+			return nil, nil, store.SentinelErr(store.InvalidQueryErr, "cursor does not match the sort of the query", nil)
+		}
+
+		if sortField == idField {
+			conditions = append(conditions, bson.M{idField: bson.M{comparison: c.Id}})
+		} else {
+			conditions = append(conditions, bson.M{"$or": bson.A{
+				bson.M{sortField: bson.M{comparison: *c.Value}},
+				bson.M{sortField: *c.Value, idField: bson.M{comparison: c.Id}},
+			}})
+		}
+	}
+
+	return bson.D{{Key: "$and", Value: conditions}}, sort, nil
+}
+
+// Translates the names of the fields to retrieve into a mongo projection, or nil if every field is to be retrieved.
+// The business id and deletion time of documents are always projected, as are the additional fields supplied, e.g. the
+// sort field of a query, from which cursors are derived.
+func projection(fields []string, additional ...string) bson.D {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	p := bson.D{{Key: idField, Value: 1}, {Key: deletedField, Value: 1}}
+	projected := map[string]bool{idField: true, deletedField: true}
+	for _, field := range append(append([]string{}, fields...), additional...) {
+		if name := fieldName(field); field != "" && !projected[name] {
+			p = append(p, bson.E{Key: name, Value: 1})
+			projected[name] = true
+		}
+	}
+
+	return p
+}
+
+// Returns the cursor positioned at the supplied document, which is the last document of a page.
+func cursorAt(q store.Query, doc bson.Raw) (string, error) {
+	c := cursor{Field: q.Sort.Field, Descending: q.Sort.Descending}
+	c.Id, _ = doc.Lookup(idField).StringValueOK()
+
+	if q.Sort.Field != "" {
+		value := doc.Lookup(fieldName(q.Sort.Field))
+		c.Value = &value
+	}
+
+	return c.encode()
+}
+
+// Unmarshals each of the supplied documents, appending them to the slice pointed to by t.
+func decodeAll(docs []bson.Raw, t interface{}) error {
+	ptr := reflect.ValueOf(t)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice {
+		panic(fmt.Sprintf("store/mongo: can only list into a pointer to a slice, not %T", t))
+	}
+
+	slice := ptr.Elem()
+	elemType := slice.Type().Elem()
+	if _, ok := reflect.New(elemType).Interface().(model.WebResource); !ok {
+		panic(fmt.Sprintf("store/mongo: can only list objects of type model.WebResource, not %v", elemType))
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, len(docs))
+	for i := range docs {
+		elem := reflect.New(elemType)
+		if err := bson.Unmarshal(docs[i], elem.Interface()); err != nil {
+			return store.SentinelErr(store.DecodingErr, fmt.Sprintf("type:  %v", elemType), err)
+		}
+		result = reflect.Append(result, elem.Elem())
+	}
+
+	slice.Set(result)
+	return nil
+}
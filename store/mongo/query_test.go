@@ -0,0 +1,86 @@
+package mongo
+
+import (
+	"errors"
+	"github.com/emetsger/negtracker/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+func Test_TranslateQuery(t *testing.T) {
+	q := store.Query{
+		Criteria: []store.Criterion{
+			{Field: "Film", Op: store.Eq, Value: "Tri-X"},
+			{Field: "EI", Op: store.Gte, Value: 200},
+			{Field: "Tags", Op: store.Contains, Value: "spring"},
+		},
+		Sort:  store.Sort{Field: "Created", Descending: true},
+		Limit: 10,
+	}
+
+	filter, sort, err := translate(q)
+	require.Nil(t, err)
+
+	assert.Equal(t, bson.D{{Key: "created", Value: -1}, {Key: idField, Value: -1}}, sort)
+	assert.Equal(t, bson.D{{Key: "$and", Value: bson.A{
+		bson.M{deletedField: notDeleted},
+		bson.M{"film": "Tri-X"},
+		bson.M{"ei": bson.M{"$gte": 200}},
+		bson.M{"tags": "spring"},
+	}}}, filter)
+}
+
+func Test_TranslateQueryCursor(t *testing.T) {
+	q := store.Query{Sort: store.Sort{Field: "Created"}, Limit: 10}
+	created := time.Date(2020, 9, 12, 14, 30, 15, 0, time.UTC)
+
+	doc, err := bson.Marshal(bson.M{idField: "moo", "created": created})
+	require.Nil(t, err)
+
+	q.Cursor, err = cursorAt(q, doc)
+	require.Nil(t, err)
+
+	filter, _, err := translate(q)
+	require.Nil(t, err)
+
+	conditions := filter[0].Value.(bson.A)
+	require.Len(t, conditions, 2)
+	or := conditions[1].(bson.M)["$or"].(bson.A)
+	value := or[0].(bson.M)["created"].(bson.M)["$gt"].(bson.RawValue)
+	assert.Equal(t, created, value.Time().UTC())
+	assert.Equal(t, bson.M{"$gt": "moo"}, or[1].(bson.M)[idField])
+}
+
+func Test_TranslateQueryCursorById(t *testing.T) {
+	q := store.Query{Sort: store.Sort{Descending: true}, Limit: 10}
+
+	doc, err := bson.Marshal(bson.M{idField: "moo"})
+	require.Nil(t, err)
+
+	q.Cursor, err = cursorAt(q, doc)
+	require.Nil(t, err)
+
+	filter, sort, err := translate(q)
+	require.Nil(t, err)
+	assert.Equal(t, bson.D{{Key: idField, Value: -1}}, sort)
+	assert.Equal(t, bson.M{idField: bson.M{"$lt": "moo"}}, filter[0].Value.(bson.A)[1])
+}
+
+func Test_TranslateQueryInvalidCursor(t *testing.T) {
+	_, _, err := translate(store.Query{Limit: 10, Cursor: "moo"})
+	assert.True(t, errors.Is(err, store.InvalidQueryErr))
+
+	// a cursor is only valid for the sort that produced it
+	q := store.Query{Sort: store.Sort{Field: "Film"}, Limit: 10}
+	doc, err := bson.Marshal(bson.M{idField: "moo", "film": "Tri-X"})
+	require.Nil(t, err)
+	q.Cursor, err = cursorAt(q, doc)
+	require.Nil(t, err)
+
+	q.Sort.Field = "EI"
+	_, _, err = translate(q)
+	assert.True(t, errors.Is(err, store.InvalidQueryErr))
+}
@@ -0,0 +1,65 @@
+package store
+
+// Compares the value of a field of a business object with the value of a Criterion
+type Operator int
+
+const (
+	// The field is equal to the value
+	Eq Operator = iota
+	// The field is greater than or equal to the value
+	Gte
+	// The field is less than or equal to the value
+	Lte
+	// The field is an array, and one of its elements is equal to the value
+	Contains
+)
+
+// A condition on the value of a field of a business object.
+type Criterion struct {
+	// The name of the field, as declared by the model struct, e.g. "Film"
+	Field string
+	// Compares the value of the field with Value
+	Op Operator
+	// The value the field is compared with, e.g. a string, int, or time.Time
+	Value interface{}
+}
+
+// Orders the results of a Query by the value of a field.  The zero value orders results by business id.
+type Sort struct {
+	// The name of the field, as declared by the model struct, e.g. "Created".  If empty, results are ordered by
+	// business id.
+	Field string
+	// Orders results from the greatest value to the least
+	Descending bool
+}
+
+// Selects a page of business objects from the storage layer.  Deleted business objects are never selected.
+type Query struct {
+	// Every criterion must be satisfied by a business object in order for it to be selected
+	Criteria []Criterion
+	// Orders the selected business objects.  Ties are broken by business id, so the order is stable across pages.
+	Sort Sort
+	// The maximum number of business objects in a page.  Must be greater than zero.
+	Limit int
+	// An opaque cursor returned by a previous query, used to select the following page.  The cursor is only valid for
+	// a query having the same Sort as the query that returned it.  If empty, the first page is selected.
+	Cursor string
+	// The names of the fields of the selected business objects to retrieve, as declared by the model struct, e.g.
+	// "Film".  Fields that are not named may be left with their zero value.  If empty, every field is retrieved.
+	Fields []string
+}
+
+// Visits the business objects selected by a query, one at a time.  Not safe for concurrent use.
+type Iterator interface {
+	// Advances to the next business object, and unmarshals it to t, which must be a pointer to a model struct.  Returns
+	// false when there are no further business objects, or when an error occurs; the error is returned by Err.
+	Next(t interface{}) bool
+
+	// Returns the error that caused Next to return false, or nil if every business object was visited.
+	Err() error
+
+	// Releases the resources held by the Iterator.  Must be called once the caller is done with the Iterator.
+	Close() error
+}
+
+var InvalidQueryErr error = &kind{CodeInvalidQuery, "store: invalid query", false}
@@ -0,0 +1,482 @@
+// Evaluates store.Query against business objects marshaled to BSON, for implementations of store.Api whose storage
+// layer cannot evaluate queries itself, e.g. store/memory and store/bolt.  Documents are selected as business objects
+// are offered to a Selection, which retains no more than a page of them, and the results, their order, their cursors
+// and their projections mirror those of store/mongo.
+package scan
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"fmt"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Used to identify the field of a marshaled document that holds the business id, as it is by store/mongo
+const IdField = "id"
+
+// The position of the last business object in a page of query results.
+type cursor struct {
+	Field      string
+	Descending bool
+	Value      *bson.RawValue `bson:",omitempty"`
+	Id         string
+}
+
+func (c cursor) encode() (string, error) {
+	data, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(encoded string) (c cursor, err error) {
+	var data []byte
+	if data, err = base64.RawURLEncoding.DecodeString(encoded); err == nil {
+		err = bson.Unmarshal(data, &c)
+	}
+	return c, err
+}
+
+// Maps the name of a field declared by a model struct to the name of the field in a marshaled document, mirroring the
+// mongo driver which lower-cases struct field names.
+func fieldName(field string) string {
+	return strings.ToLower(field)
+}
+
+// Compares two BSON values of comparable types: numbers, strings, and date times.  The returned boolean is false if the
+// values are not comparable.
+func compare(a, b bson.RawValue) (int, bool) {
+	if af, ok := number(a); ok {
+		if bf, ok := number(b); ok {
+			switch {
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			}
+			return 0, true
+		}
+		return 0, false
+	}
+
+	switch {
+	case a.Type == bsontype.String && b.Type == bsontype.String:
+		return strings.Compare(a.StringValue(), b.StringValue()), true
+	case a.Type == bsontype.DateTime && b.Type == bsontype.DateTime:
+		switch ad, bd := a.DateTime(), b.DateTime(); {
+		case ad < bd:
+			return -1, true
+		case ad > bd:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func number(v bson.RawValue) (float64, bool) {
+	switch v.Type {
+	case bsontype.Int32:
+		return float64(v.Int32()), true
+	case bsontype.Int64:
+		return float64(v.Int64()), true
+	case bsontype.Double:
+		return v.Double(), true
+	}
+	return 0, false
+}
+
+// Returns true if the document satisfies the criterion, whose value has been marshaled
+func satisfies(doc bson.Raw, c store.Criterion, value bson.RawValue) bool {
+	field, err := doc.LookupErr(fieldName(c.Field))
+	if err != nil {
+		return false
+	}
+
+	switch c.Op {
+	case store.Eq:
+		result, ok := compare(field, value)
+		return ok && result == 0
+	case store.Gte:
+		result, ok := compare(field, value)
+		return ok && result >= 0
+	case store.Lte:
+		result, ok := compare(field, value)
+		return ok && result <= 0
+	case store.Contains:
+		arr, ok := field.ArrayOK()
+		if !ok {
+			return false
+		}
+		elems, _ := arr.Values()
+		for i := range elems {
+			if result, ok := compare(elems[i], value); ok && result == 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Orders documents by the value of the sort field, breaking ties by business id
+type ordering struct {
+	field      string
+	descending bool
+}
+
+func (o ordering) less(a, b bson.Raw) bool {
+	if o.field != IdField {
+		if result, _ := compare(a.Lookup(o.field), b.Lookup(o.field)); result != 0 {
+			return (result < 0) != o.descending
+		}
+	}
+
+	aid, _ := a.Lookup(IdField).StringValueOK()
+	bid, _ := b.Lookup(IdField).StringValueOK()
+	if aid == bid {
+		return false
+	}
+	return (aid < bid) != o.descending
+}
+
+// Returns true if the document follows the cursor position in the ordering
+func (o ordering) after(doc bson.Raw, c cursor) bool {
+	position := bson.D{{Key: IdField, Value: c.Id}}
+	if c.Value != nil {
+		position = append(position, bson.E{Key: o.field, Value: *c.Value})
+	}
+	data, _ := bson.Marshal(position)
+	return o.less(data, doc)
+}
+
+// Accumulates the documents selected by a query as every document is offered to it, e.g.:
+//   sel, err := scan.Select(q)
+//   for _, doc := range docs {
+//   	sel.Offer(doc)
+//   }
+//   page, next, err := sel.Page()
+//
+// If the query has a Limit, only the first Limit+1 of the selected documents in the order of its sort are retained,
+// however many are offered: enough for a page, and to tell whether another page follows it.
+type Selection struct {
+	q        store.Query
+	o        ordering
+	c        *cursor
+	values   []bson.RawValue
+	selected *docHeap
+	// true if only the business id and sort field of the selected documents are retained
+	keysOnly bool
+}
+
+// The documents retained by a Selection, ordered so that the last of them in the order of the sort is at the root
+type docHeap struct {
+	o    ordering
+	docs []bson.Raw
+}
+
+func (h *docHeap) Len() int           { return len(h.docs) }
+func (h *docHeap) Less(i, j int) bool { return h.o.less(h.docs[j], h.docs[i]) }
+func (h *docHeap) Swap(i, j int)      { h.docs[i], h.docs[j] = h.docs[j], h.docs[i] }
+func (h *docHeap) Push(x interface{}) { h.docs = append(h.docs, x.(bson.Raw)) }
+
+func (h *docHeap) Pop() interface{} {
+	last := h.docs[len(h.docs)-1]
+	h.docs = h.docs[:len(h.docs)-1]
+	return last
+}
+
+// Returns a Selection of the documents satisfying the criteria of the query, and following its cursor, if any.  Errors
+// caused by a malformed query wrap InvalidQueryErr.
+func Select(q store.Query) (*Selection, error) {
+	sel := &Selection{q: q, o: ordering{field: IdField, descending: q.Sort.Descending}}
+	if q.Sort.Field != "" {
+		sel.o.field = fieldName(q.Sort.Field)
+	}
+
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, store.SentinelErr(store.InvalidQueryErr, "malformed cursor", err)
+		}
+		if c.Field != q.Sort.Field || c.Descending != q.Sort.Descending || (sel.o.field != IdField && c.Value == nil) {
+			return nil, store.SentinelErr(store.InvalidQueryErr, "cursor does not match the sort of the query", nil)
+		}
+		sel.c = &c
+	}
+
+	for _, c := range q.Criteria {
+		t, data, err := bson.MarshalValue(c.Value)
+		if err != nil {
+			return nil, store.SentinelErr(store.InvalidQueryErr, fmt.Sprintf("invalid value for %s", c.Field), err)
+		}
+		switch c.Op {
+		case store.Eq, store.Gte, store.Lte, store.Contains:
+		default:
+			return nil, store.SentinelErr(store.InvalidQueryErr, fmt.Sprintf("unknown operator %d", c.Op), nil)
+		}
+		sel.values = append(sel.values, bson.RawValue{Type: t, Value: data})
+	}
+
+	sel.selected = &docHeap{o: sel.o}
+	return sel, nil
+}
+
+// Returns a Selection as Select does, except that only the business id and the sort field of each selected document are
+// retained, so that every selected document may be ordered without holding them in memory; see Ids.
+func SelectKeys(q store.Query) (*Selection, error) {
+	sel, err := Select(q)
+	if err == nil {
+		sel.keysOnly = true
+	}
+	return sel, err
+}
+
+// Offers the document to the Selection, which retains a copy of it if it is selected, and it is among the first Limit+1
+// of the selected documents.  The document need not remain valid once Offer returns.
+func (s *Selection) Offer(doc bson.Raw) {
+	if s.c != nil && !s.o.after(doc, *s.c) {
+		return
+	}
+
+	for i := range s.q.Criteria {
+		if !satisfies(doc, s.q.Criteria[i], s.values[i]) {
+			return
+		}
+	}
+
+	if s.Full() {
+		// the document displaces the last of those retained, if it precedes it
+		if s.o.less(doc, s.selected.docs[0]) {
+			s.selected.docs[0] = s.retained(doc)
+			heap.Fix(s.selected, 0)
+		}
+		return
+	}
+
+	heap.Push(s.selected, s.retained(doc))
+}
+
+// Returns the copy of a selected document retained by the Selection
+func (s *Selection) retained(doc bson.Raw) bson.Raw {
+	if s.keysOnly {
+		return Project(doc, []string{IdField}, s.o.field)
+	}
+	return append(bson.Raw{}, doc...)
+}
+
+// Returns true if the Selection retains a page of documents and the document following it.  A store offering
+// documents in the order of the sort of the query (see ById) need offer no more once the Selection is full.
+func (s *Selection) Full() bool {
+	return s.q.Limit > 0 && s.selected.Len() > s.q.Limit
+}
+
+// Returns true if documents are selected in the order of their business ids, ascending unless Descending
+func (s *Selection) ById() bool {
+	return s.o.field == IdField
+}
+
+// Returns true if documents are selected in descending order
+func (s *Selection) Descending() bool {
+	return s.o.descending
+}
+
+// Returns the business id of the document at the position of the cursor of the query, which precedes every document
+// that is selected, or the empty string if the query has no cursor.
+func (s *Selection) After() string {
+	if s.c == nil {
+		return ""
+	}
+	return s.c.Id
+}
+
+// Returns the selected documents in the order of the sort of the query, restricted to its fields.  The query must not
+// have a Limit, lest only the first Limit+1 of the selected documents be returned.  No documents may be offered once
+// All is called.
+func (s *Selection) All() []bson.Raw {
+	s.sort()
+	docs := make([]bson.Raw, len(s.selected.docs))
+	for i := range s.selected.docs {
+		docs[i] = Project(s.selected.docs[i], s.q.Fields)
+	}
+	return docs
+}
+
+// Returns the business ids of the selected documents in the order of the sort of the query.  As with All, the query
+// must not have a Limit, and no documents may be offered once Ids is called.
+func (s *Selection) Ids() []string {
+	s.sort()
+	ids := make([]string, len(s.selected.docs))
+	for i := range s.selected.docs {
+		ids[i], _ = s.selected.docs[i].Lookup(IdField).StringValueOK()
+	}
+	return ids
+}
+
+// Returns the first Limit of the selected documents in the order of the sort of the query, restricted to its fields,
+// and the cursor selecting the following page.  The cursor is empty if there are no further pages.  No documents may
+// be offered once Page is called.
+func (s *Selection) Page() ([]bson.Raw, string, error) {
+	if s.q.Limit < 1 {
+		panic(fmt.Sprintf("store/scan: query limit must be a positive integer (was: %d)", s.q.Limit))
+	}
+
+	s.sort()
+	docs := s.selected.docs
+
+	var next string
+	if len(docs) > s.q.Limit {
+		docs = docs[:s.q.Limit]
+		last := cursor{Field: s.q.Sort.Field, Descending: s.q.Sort.Descending}
+		last.Id, _ = docs[len(docs)-1].Lookup(IdField).StringValueOK()
+		if s.q.Sort.Field != "" {
+			value := docs[len(docs)-1].Lookup(s.o.field)
+			last.Value = &value
+		}
+		var err error
+		if next, err = last.encode(); err != nil {
+			return nil, "", store.GenericErr("attempt to encode cursor failed", err)
+		}
+	}
+
+	page := make([]bson.Raw, len(docs))
+	for i := range docs {
+		page[i] = Project(docs[i], s.q.Fields, s.q.Sort.Field)
+	}
+
+	return page, next, nil
+}
+
+func (s *Selection) sort() {
+	docs := s.selected.docs
+	sort.Slice(docs, func(i, j int) bool { return s.o.less(docs[i], docs[j]) })
+}
+
+// Returns the document restricted to the business id, the named fields, and the additional fields, mirroring a mongo
+// projection.  The document is returned as-is if no fields are named.
+func Project(doc bson.Raw, fields []string, additional ...string) bson.Raw {
+	if len(fields) == 0 {
+		return doc
+	}
+
+	projected := map[string]bool{IdField: true}
+	for _, field := range append(append([]string{}, fields...), additional...) {
+		projected[fieldName(field)] = true
+	}
+
+	var result bson.D
+	elems, _ := doc.Elements()
+	for i := range elems {
+		if projected[elems[i].Key()] {
+			result = append(result, bson.E{Key: elems[i].Key(), Value: elems[i].Value()})
+		}
+	}
+
+	data, _ := bson.Marshal(result)
+	return data
+}
+
+// Returns a store.Iterator over the supplied documents, which must remain valid until it is closed
+func Iterate(docs []bson.Raw) store.Iterator {
+	return &iterator{docs: docs}
+}
+
+// Returns a store.Iterator over the documents selected by the query, which are fetched a page at a time by calling
+// page with the query, its Limit set to size, and its Cursor set to that returned with the previous page.  Only a page
+// of documents is held at a time, and the next page is not fetched until the documents of the previous page have been
+// visited.
+func IteratePages(q store.Query, size int, page func(q store.Query) ([]bson.Raw, string, error)) store.Iterator {
+	q.Cursor, q.Limit = "", size
+	return &iterator{q: q, page: page}
+}
+
+// A store.Iterator over documents selected by a query: either a snapshot of every document, or a page of documents
+// followed by those fetched by page
+type iterator struct {
+	docs []bson.Raw
+	q    store.Query
+	// fetches the next page of documents, nil once there are none
+	page func(q store.Query) ([]bson.Raw, string, error)
+	err  error
+}
+
+func (it *iterator) Next(t interface{}) bool {
+	if _, ok := t.(model.WebResource); !ok {
+		panic(fmt.Sprintf("store/scan: can only iterate objects of type model.WebResource, not %T", t))
+	}
+
+	for it.err == nil && len(it.docs) == 0 && it.page != nil {
+		var next string
+		if it.docs, next, it.err = it.page(it.q); next == "" {
+			it.page = nil
+		}
+		it.q.Cursor = next
+	}
+
+	if it.err != nil || len(it.docs) == 0 {
+		return false
+	}
+	if err := bson.Unmarshal(it.docs[0], t); err != nil {
+		it.err = store.SentinelErr(store.DecodingErr, fmt.Sprintf("type:  %T", t), err)
+		return false
+	}
+	it.docs = it.docs[1:]
+	return true
+}
+
+func (it *iterator) Err() error {
+	return it.err
+}
+
+func (it *iterator) Close() error {
+	it.docs, it.page = nil, nil
+	return nil
+}
+
+// Returns the error to use when a write operation on the document identified by id cannot proceed, or nil if it can:
+// either the document does not exist, or it is a tombstone.
+func Missing(id string, exists, deleted bool, msg string) error {
+	if !exists {
+		return store.SentinelErr(store.NotFoundErr, msg, nil)
+	}
+
+	if deleted {
+		return store.SentinelErr(store.DeletedErr, fmt.Sprintf("key: %s", id), nil)
+	}
+
+	return nil
+}
+
+// Unmarshals each of the supplied documents, appending them to the slice pointed to by t.
+func DecodeAll(docs []bson.Raw, t interface{}) error {
+	ptr := reflect.ValueOf(t)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice {
+		panic(fmt.Sprintf("store/scan: can only list into a pointer to a slice, not %T", t))
+	}
+
+	slice := ptr.Elem()
+	elemType := slice.Type().Elem()
+	if _, ok := reflect.New(elemType).Interface().(model.WebResource); !ok {
+		panic(fmt.Sprintf("store/scan: can only list objects of type model.WebResource, not %v", elemType))
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, len(docs))
+	for i := range docs {
+		elem := reflect.New(elemType)
+		if err := bson.Unmarshal(docs[i], elem.Interface()); err != nil {
+			return store.SentinelErr(store.DecodingErr, fmt.Sprintf("type:  %v", elemType), err)
+		}
+		result = reflect.Append(result, elem.Elem())
+	}
+
+	slice.Set(result)
+	return nil
+}
@@ -0,0 +1,182 @@
+package scan
+
+import (
+	"errors"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"strconv"
+	"testing"
+)
+
+func marshalAll(t *testing.T, negs ...model.Neg) []bson.Raw {
+	docs := make([]bson.Raw, len(negs))
+	for i := range negs {
+		data, err := bson.Marshal(negs[i])
+		require.Nil(t, err)
+		docs[i] = data
+	}
+	return docs
+}
+
+func TestSelect(t *testing.T) {
+	docs := marshalAll(t,
+		model.Neg{Id: "c", Film: "Tri-X", EI: 400},
+		model.Neg{Id: "a", Film: "FP4", EI: 125},
+		model.Neg{Id: "b", Film: "Tri-X", EI: 1600},
+		model.Neg{Id: "d", Film: "Tri-X", EI: 400},
+	)
+
+	sel, err := Select(store.Query{
+		Criteria: []store.Criterion{{Field: "Film", Op: store.Eq, Value: "Tri-X"}},
+		Sort:     store.Sort{Field: "EI"},
+		Limit:    2,
+		Fields:   []string{"Film"},
+	})
+	require.Nil(t, err)
+	for i := range docs {
+		sel.Offer(docs[i])
+	}
+	// offered documents need not remain valid
+	docs[0][len(docs[0])-2] = 0
+
+	page, next, err := sel.Page()
+	require.Nil(t, err)
+	assert.NotEmpty(t, next)
+	negs := []model.Neg{}
+	require.Nil(t, DecodeAll(page, &negs))
+	// ties are broken by business id, and the sort field is retained by the projection
+	assert.Equal(t, []model.Neg{{Id: "c", Film: "Tri-X", EI: 400}, {Id: "d", Film: "Tri-X", EI: 400}}, negs)
+
+	sel, err = Select(store.Query{
+		Criteria: []store.Criterion{{Field: "Film", Op: store.Eq, Value: "Tri-X"}},
+		Sort:     store.Sort{Field: "EI"},
+		Fields:   []string{"Film"},
+	})
+	require.Nil(t, err)
+	for i := range docs {
+		sel.Offer(docs[i])
+	}
+	negs = []model.Neg{}
+	require.Nil(t, DecodeAll(sel.All(), &negs))
+	assert.Equal(t, []model.Neg{{Id: "c", Film: "Tri-X"}, {Id: "d", Film: "Tri-X"}, {Id: "b", Film: "Tri-X"}}, negs)
+}
+
+// a Selection retains only the documents of a page, and the one following it
+func TestSelect_Bounded(t *testing.T) {
+	sel, err := Select(store.Query{Sort: store.Sort{Field: "EI", Descending: true}, Limit: 3})
+	require.Nil(t, err)
+
+	for ei := 0; ei < 100; ei++ {
+		sel.Offer(marshalAll(t, model.Neg{Id: strconv.Itoa(ei), EI: (ei * 37) % 100})[0])
+		assert.True(t, sel.selected.Len() <= 4)
+	}
+	assert.True(t, sel.Full())
+
+	page, next, err := sel.Page()
+	require.Nil(t, err)
+	assert.NotEmpty(t, next)
+	negs := []model.Neg{}
+	require.Nil(t, DecodeAll(page, &negs))
+	require.Len(t, negs, 3)
+	assert.Equal(t, []int{99, 98, 97}, []int{negs[0].EI, negs[1].EI, negs[2].EI})
+}
+
+// pages are fetched as the iterator advances, each following the cursor of the last
+// a Selection of keys retains only the business id and sort field of each selected document
+func TestSelectKeys(t *testing.T) {
+	docs := marshalAll(t,
+		model.Neg{Id: "c", Film: "Tri-X", EI: 400, Developer: "D-76"},
+		model.Neg{Id: "a", Film: "FP4", EI: 125, Developer: "D-76"},
+		model.Neg{Id: "b", Film: "Tri-X", EI: 1600, Developer: "D-76"},
+	)
+
+	sel, err := SelectKeys(store.Query{
+		Criteria: []store.Criterion{{Field: "Film", Op: store.Eq, Value: "Tri-X"}},
+		Sort:     store.Sort{Field: "EI", Descending: true},
+	})
+	require.Nil(t, err)
+	for i := range docs {
+		sel.Offer(docs[i])
+	}
+
+	for _, doc := range sel.selected.docs {
+		elems, _ := doc.Elements()
+		assert.Len(t, elems, 2)
+	}
+	assert.Equal(t, []string{"b", "c"}, sel.Ids())
+}
+
+func TestIteratePages(t *testing.T) {
+	docs := marshalAll(t, model.Neg{Id: "a"}, model.Neg{Id: "b"}, model.Neg{Id: "c"})
+	var queries []store.Query
+	page := func(q store.Query) ([]bson.Raw, string, error) {
+		queries = append(queries, q)
+		sel, err := Select(q)
+		require.Nil(t, err)
+		for i := range docs {
+			sel.Offer(docs[i])
+		}
+		return sel.Page()
+	}
+
+	it := IteratePages(store.Query{Limit: 50, Cursor: "moo"}, 2, page)
+	assert.Empty(t, queries)
+
+	var ids []string
+	for neg := (model.Neg{}); it.Next(&neg); neg = (model.Neg{}) {
+		ids = append(ids, neg.Id)
+		// a page is only fetched once those preceding it have been visited
+		assert.Len(t, queries, (len(ids)-1)/2+1)
+	}
+	require.Nil(t, it.Err())
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+
+	require.Len(t, queries, 2)
+	assert.Equal(t, store.Query{Limit: 2}, queries[0])
+	assert.NotEmpty(t, queries[1].Cursor)
+
+	failing := IteratePages(store.Query{}, 2, func(q store.Query) ([]bson.Raw, string, error) {
+		return nil, "", store.SentinelErr(store.UnavailableErr, "", nil)
+	})
+	assert.False(t, failing.Next(&model.Neg{}))
+	assert.True(t, errors.Is(failing.Err(), store.UnavailableErr))
+}
+
+func TestSelect_Invalid(t *testing.T) {
+	for name, q := range map[string]store.Query{
+		"malformed cursor":   {Cursor: "moo"},
+		"unknown operator":   {Criteria: []store.Criterion{{Field: "EI", Op: store.Operator(99), Value: 1}}},
+		"unmarshalable":      {Criteria: []store.Criterion{{Field: "EI", Op: store.Eq, Value: make(chan int)}}},
+		"mismatched cursor":  {Cursor: mustEncode(t, cursor{Field: "EI"}), Sort: store.Sort{Field: "Film"}},
+		"cursor sans value":  {Cursor: mustEncode(t, cursor{Field: "EI"}), Sort: store.Sort{Field: "EI"}},
+		"reversed direction": {Cursor: mustEncode(t, cursor{Descending: true})},
+	} {
+		_, err := Select(q)
+		assert.True(t, errors.Is(err, store.InvalidQueryErr), name)
+	}
+}
+
+func mustEncode(t *testing.T, c cursor) string {
+	encoded, err := c.encode()
+	require.Nil(t, err)
+	return encoded
+}
+
+func TestProject(t *testing.T) {
+	doc := marshalAll(t, model.Neg{Id: "moo", Film: "FP4", EI: 125, Developer: "Rodinal"})[0]
+
+	assert.Equal(t, doc, Project(doc, nil))
+
+	neg := model.Neg{}
+	require.Nil(t, bson.Unmarshal(Project(doc, []string{"Film"}, "EI"), &neg))
+	assert.Equal(t, model.Neg{Id: "moo", Film: "FP4", EI: 125}, neg)
+}
+
+func TestMissing(t *testing.T) {
+	assert.Nil(t, Missing("moo", true, false, "update failed"))
+	assert.True(t, errors.Is(Missing("moo", false, false, "update failed"), store.NotFoundErr))
+	assert.True(t, errors.Is(Missing("moo", true, true, "update failed"), store.DeletedErr))
+}
@@ -5,9 +5,14 @@ package store
 import (
 	"errors"
 	"fmt"
+	"io"
+	"time"
 )
 
-// Presents an API for durably storing business objects.
+// Presents an API for durably storing business objects.  Every implementation shares the same semantics: business ids
+// are unique, including those of deleted business objects, which are retained as tombstones until purged; and errors
+// are StorageErrors, reported with the sentinels of this package, so that callers need not know which implementation
+// they are using.
 type Api interface {
 
 	// Retrieve the identified object from the store and unmarshal it to t.
@@ -16,53 +21,227 @@ type Api interface {
 	//   _ = impl.Retrieve("1", &negative)
 	//
 	// The identifier is a persistence layer id, which may change to a business layer
-	// id in the future.
+	// id in the future.  If no object is identified, the returned error wraps NotFoundErr.
 	Retrieve(id string, t interface{}) (err error)
 
+	// Retrieve the named fields of the identified object from the store, and unmarshal them to t, as Retrieve does.
+	// Fields are named as declared by the model struct, e.g. "Film".  Fields that are not named may be left with their
+	// zero value; if no fields are named, every field is retrieved.  Errors are those of Retrieve.
+	RetrieveFields(id string, fields []string, t interface{}) (err error)
+
+	// Retrieve each of the identified objects from the store, and unmarshal them to t, whose underlying value must be a
+	// pointer to a slice of model structs, as for List.  Objects are appended in the order their identifiers are
+	// supplied, and an identifier supplied more than once is retrieved once.  The identifiers of objects that do not
+	// exist, or have been deleted, are returned as missing, in the order supplied.
+	//
+	// The identifiers are business layer ids.
+	RetrieveMany(ids []string, t interface{}) (missing []string, err error)
+
 	// Durably persist the supplied object in the storage layer.
 	// The returned id will be a persistence layer id, which may change to a business
 	// layer id in the future.
 	Store(obj interface{}) (id string, err error)
+
+	// Durably persist each of the supplied objects in the storage layer.  Objects are stored independently, and in no
+	// particular order: the failure to store one object, e.g. because its business id is a duplicate, does not prevent
+	// the others from being stored.
+	//
+	// The returned slice has an element for each object, in the order supplied: nil if the object was stored, otherwise
+	// the error that prevented it from being stored.  The returned error is non-nil if the operation as a whole failed,
+	// in which case the objects without an element error may or may not have been stored.
+	StoreMany(objs []interface{}) (errs []error, err error)
+
+	// Replace the state of the identified object in the storage layer with the supplied object.  The object must
+	// already exist in the storage layer, otherwise the returned error wraps NotFoundErr.
+	//
+	// The identifier is a business layer id.  Callers are responsible for preserving any state that must not change
+	// across updates, e.g. the creation time of the object.
+	Update(id string, obj interface{}) (err error)
+
+	// Mark the identified object as deleted.  The object is not physically removed; it remains in the storage layer as
+	// a tombstone until it is purged.  Subsequent attempts to retrieve, update, or delete the object fail with
+	// DeletedErr.  If no object is identified, the returned error wraps NotFoundErr.
+	//
+	// The identifier is a business layer id.
+	Delete(id string) (err error)
+
+	// Physically remove the tombstones of objects that were deleted before the supplied time.  Returns the number of
+	// tombstones that were removed.
+	Purge(deletedBefore time.Time) (count int, err error)
+
+	// Select a page of business objects using the supplied query, and unmarshal them to t.  The underlying value of t
+	// must be a pointer to a slice of model structs, e.g.:
+	//   negatives := []model.Neg{}
+	//   next, _ := impl.List(store.Query{Limit: 10}, &negatives)
+	//
+	// The returned cursor may be used to select the following page, and is empty if there are no further pages.  Errors
+	// caused by a malformed query, e.g. an invalid cursor, wrap InvalidQueryErr.
+	List(q Query, t interface{}) (cursor string, err error)
+
+	// Open an Iterator over every business object selected by the criteria of the supplied query, in the order of its
+	// sort.  The Limit and Cursor of the query are ignored.  Business objects are read from the storage layer as the
+	// Iterator is advanced, so callers may visit every business object without holding them all in memory, e.g.:
+	//   it, _ := impl.Iterate(store.Query{})
+	//   defer it.Close()
+	//   negative := model.Neg{}
+	//   for it.Next(&negative) {
+	//   	...
+	//   	negative = model.Neg{}
+	//   }
+	//   err = it.Err()
+	//
+	// Errors caused by a malformed query wrap InvalidQueryErr.
+	Iterate(q Query) (it Iterator, err error)
+}
+
+// Implemented by storage layers that can be copied while in use, e.g. those kept in a local file.
+type Backupable interface {
+
+	// Write a consistent copy of every object in the storage layer to w, in the format of the storage layer, such that
+	// the copy may be used in its place.  Objects may be stored, updated, and deleted while the copy is written; changes
+	// that are not yet durable when the copy begins are not included.  Returns the number of bytes written.
+	Backup(w io.Writer) (n int64, err error)
 }
 
 const (
+	// The implementation of the persistence layer, e.g. mongo
+	EnvDbType          = "DB_TYPE"
 	EnvDbUri           = "DB_URI"
 	EnvDbName          = "DB_NAME"
 	EnvDbNegCollection = "DB_NEG_COLLECTION"
+	// The file holding the persistence layer, for implementations that keep it in a local file
+	EnvDbPath = "DB_PATH"
+	// The level at which the persistence layer validates business objects against their schema: off, moderate, or
+	// strict.  Only store/mongo validates business objects.
+	EnvDbSchemaValidation = "DB_SCHEMA_VALIDATION"
+)
+
+// A stable, machine-readable identifier of a kind of storage error.  Codes may be logged or exposed to clients, and
+// will not change across releases.
+type Code string
+
+const (
+	CodeGeneral      Code = "general"
+	CodeDuplicateKey Code = "duplicate_key"
+	CodeDecoding     Code = "decoding"
+	CodeDeleted      Code = "deleted"
+	CodeNotFound     Code = "not_found"
+	CodeInvalidQuery Code = "invalid_query"
+	CodeUnavailable  Code = "unavailable"
 )
 
+// A kind of storage error.  Kinds are the sentinel values that callers test for with errors.Is, e.g.:
+//   if errors.Is(err, store.NotFoundErr) { ... }
+type kind struct {
+	code Code
+	msg  string
+	// true if operations failing with this kind of error may succeed when retried
+	temporary bool
+}
+
+func (k *kind) Error() string {
+	return k.msg
+}
+
+var GeneralErr error = &kind{CodeGeneral, "store: error occurred interacting with the storage layer", false}
+var DuplicateKeyErr error = &kind{CodeDuplicateKey, "store: attempt to insert a duplicate key", false}
+var DecodingErr error = &kind{CodeDecoding, "store: error decoding object", false}
+var DeletedErr error = &kind{CodeDeleted, "store: object has been deleted", false}
+var NotFoundErr error = &kind{CodeNotFound, "store: object not found", false}
+var UnavailableErr error = &kind{CodeUnavailable, "store: storage layer is unavailable", true}
+
+// An error returned by an implementation of Api.  A StorageError is of exactly one kind, identified by its sentinel,
+// and may wrap the underlying error (e.g. an error returned by a database driver) that caused it.
+//
+// errors.Is reports true only for the sentinel of the StorageError, or for errors in the chain of its cause.
 type StorageError struct {
-	sentinel   error
-	msg, cause string
+	sentinel error
+	msg      string
+	cause    error
 }
 
-func SentinelErr(sentinel error, msg, cause string) StorageError {
+// Creates a StorageError of the kind identified by sentinel.  The msg and cause are optional, and may be empty or nil
+// respectively.
+func SentinelErr(sentinel error, msg string, cause error) error {
 	if sentinel == nil {
 		panic("store: error creating error, sentinel value required")
 	}
 	return StorageError{sentinel, msg, cause}
 }
 
-func GenericErr(msg, cause string) error {
+// Creates a StorageError of the kind GeneralErr.
+func GenericErr(msg string, cause error) error {
 	return StorageError{GeneralErr, msg, cause}
 }
 
 func (e StorageError) Error() string {
 	if len(e.msg) > 0 {
-		if len(e.cause) > 0 {
-			return fmt.Sprintf("%s, %s: %s", e.sentinel.Error(), e.msg, e.cause)
+		if e.cause != nil {
+			return fmt.Sprintf("%s, %s: %v", e.sentinel.Error(), e.msg, e.cause)
 		} else {
 			return fmt.Sprintf("%s, %s", e.sentinel.Error(), e.msg)
 		}
 	}
 
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %v", e.sentinel.Error(), e.cause)
+	}
+
 	return e.sentinel.Error()
 }
 
-var GeneralErr = errors.New("store: error occurred interacting with the storage layer")
-var DuplicateKeyErr = errors.New("store: attempt to insert a duplicate key")
-var DecodingErr = errors.New("store: error decoding object")
-
+// Returns true only if target is the sentinel of this error.
 func (e StorageError) Is(target error) bool {
-	return target == GeneralErr || target == DuplicateKeyErr || target == DecodingErr
+	return e.sentinel == target
+}
+
+// Returns the underlying cause of this error, which may be nil.
+func (e StorageError) Unwrap() error {
+	return e.cause
+}
+
+// Returns the machine-readable code of the kind of this error.  Errors created with a sentinel that is not declared by
+// this package are of the code CodeGeneral.
+func (e StorageError) Code() Code {
+	if k, ok := e.sentinel.(*kind); ok {
+		return k.code
+	}
+	return CodeGeneral
+}
+
+// Returns true if the operation that failed with this error may succeed when retried, either because its kind is
+// temporary (e.g. UnavailableErr), or because its cause reports itself as temporary or as a timeout.
+func (e StorageError) Temporary() bool {
+	if k, ok := e.sentinel.(*kind); ok && k.temporary {
+		return true
+	}
+
+	var temporary interface{ Temporary() bool }
+	if errors.As(e.cause, &temporary) && temporary.Temporary() {
+		return true
+	}
+
+	var timeout interface{ Timeout() bool }
+	return errors.As(e.cause, &timeout) && timeout.Timeout()
+}
+
+// Returns the code of the StorageError in the chain of err.  If err is nil, the empty code is returned; if there is no
+// StorageError in the chain, CodeGeneral is returned.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+
+	var e StorageError
+	if errors.As(err, &e) {
+		return e.Code()
+	}
+
+	return CodeGeneral
+}
+
+// Returns true if the StorageError in the chain of err is temporary; see StorageError.Temporary.
+func Temporary(err error) bool {
+	var e StorageError
+	return errors.As(err, &e) && e.Temporary()
 }
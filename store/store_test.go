@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStorageError_Is(t *testing.T) {
+	err := SentinelErr(DuplicateKeyErr, "key: moo", nil)
+
+	assert.True(t, errors.Is(err, DuplicateKeyErr))
+	assert.False(t, errors.Is(err, GeneralErr))
+	assert.False(t, errors.Is(err, DecodingErr))
+	assert.False(t, errors.Is(err, NotFoundErr))
+
+	wrapped := fmt.Errorf("context: %w", err)
+	assert.True(t, errors.Is(wrapped, DuplicateKeyErr))
+	assert.False(t, errors.Is(wrapped, DeletedErr))
+}
+
+func TestStorageError_Unwrap(t *testing.T) {
+	cause := errors.New("driver error")
+	err := GenericErr("attempt to insert document failed", cause)
+
+	assert.True(t, errors.Is(err, GeneralErr))
+	assert.True(t, errors.Is(err, cause))
+	assert.Equal(t, cause, errors.Unwrap(err))
+	assert.Equal(t, "store: error occurred interacting with the storage layer, attempt to insert document failed: "+
+		"driver error", err.Error())
+}
+
+func TestCodeOf(t *testing.T) {
+	assert.Equal(t, CodeNotFound, CodeOf(SentinelErr(NotFoundErr, "", nil)))
+	assert.Equal(t, CodeDeleted, CodeOf(fmt.Errorf("wrapped: %w", SentinelErr(DeletedErr, "", nil))))
+	assert.Equal(t, CodeInvalidQuery, CodeOf(SentinelErr(InvalidQueryErr, "", nil)))
+	assert.Equal(t, CodeGeneral, CodeOf(SentinelErr(errors.New("foreign sentinel"), "", nil)))
+	assert.Equal(t, CodeGeneral, CodeOf(errors.New("not a storage error")))
+	assert.Equal(t, Code(""), CodeOf(nil))
+}
+
+func TestTemporary(t *testing.T) {
+	assert.True(t, Temporary(SentinelErr(UnavailableErr, "", nil)))
+	assert.True(t, Temporary(GenericErr("", fmt.Errorf("wrapped: %w", context.DeadlineExceeded))))
+	assert.False(t, Temporary(GenericErr("", errors.New("driver error"))))
+	assert.False(t, Temporary(SentinelErr(NotFoundErr, "", nil)))
+	assert.False(t, Temporary(errors.New("not a storage error")))
+}
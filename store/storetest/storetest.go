@@ -0,0 +1,328 @@
+// Verifies that an implementation of store.Api conforms to the semantics documented by store.Api, so that every
+// implementation is held to the same behavior by a single suite, e.g.:
+//   func TestMyStore_Conformance(t *testing.T) {
+//   	storetest.Run(t, func(t *testing.T) store.Api { return newMyStore(t) })
+//   }
+//
+// Tests of behavior specific to an implementation, e.g. durability across restarts, remain with the implementation.
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/emetsger/negtracker/model"
+	"github.com/emetsger/negtracker/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The business object stored by the suite, under the business id "moo" unless stated otherwise
+var sampleNeg = model.Neg{
+	Id:        "moo",
+	Created:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	Updated:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	Film:      "FP4",
+	EI:        100,
+	Developer: "Pyrocat HD",
+	Tags:      []string{"druid hill", "spring"},
+	Format:    "120",
+}
+
+// Runs the suite against the stores returned by newStore, which is called once for each test of the suite and must
+// return a store holding no business objects.  The store may hold business objects that are invalid per their schema.
+func Run(t *testing.T, newStore func(t *testing.T) store.Api) {
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, s store.Api)
+	}{
+		{"StoreAndRetrieve", testStoreAndRetrieve},
+		{"StoreDuplicate", testStoreDuplicate},
+		{"StoreMany", testStoreMany},
+		{"RetrieveDecoding", testRetrieveDecoding},
+		{"RetrieveFields", testRetrieveFields},
+		{"RetrieveMany", testRetrieveMany},
+		{"UpdateAndDelete", testUpdateAndDelete},
+		{"Purge", testPurge},
+		{"List", testList},
+		{"ListById", testListById},
+		{"Iterate", testIterate},
+		{"Concurrent", testConcurrent},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+// Stores a copy of sampleNeg under each of the supplied business ids
+func storeIds(t *testing.T, s store.Api, ids ...string) {
+	for _, id := range ids {
+		obj := sampleNeg
+		obj.Id = id
+		_, err := s.Store(obj)
+		require.Nil(t, err)
+	}
+}
+
+// Returns the ids of the Negs listed by the query, a page at a time
+func listIds(t *testing.T, s store.Api, q store.Query) []string {
+	var ids []string
+	for pages := 0; ; pages++ {
+		require.True(t, pages < 10)
+		negs := []model.Neg{}
+		next, err := s.List(q, &negs)
+		require.Nil(t, err)
+		for i := range negs {
+			ids = append(ids, negs[i].Id)
+		}
+		if next == "" {
+			return ids
+		}
+		q.Cursor = next
+	}
+}
+
+func testStoreAndRetrieve(t *testing.T, s store.Api) {
+	obj := sampleNeg
+	obj.Tags = []string{"druid hill", "spring"}
+	pid, err := s.Store(obj)
+	require.Nil(t, err)
+	assert.NotEmpty(t, pid)
+
+	retrieved := model.Neg{}
+	require.Nil(t, s.Retrieve("moo", &retrieved))
+	assert.Equal(t, obj, retrieved)
+
+	// the stored state is not shared with the caller
+	obj.Tags[0] = "moo"
+	retrieved = model.Neg{}
+	require.Nil(t, s.Retrieve("moo", &retrieved))
+	assert.Equal(t, "druid hill", retrieved.Tags[0])
+
+	err = s.Retrieve("oink", &model.Neg{})
+	assert.True(t, errors.Is(err, store.NotFoundErr))
+}
+
+func testStoreDuplicate(t *testing.T, s store.Api) {
+	_, err := s.Store(sampleNeg)
+	require.Nil(t, err)
+
+	_, err = s.Store(sampleNeg)
+	assert.True(t, errors.Is(err, store.DuplicateKeyErr))
+
+	// business ids are not reused once deleted
+	require.Nil(t, s.Delete("moo"))
+	_, err = s.Store(sampleNeg)
+	assert.True(t, errors.Is(err, store.DuplicateKeyErr))
+}
+
+func testStoreMany(t *testing.T, s store.Api) {
+	other := sampleNeg
+	other.Id = "oink"
+	errs, err := s.StoreMany([]interface{}{sampleNeg, other, sampleNeg, make(chan int)})
+	require.Nil(t, err)
+	require.Len(t, errs, 4)
+	assert.Nil(t, errs[0])
+	assert.Nil(t, errs[1])
+	assert.True(t, errors.Is(errs[2], store.DuplicateKeyErr))
+	assert.True(t, errors.Is(errs[3], store.GeneralErr))
+
+	assert.Nil(t, s.Retrieve("oink", &model.Neg{}))
+}
+
+func testRetrieveDecoding(t *testing.T, s store.Api) {
+	_, err := s.Store(struct {
+		Id string `bson:"id"`
+		EI string `bson:"ei"`
+	}{"moo", "one hundred"})
+	require.Nil(t, err)
+
+	err = s.Retrieve("moo", &model.Neg{})
+	assert.True(t, errors.Is(err, store.DecodingErr))
+
+	assert.Panics(t, func() { _ = s.Retrieve("moo", &struct{}{}) })
+}
+
+func testRetrieveFields(t *testing.T, s store.Api) {
+	_, err := s.Store(sampleNeg)
+	require.Nil(t, err)
+
+	retrieved := model.Neg{}
+	require.Nil(t, s.RetrieveFields("moo", []string{"Film", "Tags"}, &retrieved))
+	assert.Equal(t, model.Neg{Id: "moo", Film: "FP4", Tags: sampleNeg.Tags}, retrieved)
+}
+
+func testRetrieveMany(t *testing.T, s store.Api) {
+	storeIds(t, s, "moo", "oink", "quack")
+	require.Nil(t, s.Delete("oink"))
+
+	negs := []model.Neg{}
+	missing, err := s.RetrieveMany([]string{"quack", "oink", "baa", "moo", "quack"}, &negs)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"oink", "baa"}, missing)
+	require.Len(t, negs, 2)
+	assert.Equal(t, "quack", negs[0].Id)
+	assert.Equal(t, "moo", negs[1].Id)
+}
+
+func testUpdateAndDelete(t *testing.T, s store.Api) {
+	_, err := s.Store(sampleNeg)
+	require.Nil(t, err)
+
+	updated := sampleNeg
+	updated.Developer = "Rodinal"
+	require.Nil(t, s.Update("moo", &updated))
+
+	retrieved := model.Neg{}
+	require.Nil(t, s.Retrieve("moo", &retrieved))
+	assert.Equal(t, "Rodinal", retrieved.Developer)
+
+	assert.True(t, errors.Is(s.Update("oink", &updated), store.NotFoundErr))
+	assert.True(t, errors.Is(s.Delete("oink"), store.NotFoundErr))
+
+	require.Nil(t, s.Delete("moo"))
+	assert.True(t, errors.Is(s.Retrieve("moo", &model.Neg{}), store.DeletedErr))
+	assert.True(t, errors.Is(s.Update("moo", &updated), store.DeletedErr))
+	assert.True(t, errors.Is(s.Delete("moo"), store.DeletedErr))
+}
+
+func testPurge(t *testing.T, s store.Api) {
+	storeIds(t, s, "moo", "oink")
+	require.Nil(t, s.Delete("moo"))
+
+	count, err := s.Purge(time.Now().Add(-time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = s.Purge(time.Now().Add(time.Second))
+	require.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.True(t, errors.Is(s.Retrieve("moo", &model.Neg{}), store.NotFoundErr))
+	assert.Nil(t, s.Retrieve("oink", &model.Neg{}))
+
+	// once purged, the business id may be reused
+	_, err = s.Store(sampleNeg)
+	assert.Nil(t, err)
+}
+
+func testList(t *testing.T, s store.Api) {
+	for ei := 100; ei <= 700; ei += 100 {
+		obj := sampleNeg
+		obj.Id = fmt.Sprintf("neg%d", ei)
+		obj.EI = ei
+		if ei == 400 {
+			obj.Tags = []string{"pushed"}
+		}
+		_, err := s.Store(obj)
+		require.Nil(t, err)
+	}
+	require.Nil(t, s.Delete("neg700"))
+
+	q := store.Query{
+		Criteria: []store.Criterion{{Field: "EI", Op: store.Gte, Value: 200}},
+		Sort:     store.Sort{Field: "EI", Descending: true},
+		Limit:    2,
+		Fields:   []string{"Film"},
+	}
+
+	var eis []int
+	for pages := 0; ; pages++ {
+		require.True(t, pages < 3)
+		negs := []model.Neg{}
+		next, err := s.List(q, &negs)
+		require.Nil(t, err)
+		for i := range negs {
+			eis = append(eis, negs[i].EI)
+			assert.Empty(t, negs[i].Developer)
+		}
+		if next == "" {
+			break
+		}
+		q.Cursor = next
+	}
+	assert.Equal(t, []int{600, 500, 400, 300, 200}, eis)
+
+	negs := []model.Neg{}
+	_, err := s.List(store.Query{
+		Criteria: []store.Criterion{{Field: "Tags", Op: store.Contains, Value: "pushed"}},
+		Limit:    10,
+	}, &negs)
+	require.Nil(t, err)
+	require.Len(t, negs, 1)
+	assert.Equal(t, "neg400", negs[0].Id)
+
+	_, err = s.List(store.Query{Cursor: "moo", Limit: 10}, &[]model.Neg{})
+	assert.True(t, errors.Is(err, store.InvalidQueryErr))
+}
+
+func testListById(t *testing.T, s store.Api) {
+	storeIds(t, s, "c", "a", "e", "b", "d", "f")
+	require.Nil(t, s.Delete("c"))
+
+	assert.Equal(t, []string{"a", "b", "d", "e", "f"}, listIds(t, s, store.Query{Limit: 2}))
+	assert.Equal(t, []string{"f", "e", "d", "b", "a"}, listIds(t, s, store.Query{Limit: 2,
+		Sort: store.Sort{Descending: true}}))
+	assert.Equal(t, []string{"b", "d", "e"}, listIds(t, s, store.Query{Limit: 2,
+		Criteria: []store.Criterion{{Field: "Id", Op: store.Gte, Value: "b"}, {Field: "Id", Op: store.Lte, Value: "e"}}}))
+}
+
+func testIterate(t *testing.T, s store.Api) {
+	storeIds(t, s, "oink", "moo", "quack")
+
+	it, err := s.Iterate(store.Query{Sort: store.Sort{Descending: true}, Limit: 1, Fields: []string{"Film"}})
+	require.Nil(t, err)
+	defer func() { _ = it.Close() }()
+
+	var ids []string
+	neg := model.Neg{}
+	for it.Next(&neg) {
+		ids = append(ids, neg.Id)
+		assert.Equal(t, "FP4", neg.Film)
+		assert.Empty(t, neg.Developer)
+		neg = model.Neg{}
+	}
+	require.Nil(t, it.Err())
+	// the limit of the query is ignored
+	assert.Equal(t, []string{"quack", "oink", "moo"}, ids)
+
+	it, err = s.Iterate(store.Query{Cursor: "moo"})
+	require.Nil(t, err, "the cursor of the query is ignored")
+	_ = it.Close()
+
+	_, err = s.Iterate(store.Query{Criteria: []store.Criterion{{Field: "EI", Op: store.Operator(99)}}})
+	assert.True(t, errors.Is(err, store.InvalidQueryErr))
+}
+
+func testConcurrent(t *testing.T, s store.Api) {
+	const writers = 20
+	errs := make(chan error, writers)
+	wg := sync.WaitGroup{}
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			obj := sampleNeg
+			obj.EI = i
+			_, err := s.Store(obj)
+			errs <- err
+			_ = s.Retrieve("moo", &model.Neg{})
+			_, _ = s.List(store.Query{Limit: 10}, &[]model.Neg{})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	stored := 0
+	for err := range errs {
+		if err == nil {
+			stored++
+		} else {
+			assert.True(t, errors.Is(err, store.DuplicateKeyErr))
+		}
+	}
+	assert.Equal(t, 1, stored)
+}
@@ -0,0 +1,190 @@
+// Validates the state of model structs against constraints declared by the `validate` tags of their fields, e.g.:
+//   type Neg struct {
+//   	Film   string `validate:"required"`
+//   	EI     int    `validate:"min=0,max=25600"`
+//   	Format string `validate:"oneof=35mm|120|4x5"`
+//   }
+//
+// Supported constraints:
+//   required: the field must not be the zero value; strings must not be blank
+//   min=N, max=N: numbers must be within the inclusive range; the length of strings and slices must be within the range
+//   oneof=A|B|C: strings must be one of the listed values.  The empty string is permitted unless the field is required.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The name of the struct tag declaring the constraints of a field
+const tagName = "validate"
+
+// A constraint violated by a field of a model struct
+type Violation struct {
+	// The name of the field, as it appears in the JSON representation of the model struct
+	Field string
+	// Describes the violated constraint, e.g. "must be at least 0"
+	Reason string
+}
+
+// A constraint declared by the `validate` tag of a field, e.g. the constraint "min=0" has the Name "min" and the Arg "0"
+type Constraint struct {
+	Name string
+	// The argument of the constraint, or the empty string if it has none
+	Arg string
+}
+
+func (c Constraint) String() string {
+	if c.Arg == "" {
+		return c.Name
+	}
+	return c.Name + "=" + c.Arg
+}
+
+// The constraints violated by a model struct.  A nil or empty Violations means the model struct is valid.
+type Violations []Violation
+
+func (v Violations) Error() string {
+	reasons := make([]string, len(v))
+	for i := range v {
+		reasons[i] = fmt.Sprintf("%s %s", v[i].Field, v[i].Reason)
+	}
+	return "validate: " + strings.Join(reasons, "; ")
+}
+
+// Groups the reasons of the violations by the name of the violating field.
+func (v Violations) ByField() map[string][]string {
+	result := map[string][]string{}
+	for i := range v {
+		result[v[i].Field] = append(result[v[i].Field], v[i].Reason)
+	}
+	return result
+}
+
+// Validates the supplied struct, or pointer to a struct, against the constraints declared by the tags of its fields.
+// Returns the violated constraints in the order the fields are declared, or nil if there are none.  Panics if v is not a
+// struct, or if a tag is malformed.
+func Struct(v interface{}) Violations {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: can only validate structs, not %T", v))
+	}
+
+	var violations Violations
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		for _, constraint := range Constraints(field) {
+			if reason := check(constraint, value.Field(i), field); reason != "" {
+				violations = append(violations, Violation{Field: jsonName(field), Reason: reason})
+			}
+		}
+	}
+
+	return violations
+}
+
+// Returns the constraints declared by the `validate` tag of a struct field, in the order they are declared, or nil if
+// the field has no tag.  Constraints are not checked for being known or well-formed.
+func Constraints(field reflect.StructField) []Constraint {
+	tag, ok := field.Tag.Lookup(tagName)
+	if !ok {
+		return nil
+	}
+
+	var constraints []Constraint
+	for _, constraint := range strings.Split(tag, ",") {
+		name, arg := constraint, ""
+		if i := strings.Index(constraint, "="); i > -1 {
+			name, arg = constraint[:i], constraint[i+1:]
+		}
+		constraints = append(constraints, Constraint{Name: name, Arg: arg})
+	}
+	return constraints
+}
+
+// Checks the value of a field against a constraint, returning the reason the constraint is violated, or the empty
+// string if it is satisfied.
+func check(constraint Constraint, value reflect.Value, field reflect.StructField) string {
+	switch constraint.Name {
+	case "required":
+		if value.IsZero() || (value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "") {
+			return "is required"
+		}
+	case "min":
+		if n, bound := measure(value, field), parseBound(constraint, field); n < bound {
+			return fmt.Sprintf("must be at least %s", describe(bound, value))
+		}
+	case "max":
+		if n, bound := measure(value, field), parseBound(constraint, field); n > bound {
+			return fmt.Sprintf("must be at most %s", describe(bound, value))
+		}
+	case "oneof":
+		if value.Kind() != reflect.String {
+			panic(fmt.Sprintf("validate: oneof constraint on non-string field %s", field.Name))
+		}
+		allowed := strings.Split(constraint.Arg, "|")
+		if value.String() != "" && !contains(allowed, value.String()) {
+			return fmt.Sprintf("must be one of %s", strings.Join(allowed, ", "))
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown constraint '%s' on field %s", constraint, field.Name))
+	}
+
+	return ""
+}
+
+// Returns the quantity constrained by min and max: the value of a number, or the length of a string or slice.
+func measure(value reflect.Value, field reflect.StructField) float64 {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(value.Len())
+	default:
+		panic(fmt.Sprintf("validate: range constraint on unsupported field %s of kind %s", field.Name, value.Kind()))
+	}
+}
+
+func parseBound(constraint Constraint, field reflect.StructField) float64 {
+	bound, err := strconv.ParseFloat(constraint.Arg, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validate: malformed constraint '%s' on field %s", constraint, field.Name))
+	}
+	return bound
+}
+
+// Describes a bound in terms of the constrained value: a number, or a length.
+func describe(bound float64, value reflect.Value) string {
+	formatted := strconv.FormatFloat(bound, 'f', -1, 64)
+	switch value.Kind() {
+	case reflect.String:
+		return formatted + " characters long"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return formatted + " elements long"
+	default:
+		return formatted
+	}
+}
+
+// Returns the name of the field in the JSON representation of its struct
+func jsonName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+func contains(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+	return false
+}
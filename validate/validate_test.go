@@ -0,0 +1,59 @@
+package validate
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+type sample struct {
+	Name   string   `validate:"required,max=8"`
+	Count  int      `validate:"min=0,max=10"`
+	Kind   string   `json:"kind" validate:"oneof=a|b"`
+	Tags   []string `validate:"max=2"`
+	Ignore string
+}
+
+func TestStruct_Valid(t *testing.T) {
+	assert.Nil(t, Struct(sample{Name: "moo", Count: 10, Kind: "b", Tags: []string{"x"}}))
+	assert.Nil(t, Struct(&sample{Name: "moo"}))
+}
+
+func TestStruct_Violations(t *testing.T) {
+	violations := Struct(&sample{Name: "  ", Count: -1, Kind: "c", Tags: []string{"x", "y", "z"}})
+
+	assert.Equal(t, Violations{
+		{Field: "Name", Reason: "is required"},
+		{Field: "Count", Reason: "must be at least 0"},
+		{Field: "kind", Reason: "must be one of a, b"},
+		{Field: "Tags", Reason: "must be at most 2 elements long"},
+	}, violations)
+
+	assert.Equal(t, map[string][]string{"Name": {"is required", "must be at most 8 characters long"}},
+		Struct(sample{Name: "         ", Count: 1}).ByField())
+}
+
+func TestStruct_Malformed(t *testing.T) {
+	assert.Panics(t, func() { Struct("moo") })
+	assert.Panics(t, func() {
+		Struct(struct {
+			Name string `validate:"frobnicate"`
+		}{})
+	})
+	assert.Panics(t, func() {
+		Struct(struct {
+			Count int `validate:"min=zero"`
+		}{})
+	})
+}
+
+func TestConstraints(t *testing.T) {
+	typ := reflect.TypeOf(sample{})
+
+	name, _ := typ.FieldByName("Name")
+	assert.Equal(t, []Constraint{{Name: "required"}, {Name: "max", Arg: "8"}}, Constraints(name))
+	assert.Equal(t, "max=8", Constraints(name)[1].String())
+
+	ignore, _ := typ.FieldByName("Ignore")
+	assert.Nil(t, Constraints(ignore))
+}